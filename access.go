@@ -0,0 +1,135 @@
+package trix
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// accessTracker keeps the times the nodes of a root were read, and the paths
+// that were looked up but not found.
+type accessTracker struct {
+	reads  sync.Map // *Node -> *uint64
+	misses sync.Map // path string -> *int64
+}
+
+// TrackAccess enables or disables access tracking on the node's root, as
+// well as on its scopes, including existing ones, unless they track access
+// themselves: while enabled, the times each node is found by getters,
+// including each of the nodes returned by wildcard lookups, and failed
+// lookups are counted. Only the reads of the root's own nodes are counted,
+// including the ones found through scopes, and not those of the scopes'
+// nodes. Enabling it again keeps the counts; disabling it discards them. See
+// AccessReport, ReadCounts and UnreadKeys. Return the original node.
+func (node *Node) TrackAccess(on bool) *Node {
+	root := node.GetRoot()
+	if root == nil {
+		return node
+	}
+	if on {
+		root.ensureRootState().access.CompareAndSwap(nil, &accessTracker{})
+	} else if state := root.rootState(); state != &noRootState {
+		state.access.Store(nil)
+	}
+	return node
+}
+
+// accessTracker returns the tracker of the root, or of the nearest of its
+// parent scopes that tracks access, or nil if none does.
+func (root *Node) accessTracker() *accessTracker {
+	for r := root; r != nil; r = r.Parent.GetRoot() {
+		if tracker := r.rootState().access.Load(); tracker != nil {
+			return tracker
+		}
+	}
+	return nil
+}
+
+// markRead counts a read of each of the nodes that are in the tracker's root.
+func (tracker *accessTracker) markRead(nodes NodeList) {
+	for _, node := range nodes {
+		if node.GetRoot().rootState().access.Load() != tracker {
+			continue
+		}
+		counter, found := tracker.reads.Load(node)
+		if !found {
+			counter, _ = tracker.reads.LoadOrStore(node, new(uint64))
+		}
+		atomic.AddUint64(counter.(*uint64), 1)
+	}
+}
+
+// readCount returns the times the node was read.
+func (tracker *accessTracker) readCount(node *Node) int {
+	if counter, found := tracker.reads.Load(node); found {
+		return int(atomic.LoadUint64(counter.(*uint64)))
+	}
+	return 0
+}
+
+// miss counts a failed lookup of the path.
+func (tracker *accessTracker) miss(path string) {
+	counter, found := tracker.misses.Load(path)
+	if !found {
+		counter, _ = tracker.misses.LoadOrStore(path, new(int64))
+	}
+	atomic.AddInt64(counter.(*int64), 1)
+}
+
+// AccessReport returns the paths of the nodes with values, under the node,
+// that were never read since access tracking was enabled, in depth-first
+// order, and the number of times each path that wasn't found was looked up,
+// on the root or any of its scopes. If access is not being tracked, return
+// nil values. See TrackAccess.
+func (node *Node) AccessReport() (unusedPaths []string, missingPaths map[string]int) {
+	tracker := node.GetRoot().accessTracker()
+	if tracker == nil {
+		return nil, nil
+	}
+
+	unusedPaths = []string{}
+	for _, n := range node.FindFunc(func(n *Node) bool { return n.Value != nil && tracker.readCount(n) == 0 }) {
+		unusedPaths = append(unusedPaths, n.PathString())
+	}
+
+	missingPaths = map[string]int{}
+	tracker.misses.Range(func(path, counter interface{}) bool {
+		missingPaths[path.(string)] = int(atomic.LoadInt64(counter.(*int64)))
+		return true
+	})
+	return unusedPaths, missingPaths
+}
+
+// ReadCounts returns the number of times each of the nodes under the node
+// was found by getters, by path, since access tracking was enabled; nodes
+// that were never read are not included. If access is not being tracked,
+// return nil. See TrackAccess.
+func (node *Node) ReadCounts() map[string]int {
+	tracker := node.GetRoot().accessTracker()
+	if tracker == nil {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, n := range node.FindFunc(func(n *Node) bool { return tracker.readCount(n) != 0 }) {
+		counts[n.PathString()] = tracker.readCount(n)
+	}
+	return counts
+}
+
+// UnreadKeys returns the paths of the leaves under the node that were never
+// read since access tracking was enabled, in depth-first order; unlike
+// AccessReport, leaves without a value are included, and branches with a
+// value are not. If access is not being tracked, return nil. See
+// TrackAccess.
+func (node *Node) UnreadKeys() []string {
+	tracker := node.GetRoot().accessTracker()
+	if tracker == nil {
+		return nil
+	}
+	paths := []string{}
+	for _, n := range node.FindFunc(func(n *Node) bool {
+		return n.IsLeaf() && n.Flags&IsRoot == 0 && tracker.readCount(n) == 0
+	}) {
+		paths = append(paths, n.PathString())
+	}
+	return paths
+}
@@ -0,0 +1,148 @@
+package trix
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestAccessReport(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "localhost")
+	root.SetKey("db.port", 5432)
+	root.SetKey("cache.ttl", "1h")
+	root.SetKey("legacy.url", "http://old.internal")
+
+	// disabled by default
+	root.Get("db.host")
+	unused, missing := root.AccessReport()
+	testTrue(t, unused == nil && missing == nil)
+	testTrue(t, root.ReadCounts() == nil)
+
+	root.TrackAccess(true)
+	unused, missing = root.AccessReport()
+	testDeepEqual(t, unused, []string{"db.host", "db.port", "cache.ttl", "legacy.url"})
+	testDeepEqual(t, missing, map[string]int{})
+
+	db := root.GetNode("db")
+	testDeepEqual(t, db.GetInt("port"), 5432)
+	testDeepEqual(t, root.GetDurationDefault(0, "cache.ttl").String(), "1h0m0s")
+	db.GetStringDefault("x", "user")
+	root.GetNodes("missing.*")
+
+	// scopes share the tracker
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scope := root.With(Args{"request.id": 1})
+			scope.GetString("request.id")
+			scope.GetString("db.host")
+			scope.GetNode("db").Get("password")
+		}()
+	}
+	wg.Wait()
+
+	unused, missing = root.AccessReport()
+	testDeepEqual(t, unused, []string{"legacy.url"})
+	testDeepEqual(t, missing, map[string]int{"db.user": 1, "missing.*": 1, "db.password": 10})
+	unused, _ = root.GetNode("db").AccessReport()
+	testDeepEqual(t, unused, []string{})
+
+	buf := bytes.Buffer{}
+	root.DumpWith(&buf, DumpOptions{MarkUnread: true})
+	testDeepEqual(t, buf.String(), "db.host=localhost\ndb.port=5432\ncache.ttl=1h\nlegacy.url=http://old.internal # unread\n")
+}
+
+func TestReadCounts(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "localhost")
+	root.SetKey("db.port", 5432)
+	root.SetKey("servers.a.port", 81)
+	root.SetKey("servers.b.port", 82)
+	root.AddNode("empty")
+	testTrue(t, root.ReadCounts() == nil)
+	testTrue(t, root.UnreadKeys() == nil)
+
+	root.TrackAccess(true)
+	testDeepEqual(t, root.ReadCounts(), map[string]int{})
+	testDeepEqual(t, root.UnreadKeys(), []string{"db.host", "db.port", "servers.a.port", "servers.b.port", "empty"})
+	testDeepEqual(t, NewRoot().TrackAccess(true).UnreadKeys(), []string{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			root.With().GetString("db.host")
+		}()
+	}
+	wg.Wait()
+	root.GetInt("db.port")
+	root.GetIntDefault(0, "db.port")
+	root.GetNodes("servers.*.port")
+	root.GetNode("db")
+
+	testDeepEqual(t, root.ReadCounts(), map[string]int{
+		"db":             1,
+		"db.host":        10,
+		"db.port":        2,
+		"servers.a.port": 1,
+		"servers.b.port": 1,
+	})
+	testDeepEqual(t, root.GetNode("servers").ReadCounts(), map[string]int{"servers": 1, "servers.a.port": 1, "servers.b.port": 1})
+	testDeepEqual(t, root.UnreadKeys(), []string{"empty"})
+	testDeepEqual(t, root.GetNode("db").UnreadKeys(), []string{})
+}
+
+func TestTrackAccess_Toggle(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "localhost")
+	root.SetKey("db.port", 5432)
+	scope := root.With(Args{"request.id": 1})
+
+	// enabling it on the root also applies to existing scopes, but only the
+	// root's own nodes are counted
+	root.TrackAccess(true)
+	scope.GetString("db.host")
+	scope.GetString("request.id")
+	testDeepEqual(t, root.ReadCounts(), map[string]int{"db.host": 1})
+	testDeepEqual(t, scope.ReadCounts(), map[string]int{})
+
+	// enabling it again keeps the counts
+	root.TrackAccess(true)
+	root.GetString("db.host")
+	testDeepEqual(t, root.ReadCounts(), map[string]int{"db.host": 2})
+
+	// scopes can track their own access
+	scope.TrackAccess(true)
+	scope.GetString("request.id")
+	scope.GetString("db.port")
+	testDeepEqual(t, scope.ReadCounts(), map[string]int{"request.id": 1})
+	testDeepEqual(t, root.ReadCounts(), map[string]int{"db.host": 2})
+	scope.TrackAccess(false)
+
+	// disabling it discards the counts
+	root.TrackAccess(false)
+	root.GetString("db.port")
+	testTrue(t, root.ReadCounts() == nil)
+	testTrue(t, scope.ReadCounts() == nil)
+	root.TrackAccess(true)
+	testDeepEqual(t, root.ReadCounts(), map[string]int{})
+
+	// toggling it while reading is safe
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			scope.GetString("db.host")
+		}()
+		go func(on bool) {
+			defer wg.Done()
+			root.TrackAccess(on)
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,118 @@
+package trix
+
+import (
+	"sort"
+	"sync"
+)
+
+// aliasSet keeps the aliases registered on a base root; they're looked up
+// from it by its scopes.
+type aliasSet struct {
+	mutex   sync.RWMutex
+	aliases map[string]alias // by the old path, joined
+}
+
+// alias is a registered alias, with its paths parsed.
+type alias struct {
+	from, to []string
+}
+
+// Alias registers an alias on the node's base root, like OnAccess, so that
+// getters looking up oldPath, or a path under it, also look up newPath, e.g.
+// with the alias "db.host" to "database.host", GetString("db.host") returns
+// the value of "database.host", if "db.host" isn't set, including on parent
+// scopes. Lookups with wildcards also use them, so GetNodes("db.*") includes
+// "database.host". Lookups that return a single node only use the alias if
+// the original path isn't found, while GetNodes, and the getters based on it,
+// return the nodes found with both paths, but only once for each old path,
+// preferring the original one, e.g. if both "db.host" and "database.host" are
+// set, only the former is returned. Paths are absolute, and can't have
+// wildcards. Aliases only work from oldPath to newPath, and are not applied
+// to the results of other aliases; see AliasBoth. An empty newPath removes
+// the alias. Return the original node.
+func (node *Node) Alias(oldPath, newPath string) *Node {
+	from := ParseKeys([]interface{}{oldPath})
+	to := ParseKeys([]interface{}{newPath})
+	if len(from) == 0 {
+		return node
+	}
+
+	set := node.aliasSet()
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	if len(to) == 0 {
+		delete(set.aliases, joinPath(from))
+	} else {
+		set.aliases[joinPath(from)] = alias{from: from, to: to}
+	}
+	return node
+}
+
+// AliasBoth registers aliases in both directions, like calling
+// Alias(oldPath, newPath) and Alias(newPath, oldPath), so that getters find
+// the values set with either name, whichever one is used. Return the
+// original node.
+func (node *Node) AliasBoth(oldPath, newPath string) *Node {
+	return node.Alias(oldPath, newPath).Alias(newPath, oldPath)
+}
+
+// aliasSet returns the base root's aliases, creating them if necessary.
+func (node *Node) aliasSet() *aliasSet {
+	state := node.baseRoot().ensureRootState()
+	if set := state.aliases.Load(); set != nil {
+		return set
+	}
+	state.aliases.CompareAndSwap(nil, &aliasSet{aliases: map[string]alias{}})
+	return state.aliases.Load()
+}
+
+// Aliases returns the aliases registered on the node's base root, mapping
+// each old path to its new one, or nil if there are none. See Alias.
+func (node *Node) Aliases() map[string]string {
+	set := node.baseRoot().rootState().aliases.Load()
+	if set == nil {
+		return nil
+	}
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	if len(set.aliases) == 0 {
+		return nil
+	}
+	aliases := make(map[string]string, len(set.aliases))
+	for from, alias := range set.aliases {
+		aliases[from] = joinPath(alias.to)
+	}
+	return aliases
+}
+
+// rewrite returns the aliases matching the absolute spec, sorted by their old
+// paths, with the spec rewritten to use their new paths: an alias matches if
+// its old path is the spec, or a prefix of it, with wildcards in the spec
+// matching any key.
+func (set *aliasSet) rewrite(spec []string) (aliases []alias, specs [][]string) {
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	var froms []string
+	for from, alias := range set.aliases {
+		if len(alias.from) > len(spec) {
+			continue
+		}
+		matches := true
+		for i, key := range alias.from {
+			if spec[i] != key && spec[i] != "*" {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			froms = append(froms, from)
+		}
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		alias := set.aliases[from]
+		aliases = append(aliases, alias)
+		specs = append(specs, append(append([]string{}, alias.to...), spec[len(alias.from):]...))
+	}
+	return aliases, specs
+}
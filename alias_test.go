@@ -0,0 +1,84 @@
+package trix
+
+import (
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("database.host", "db.example.com")
+	root.SetKey("database.port", 5432)
+	root.SetKey("server.port", 80)
+	root.Alias("db", "database").Alias("http.port", "server.port")
+	testDeepEqual(t, root.Aliases(), map[string]string{"db": "database", "http.port": "server.port"})
+
+	// old paths, and paths under them, resolve to the new ones
+	testDeepEqual(t, root.GetString("db.host"), "db.example.com")
+	testDeepEqual(t, root.GetInt("http.port"), 80)
+	testDeepEqual(t, root.GetNode("db.port"), root.GetNode("database.port"))
+	testDeepEqual(t, root.GetNode("db").GetInt("port"), 5432)
+	testDeepEqual(t, root.GetIntDefault(1, "db.port"), 5432)
+	_, err := root.TryGet("db.user")
+	testError(t, err, `key "db.user": node not found`)
+
+	// one way only
+	root.SetKey("http.host", "example.com")
+	testDeepEqual(t, root.GetString("server.host"), "")
+	root.Alias("server.host", "http.host")
+	testDeepEqual(t, root.GetString("server.host"), "example.com")
+
+	// the original path wins, and GetNodes returns each path once
+	root.SetKey("db.port", 6543)
+	testDeepEqual(t, root.GetInt("db.port"), 6543)
+	testDeepEqual(t, root.GetValues("db.*"), []Value{6543, "db.example.com"})
+	testDeepEqual(t, root.GetValues("db.port"), []Value{6543})
+	testDeepEqual(t, root.GetValues("database.port"), []Value{5432})
+	root.Alias("database", "database")
+	testDeepEqual(t, root.GetValues("database.*"), []Value{"db.example.com", 5432})
+
+	// across scopes
+	scope := root.With(Args{"database.user": "app"})
+	testDeepEqual(t, scope.GetString("db.user"), "app")
+	testDeepEqual(t, scope.GetString("db.host"), "db.example.com")
+	testDeepEqual(t, scope.GetInt("http.port"), 80)
+
+	// removing
+	root.Alias("database", "")
+	root.Alias("db", "")
+	root.Alias("", "x")
+	testDeepEqual(t, root.Aliases(), map[string]string{"http.port": "server.port", "server.host": "http.host"})
+	testDeepEqual(t, root.GetString("db.host"), "")
+	testDeepEqual(t, scope.GetString("db.user"), "")
+	testTrue(t, NewRoot().Aliases() == nil)
+}
+
+func TestAliasWildcards(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("database.host", "db.example.com")
+	root.SetKey("database.port", 5432)
+	root.SetKey("cache.host", "cache.example.com")
+	root.Alias("db.host", "database.host")
+
+	testDeepEqual(t, root.GetValues("db.*"), []Value{"db.example.com"})
+	testDeepEqual(t, root.GetValues("*.host"), []Value{"db.example.com", "cache.example.com"})
+	testDeepEqual(t, root.GetNode("*.host").GetString(), "db.example.com")
+	root.SetKey("db.host", "old.example.com")
+	testDeepEqual(t, root.GetValues("db.*"), []Value{"old.example.com"})
+	testDeepEqual(t, root.GetValues("*.host"), []Value{"db.example.com", "cache.example.com", "old.example.com"})
+}
+
+func TestAliasBoth(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("cache.host", "cache.example.com")
+	root.SetKey("memcache.port", 11211)
+	root.AliasBoth("cache", "memcache")
+	testDeepEqual(t, root.Aliases(), map[string]string{"cache": "memcache", "memcache": "cache"})
+	testDeepEqual(t, root.GetString("memcache.host"), "cache.example.com")
+	testDeepEqual(t, root.GetInt("cache.port"), 11211)
+	testDeepEqual(t, root.GetValues("cache.*"), []Value{"cache.example.com", 11211})
+	testDeepEqual(t, root.GetValues("memcache.*"), []Value{11211, "cache.example.com"})
+
+	root.Alias("memcache", "")
+	testDeepEqual(t, root.GetString("memcache.host"), "")
+	testDeepEqual(t, root.GetInt("cache.port"), 11211)
+}
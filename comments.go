@@ -0,0 +1,191 @@
+package trix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commentTracker is set on roots keeping comments; the comments themselves
+// are kept as the nodes' metadata (see MetaComments, MetaComment and
+// MetaFooter).
+type commentTracker struct{}
+
+// KeepComments makes the node's root keep the comments found in files loaded
+// from now on (see MergeFile and LoadOptions.KeepComments), so that WriteConf
+// can write them back. Comment and empty lines are attached to the entry that
+// follows them, and, unlike when comments are not kept, a whitespace followed
+// by "#" starts a trailing comment, instead of being part of the value.
+// Return the original node.
+func (node *Node) KeepComments() *Node {
+	if root := node.GetRoot(); root != nil && root.rootState().comments == nil {
+		root.ensureRootState().comments = &commentTracker{}
+	}
+	return node
+}
+
+// set attaches the comments to the node. The tracker may be nil.
+func (tracker *commentTracker) set(node *Node, comments []string, comment string) {
+	if tracker == nil || node == nil {
+		return
+	}
+	if len(comments) > 0 {
+		node.SetMeta(MetaComments, comments)
+	} else {
+		node.SetMeta(MetaComments, nil)
+	}
+	if comment != "" {
+		node.SetMeta(MetaComment, comment)
+	} else {
+		node.SetMeta(MetaComment, nil)
+	}
+}
+
+// setFooter sets the comments written after the node's entries.
+// The tracker may be nil.
+func (tracker *commentTracker) setFooter(node *Node, footer []string) {
+	if tracker != nil && len(footer) > 0 {
+		node.SetMeta(MetaFooter, footer)
+	}
+}
+
+// formatConfDuration formats a duration as accepted by parseDuration; it
+// fails if the duration is negative or has a fraction of a second.
+func formatConfDuration(d time.Duration) (string, bool) {
+	if d < 0 || d%time.Second != 0 {
+		return "", false
+	}
+	return FormatDuration(d), true
+}
+
+// formatConfValue returns the type and the string representation of the
+// value, such that parseValueType can convert it back. The type is empty
+// for strings and unknown types.
+func formatConfValue(v Value) (string, string) {
+	join := func(n int, format func(int) string) string {
+		parts := make([]string, n)
+		for i := range parts {
+			parts[i] = format(i)
+		}
+		return strings.Join(parts, ",")
+	}
+	formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	formatTime := func(t time.Time) string { return t.Format(time.RFC3339Nano) }
+
+	switch v := v.(type) {
+	case string:
+		return "", v
+	case int:
+		return "int", strconv.Itoa(v)
+	case float64:
+		return "float", formatFloat(v)
+	case bool:
+		return "bool", strconv.FormatBool(v)
+	case time.Duration:
+		if s, ok := formatConfDuration(v); ok {
+			return "duration", s
+		}
+		return "", v.String()
+	case time.Time:
+		return "time", formatTime(v)
+	case []string:
+		return "[]string", joinEsc(v, ",", `\`)
+	case *Encrypted:
+		return "enc", v.Ciphertext
+	case []int:
+		return "[]int", join(len(v), func(i int) string { return strconv.Itoa(v[i]) })
+	case []float64:
+		return "[]float", join(len(v), func(i int) string { return formatFloat(v[i]) })
+	case []bool:
+		return "[]bool", join(len(v), func(i int) string { return strconv.FormatBool(v[i]) })
+	case []time.Duration:
+		ok := true
+		s := join(len(v), func(i int) string {
+			s, valid := formatConfDuration(v[i])
+			ok = ok && valid
+			return s
+		})
+		if ok {
+			return "[]duration", s
+		}
+	case []time.Time:
+		return "[]time", join(len(v), func(i int) string { return formatTime(v[i]) })
+	}
+	return "", fmt.Sprint(v)
+}
+
+// formatConfKey returns the keys as written by WriteConf, escaped with
+// joinPath, or an error if MergeFile wouldn't read them back.
+func formatConfKey(keys []string) (string, error) {
+	key := joinPath(keys)
+	for _, k := range keys {
+		if k == "" {
+			return "", fmt.Errorf(`Bad key "%s": empty element`, key)
+		}
+	}
+	if strings.ContainsAny(key, "=\r\n") || key != strings.TrimSpace(key) ||
+		strings.HasPrefix(key, "#") || strings.HasPrefix(key, "include ") {
+		return "", fmt.Errorf(`Bad key "%s": can't be written`, key)
+	}
+	return key, nil
+}
+
+// needsQuoting returns whether a value written as is wouldn't be read back
+// by MergeFile, as it has leading or trailing whitespace, line breaks, or
+// what would start a trailing comment when keeping comments.
+func needsQuoting(value string) bool {
+	return value != strings.TrimSpace(value) || strings.ContainsAny(value, "\r\n") ||
+		reParseTrailingComment.MatchString(value)
+}
+
+// WriteConf writes the node's descendants that have values in the format
+// read by MergeFile, with keys relative to the node, escaped so that dots
+// and backslashes within them are read back. Values of known types are
+// written with their type, e.g. "port:int=8080", and strings that wouldn't be
+// read back as they are, like the ones with leading or trailing whitespace
+// or " #", are quoted, e.g. `motd:quoted=" hi # there"`. If the root is
+// keeping comments (see KeepComments), they are written back in place.
+// Nodes that can't be written, like ones with "=" in their keys, are errors,
+// in which case the output is incomplete.
+func (node *Node) WriteConf(w io.Writer) error {
+	if node == nil {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	depth := node.Depth()
+	for _, n := range node.FindFunc(func(n *Node) bool { return n != node && n.Value != nil }) {
+		keys := n.Path()[depth:]
+		key, err := formatConfKey(keys)
+		if err != nil {
+			return err
+		}
+		typ, value := formatConfValue(n.Value)
+		if needsQuoting(value) {
+			if typ != "" {
+				return fmt.Errorf(`Bad value for key "%s": can't be written`, key)
+			}
+			typ, value = "quoted", strconv.Quote(value)
+		} else if typ == "" && strings.Contains(keys[len(keys)-1], ":") {
+			// so that it's not read as a type
+			typ = "string"
+		}
+		if typ != "" {
+			key += ":" + typ
+		}
+
+		comments, _ := n.meta[MetaComments].([]string)
+		for _, line := range comments {
+			fmt.Fprintln(bw, line)
+		}
+		comment, _ := n.meta[MetaComment].(string)
+		fmt.Fprintf(bw, "%s=%s%s\n", key, value, comment)
+	}
+	footer, _ := node.meta[MetaFooter].([]string)
+	for _, line := range footer {
+		fmt.Fprintln(bw, line)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,156 @@
+package trix
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWriteConf(t *testing.T) {
+	// without comments, "#" is part of the value
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader("# database\ndb.host=localhost # primary\ndb.port:int=5432\n"), true), "")
+	testDeepEqual(t, root.Get("db.host"), "localhost # primary")
+	buf := bytes.Buffer{}
+	testError(t, root.GetNode("db").WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), "host:quoted=\"localhost # primary\"\nport:int=5432\n")
+	var nilNode *Node
+	testError(t, nilNode.WriteConf(&buf), "")
+
+	// round trip
+	root, err := LoadWith(LoadOptions{Filename: "testdata/comments.conf", KeepComments: true})
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("db.host"), "localhost")
+	testDeepEqual(t, root.Get("legacy.url"), "http://old.internal/#anchor")
+	testDeepEqual(t, root.Get("db.pool"), []string{"a,b", "c"})
+
+	original, err := os.ReadFile("testdata/comments.conf")
+	testError(t, err, "")
+	buf.Reset()
+	testError(t, root.WriteConf(&buf), "")
+	expected := strings.Replace(string(original), "\ninclude comments-include.conf\n", "# from the include\nfeature.flag=on\n\n", 1)
+	testDeepEqual(t, buf.String(), expected)
+
+	// edit, and compare with the golden file
+	root.SetKey("db.host", "db.internal")
+	root.SetKey("cache.ttl", 2*time.Hour)
+	root.Unset("legacy")
+	root.SetKey("added.key", 1.5)
+	golden, err := os.ReadFile("testdata/comments.golden")
+	testError(t, err, "")
+	buf.Reset()
+	testError(t, root.WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), string(golden))
+
+	// the result can be loaded back
+	reloaded := NewRoot().KeepComments()
+	testError(t, reloaded.MergeReader(bytes.NewReader(golden), true), "")
+	testTrue(t, reloaded.EqualUnordered(root))
+	written := NewRoot().KeepComments()
+	testError(t, written.MergeFile("testdata/comments.golden"), "")
+	buf.Reset()
+	testError(t, written.WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), string(golden))
+
+	// comments of deleted keys are dropped
+	written.Unset("db")
+	buf.Reset()
+	testError(t, written.WriteConf(&buf), "")
+	testTrue(t, !strings.Contains(buf.String(), "# database"))
+	testTrue(t, !strings.Contains(buf.String(), "# primary"))
+	testTrue(t, strings.HasPrefix(buf.String(), "# from the include\n"))
+	testDeepEqual(t, len(written.FindFunc(func(n *Node) bool {
+		_, found := n.Meta(MetaComment)
+		return found
+	})), 1)
+}
+
+func TestWriteConf_RoundTrip(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("plain", "value")
+	root.Set([]interface{}{"dotted", Key("a.b"), Key(`back\slash`)}, "x")
+	root.Set([]interface{}{Key("a:int")}, "not an int")
+	root.Set([]interface{}{Key(`a\,b`)}, "settings")
+	root.SetKey("spaces", "  padded\t")
+	root.SetKey("hash", "a # b")
+	root.SetKey("lines", "one\ntwo")
+	root.SetKey("quoted", `"as is"`)
+	root.SetKey("list", []string{"a,b", "c"})
+
+	buf := bytes.Buffer{}
+	testError(t, root.WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), `plain=value
+dotted.a\.b.back\\slash=x
+a:int:string=not an int
+a\\,b=settings
+spaces:quoted="  padded\t"
+hash:quoted="a # b"
+lines:quoted="one\ntwo"
+quoted="as is"
+list:[]string=a\,b,c
+`)
+
+	// read back, with and without keeping comments
+	for _, keep := range []bool{false, true} {
+		read := NewRoot()
+		if keep {
+			read.KeepComments()
+		}
+		testError(t, read.MergeReader(bytes.NewReader(buf.Bytes()), true), "")
+		testTrue(t, read.EqualUnordered(root))
+	}
+	fsys := fstest.MapFS{"written.conf": {Data: buf.Bytes()}}
+	loaded, err := LoadWith(LoadOptions{FS: fsys, Filename: "written.conf", Strict: true, KeepComments: true})
+	testError(t, err, "")
+	testTrue(t, loaded.EqualUnordered(root))
+
+	// trailing comments after quoted values
+	read := NewRoot().KeepComments()
+	testError(t, read.MergeReader(strings.NewReader(`motd:quoted=" hi # there " # greeting`), true), "")
+	testDeepEqual(t, read.Get("motd"), " hi # there ")
+	buf.Reset()
+	testError(t, read.WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), `motd:quoted=" hi # there " # greeting`+"\n")
+	read = NewRoot()
+	testError(t, read.MergeReader(strings.NewReader(`motd:quoted=" hi " # greeting`), true), "")
+	testDeepEqual(t, read.Get("motd"), " hi ")
+	testError(t, read.MergeReader(strings.NewReader(`motd:quoted=" hi " extra`), true), `line 1: Bad quoted string: " hi " extra`)
+	testError(t, read.MergeReader(strings.NewReader(`motd:quoted=hi`), true), `line 1: Bad quoted string: hi`)
+
+	// nodes that can't be written
+	for _, bad := range []struct {
+		keys  []interface{}
+		value Value
+		err   string
+	}{
+		{[]interface{}{Key("a=b")}, "x", `Bad key "a=b": can't be written`},
+		{[]interface{}{"a", Key("")}, "x", `Bad key "a.": empty element`},
+		{[]interface{}{Key(" a")}, "x", `Bad key " a": can't be written`},
+		{[]interface{}{Key("# a")}, "x", `Bad key "# a": can't be written`},
+		{[]interface{}{Key("include a")}, "x", `Bad key "include a": can't be written`},
+		{[]interface{}{"list"}, []string{"a #b"}, `Bad value for key "list": can't be written`},
+	} {
+		root := NewRoot()
+		root.Set(bad.keys, bad.value)
+		testError(t, root.WriteConf(&bytes.Buffer{}), bad.err)
+	}
+}
+
+func TestFormatConfValue(t *testing.T) {
+	for _, value := range []Value{
+		"text", 10, 3.25, true, 90 * time.Minute, 26*time.Hour + time.Second, time.Duration(0),
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		[]string{"a,b", "c"}, []string{`C:\dir\`, `a\,b`, ``}, []int{1, 2}, []float64{1.5, 2}, []bool{true, false},
+		[]time.Duration{time.Hour, time.Minute}, []time.Time{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+	} {
+		typ, s := formatConfValue(value)
+		parsed, err := parseValueType(typ, s)
+		testError(t, err, "")
+		testDeepEqual(t, parsed, value)
+	}
+	typ, s := formatConfValue(1500 * time.Millisecond)
+	testDeepEqual(t, typ+"="+s, "=1.5s")
+}
@@ -0,0 +1,166 @@
+package trix
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// ChangeKind is the kind of difference between two nodes.
+type ChangeKind byte
+
+const (
+	// Added means the node only exists on the other tree.
+	Added ChangeKind = iota
+
+	// Removed means the node only exists on the original tree.
+	Removed
+
+	// Changed means the node's value is different.
+	Changed
+
+	// FlagsChanged means the node's flags are different.
+	FlagsChanged
+
+	// KeyChanged means the compared nodes have different keys; this can only
+	// happen to the nodes initially compared.
+	KeyChanged
+
+	// Reordered means the node has the same children, but in a different
+	// order.
+	Reordered
+)
+
+// String returns the name of the change kind.
+func (kind ChangeKind) String() string {
+	switch kind {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case FlagsChanged:
+		return "flags"
+	case KeyChanged:
+		return "key"
+	case Reordered:
+		return "reordered"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", byte(kind))
+}
+
+// Change represents a difference between two trees.
+// For Added and Removed changes, New and Old are the *Node added or removed;
+// for Changed, they are the values; for FlagsChanged, the flags; for
+// KeyChanged, the keys; and for Reordered, the ChildKeys.
+type Change struct {
+	Kind ChangeKind
+	Path []string
+	Old  Value
+	New  Value
+}
+
+// compareNodes walks both nodes, calling emit for each difference found, and
+// stopping if it returns false. Children order is only considered if ordered
+// is true. The IsRoot flag is ignored. If visit is not nil, it's called for
+// each pair of nodes compared, and the walk stops if it returns false. Return
+// false if the walk was stopped.
+func compareNodes(a, b *Node, path []string, ordered bool, emit func(Change) bool, visit func() bool) bool {
+	if visit != nil && !visit() {
+		return false
+	}
+	if a == nil || b == nil {
+		if a == nil && b != nil {
+			return emit(Change{Kind: Added, Path: path, New: b})
+		} else if a != nil {
+			return emit(Change{Kind: Removed, Path: path, Old: a})
+		}
+		return true
+	}
+
+	if len(path) == 0 && a.Key != b.Key {
+		if !emit(Change{Kind: KeyChanged, Path: path, Old: a.Key, New: b.Key}) {
+			return false
+		}
+	}
+	if fa, fb := a.Flags&^IsRoot, b.Flags&^IsRoot; fa != fb {
+		if !emit(Change{Kind: FlagsChanged, Path: path, Old: fa, New: fb}) {
+			return false
+		}
+	}
+	if !reflect.DeepEqual(a.Value, b.Value) {
+		if !emit(Change{Kind: Changed, Path: path, Old: a.Value, New: b.Value}) {
+			return false
+		}
+	}
+
+	childPath := func(key string) []string {
+		return append(path[:len(path):len(path)], key)
+	}
+	sameKeys := len(a.ChildKeys) == len(b.ChildKeys)
+	for _, key := range a.ChildKeys {
+		other, found := b.Children[key]
+		sameKeys = sameKeys && found
+		if !compareNodes(a.Children[key], other, childPath(key), ordered, emit, visit) {
+			return false
+		}
+	}
+	for _, key := range b.ChildKeys {
+		if _, found := a.Children[key]; !found {
+			if !emit(Change{Kind: Added, Path: childPath(key), New: b.Children[key]}) {
+				return false
+			}
+		}
+	}
+
+	if ordered && sameKeys && !reflect.DeepEqual(a.ChildKeys, b.ChildKeys) {
+		return emit(Change{Kind: Reordered, Path: path, Old: a.ChildKeys, New: b.ChildKeys})
+	}
+	return true
+}
+
+// Equal returns whether both nodes have the same key, flags (other than
+// IsRoot) and deeply-equal values, and their children are equal, in the same
+// order. Two nil nodes are equal.
+func (node *Node) Equal(other *Node) bool {
+	return compareNodes(node, other, nil, true, func(Change) bool { return false }, nil)
+}
+
+// EqualUnordered is like Equal, but the order of children is ignored.
+func (node *Node) EqualUnordered(other *Node) bool {
+	return compareNodes(node, other, nil, false, func(Change) bool { return false }, nil)
+}
+
+// Diff returns the list of changes needed to turn the node into the other;
+// the list is empty if they're Equal. Paths are relative to the nodes.
+func (node *Node) Diff(other *Node) []Change {
+	changes := []Change{}
+	compareNodes(node, other, nil, true, func(change Change) bool {
+		changes = append(changes, change)
+		return true
+	}, nil)
+	return changes
+}
+
+// ctxCheckInterval is the number of nodes visited between checks of the
+// context, in long walks.
+const ctxCheckInterval = 1024
+
+// DiffCtx is like Diff, but periodically checks the context while walking
+// the nodes, and returns its error, and no changes, if it's done.
+func (node *Node) DiffCtx(ctx context.Context, other *Node) ([]Change, error) {
+	changes := []Change{}
+	visited := 0
+	completed := compareNodes(node, other, nil, true, func(change Change) bool {
+		changes = append(changes, change)
+		return true
+	}, func() bool {
+		visited++
+		return visited%ctxCheckInterval != 0 || ctx.Err() == nil
+	})
+	if !completed {
+		return nil, ctx.Err()
+	}
+	return changes, nil
+}
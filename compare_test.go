@@ -0,0 +1,128 @@
+package trix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	a := NewRoot()
+	a.SetKey("x.1", "one")
+	a.SetKey("x.2", []string{"a", "b"})
+	a.SetKey("y", 2)
+	b := NewRoot()
+	b.SetKey("y", 2)
+	b.SetKey("x.2", []string{"a", "b"})
+	b.SetKey("x.1", "one")
+
+	testTrue(t, !a.Equal(b))
+	testTrue(t, a.EqualUnordered(b))
+	b.SortRecursively()
+	a.SortRecursively()
+	testTrue(t, a.Equal(b))
+	testDeepEqual(t, a.Diff(b), []Change{})
+
+	// roots and non-roots with the same contents are equal
+	c := NewNode("")
+	c.Merge(a.GetNode("x"))
+	c.Merge(a.GetNode("y"))
+	testTrue(t, a.Equal(c))
+
+	b.SetKey("x.2", []string{"a", "c"})
+	b.GetNode("x").Flags = ForceMap
+	b.SetKey("z", 1)
+	b.Unset("y")
+	testTrue(t, !a.Equal(b))
+	testTrue(t, !a.EqualUnordered(b))
+	testEqualString(t, a.Diff(b), `[{flags [x] 0 1} {changed [x 2] [a b] [a c]} {removed [y] {} <nil>} {added [z] <nil> {}}]`)
+
+	var nilNode *Node
+	testTrue(t, nilNode.Equal(nil))
+	testTrue(t, !nilNode.Equal(a))
+	testTrue(t, !a.Equal(nil))
+	testDeepEqual(t, len(nilNode.Diff(a)), 1)
+	testTrue(t, !NewNode("a").Equal(NewNode("b")))
+	testDeepEqual(t, NewNode("a").Diff(NewNode("b")), []Change{{Kind: KeyChanged, Old: "a", New: "b"}})
+}
+
+func TestEqual_Diff(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	keys := []string{"a", "b", "c", "1", "2"}
+	randomPath := func() string {
+		path := keys[random.Intn(len(keys))]
+		for random.Intn(2) == 0 {
+			path += "." + keys[random.Intn(len(keys))]
+		}
+		return path
+	}
+	mutate := func(root *Node) {
+		switch random.Intn(5) {
+		case 0:
+			root.SetKey(randomPath(), random.Intn(3))
+		case 1:
+			root.Unset(randomPath())
+		case 2:
+			if node := root.GetNode(randomPath()); node != nil {
+				node.Flags ^= ForceArray
+			}
+		case 3:
+			root.SetKey(randomPath(), []int{random.Intn(2)})
+		case 4:
+			root.SortRecursively()
+		}
+	}
+
+	for i := 0; i < 500; i++ {
+		a := NewRoot()
+		for j := random.Intn(10); j > 0; j-- {
+			mutate(a)
+		}
+		b := NewRoot()
+		b.Merge(a) // clone
+		b = b.Children[""]
+		for j := random.Intn(3); j > 0; j-- {
+			mutate(b)
+		}
+
+		diff := a.Diff(b)
+		unordered := 0
+		for _, change := range diff {
+			if change.Kind != Reordered {
+				unordered++
+			}
+		}
+		if a.Equal(b) != (len(diff) == 0) || a.EqualUnordered(b) != (unordered == 0) {
+			t.Fatalf("Equal/Diff mismatch for %v and %v: %v", a, b, diff)
+		}
+		testDeepEqual(t, b.Diff(b), []Change{})
+	}
+	testEqualString(t, fmt.Sprint(Added, Removed, Changed, FlagsChanged, KeyChanged, Reordered), "added removed changed flags key reordered")
+}
+
+func TestDiffCtx(t *testing.T) {
+	a, b := NewRoot(), NewRoot()
+	for i := 0; i < 3*ctxCheckInterval; i++ {
+		a.Set([]interface{}{"items", i}, i)
+		b.Set([]interface{}{"items", i}, i)
+	}
+	b.SetKey("items.7", "seven")
+
+	changes, err := a.DiffCtx(context.Background(), b)
+	testError(t, err, "")
+	testDeepEqual(t, changes, a.Diff(b))
+	testDeepEqual(t, len(changes), 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	changes, err = a.DiffCtx(ctx, b)
+	testTrue(t, errors.Is(err, context.Canceled))
+	testTrue(t, changes == nil)
+
+	// small trees are compared without checking the context
+	changes, err = NewRoot().DiffCtx(ctx, NewRoot())
+	testError(t, err, "")
+	testDeepEqual(t, changes, []Change{})
+}
@@ -0,0 +1,384 @@
+package trix
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// These convert node values like the Try getters do.
+
+func toInt(v Value) (int, error) {
+	if castd, ok := v.(int); ok {
+		return castd, nil
+	}
+	return parseInt(v)
+}
+
+func toInt64(v Value) (int64, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	}
+	return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+}
+
+func toUint(v Value) (uint64, error) {
+	switch v := v.(type) {
+	case uint64:
+		return v, nil
+	case uint:
+		return uint64(v), nil
+	case int:
+		if v < 0 {
+			return 0, fmt.Errorf("Negative value: %d", v)
+		}
+		return uint64(v), nil
+	}
+	s := fmt.Sprint(v)
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("Negative value: %s", s)
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func toSize(v Value) (int64, error) {
+	if castd, ok := v.(int64); ok && castd >= 0 {
+		return castd, nil
+	}
+	return ParseSize(fmt.Sprint(v))
+}
+
+func toFloat(v Value) (float64, error) {
+	if castd, ok := v.(float64); ok {
+		return castd, nil
+	}
+	return strconv.ParseFloat(fmt.Sprint(v), 64)
+}
+
+func toFloat32(v Value) (float32, error) {
+	switch v := v.(type) {
+	case float32:
+		return v, nil
+	case float64:
+		if math.Abs(v) > math.MaxFloat32 && !math.IsInf(v, 0) {
+			return 0, fmt.Errorf("Out of float32 range: %v", v)
+		}
+		return float32(v), nil
+	}
+	f, err := strconv.ParseFloat(fmt.Sprint(v), 32)
+	if err != nil {
+		return 0, err
+	}
+	return float32(f), nil
+}
+
+func toBool(v Value) (bool, error) {
+	if castd, ok := v.(bool); ok {
+		return castd, nil
+	}
+	return parseBool(v)
+}
+
+func toDuration(v Value) (time.Duration, error) {
+	if castd, ok := v.(time.Duration); ok {
+		return castd, nil
+	}
+	return parseDuration(v)
+}
+
+func toTime(v Value) (time.Time, error) {
+	if castd, ok := v.(time.Time); ok {
+		return castd, nil
+	}
+	return parseTime(v)
+}
+
+// parseURL parses the value as a URL, which may be relative. Errors don't
+// include the value, since it could have a password.
+func parseURL(v Value) (*url.URL, error) {
+	switch castd := v.(type) {
+	case *url.URL:
+		if castd != nil {
+			u := *castd
+			return &u, nil
+		}
+	case url.URL:
+		return &castd, nil
+	}
+	u, err := url.Parse((&Node{Value: v}).internalStringValue())
+	if urlErr, ok := err.(*url.Error); ok {
+		return nil, fmt.Errorf("Bad URL: %w", urlErr.Err)
+	}
+	return u, err
+}
+
+func toURL(v Value) (*url.URL, error) {
+	u, err := parseURL(v)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme == "" {
+		return nil, fmt.Errorf(`Bad URL "%s": missing scheme`, u.Redacted())
+	} else if u.Host == "" {
+		return nil, fmt.Errorf(`Bad URL "%s": missing host`, u.Redacted())
+	}
+	return u, nil
+}
+
+// toIP converts the value to an IP address; IPv4 addresses, including
+// IPv4-mapped IPv6 ones like "::ffff:10.0.0.1", use the 4-byte form.
+func toIP(v Value) (net.IP, error) {
+	ip, ok := v.(net.IP)
+	if !ok {
+		s := strings.TrimSpace((&Node{Value: v}).internalStringValue())
+		if ip = net.ParseIP(s); ip == nil {
+			return nil, fmt.Errorf(`Bad IP address "%s"`, s)
+		}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return ip, nil
+}
+
+func toCIDR(v Value) (*net.IPNet, error) {
+	if castd, ok := v.(*net.IPNet); ok && castd != nil {
+		return castd, nil
+	}
+	s := strings.TrimSpace((&Node{Value: v}).internalStringValue())
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf(`Bad CIDR "%s"`, s)
+	}
+	return ipNet, nil
+}
+
+// maxCachedRegexps is the most regexps kept by regexpCache; when it's full,
+// it's emptied before adding another one.
+const maxCachedRegexps = 1000
+
+// regexpCache keeps the regexps compiled by toRegexp, by their patterns.
+var regexpCache = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: map[string]*regexp.Regexp{}}
+
+// toRegexp compiles the node's value as a regexp, using the cached one if the
+// same pattern was compiled before.
+func toRegexp(node *Node) (*regexp.Regexp, error) {
+	if castd, ok := node.Value.(*regexp.Regexp); ok && castd != nil {
+		return castd, nil
+	}
+	pattern := node.internalStringValue()
+	regexpCache.Lock()
+	re, ok := regexpCache.compiled[pattern]
+	regexpCache.Unlock()
+	if ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Lock()
+	if len(regexpCache.compiled) >= maxCachedRegexps {
+		regexpCache.compiled = map[string]*regexp.Regexp{}
+	}
+	regexpCache.compiled[pattern] = re
+	regexpCache.Unlock()
+	return re, nil
+}
+
+// toBytes decodes the node's value, unless it's already a []byte: values with
+// a "hex:" prefix as hex, and other values as base64, standard or URL-safe,
+// padded or not. Encrypted values are decrypted first. Errors don't include
+// the value, which is often a secret.
+func toBytes(node *Node) ([]byte, error) {
+	if castd, ok := node.Value.([]byte); ok {
+		return castd, nil
+	}
+	s, err := node.internalPlainString()
+	if err != nil {
+		return nil, err
+	}
+	if encoded, found := strings.CutPrefix(s, "hex:"); found {
+		b, err := hex.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Bad hex value")
+		}
+		return b, nil
+	}
+	for _, encoding := range []*base64.Encoding{
+		base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding,
+	} {
+		if b, err := encoding.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("Bad base64 value")
+}
+
+func toString(v Value) (string, error) {
+	return (&Node{Value: v}).internalStringValue(), nil
+}
+
+// toSlice converts the node's value to a slice: values that already are one
+// are returned as is, other values are split on unescaped commas, and, if the
+// node has no value, its children's values are used, as long as they all have
+// numeric keys and no children of their own, like the ones added with Push.
+// Conversion errors name the element that failed, and its index.
+func toSlice[T any](node *Node, convert func(Value) (T, error)) ([]T, error) {
+	if castd, ok := node.Value.([]T); ok {
+		return castd, nil
+	}
+
+	var values []Value
+	if node.Value != nil {
+		for _, s := range splitEsc(node.internalStringValue(), ",", `\`) {
+			values = append(values, s)
+		}
+	} else {
+		for _, key := range node.ChildKeys {
+			if _, err := strconv.Atoi(key); err != nil {
+				return nil, fmt.Errorf(`Not a list: key "%s" is not numeric`, key)
+			} else if len(node.Children[key].Children) > 0 {
+				return nil, fmt.Errorf(`Not a list: element "%s" has children`, key)
+			}
+			values = append(values, node.Children[key].Value)
+		}
+	}
+
+	result := make([]T, len(values))
+	for i, v := range values {
+		var err error
+		if result[i], err = convert(v); err != nil {
+			return nil, fmt.Errorf(`Bad element %d "%v": %w`, i, v, err)
+		}
+	}
+	return result, nil
+}
+
+// internalConvert sets target, which must be a pointer to one of the types
+// supported by GetAs, to the node's value.
+func internalConvert(node *Node, target interface{}) (err error) {
+	switch target := target.(type) {
+	case *string:
+		*target, err = node.internalPlainString()
+	case *int:
+		*target, err = toInt(node.Value)
+	case *int64:
+		*target, err = toInt64(node.Value)
+	case *uint64:
+		*target, err = toUint(node.Value)
+	case *float64:
+		*target, err = toFloat(node.Value)
+	case *float32:
+		*target, err = toFloat32(node.Value)
+	case *bool:
+		*target, err = toBool(node.Value)
+	case *time.Duration:
+		*target, err = toDuration(node.Value)
+	case *time.Time:
+		*target, err = toTime(node.Value)
+	case **url.URL:
+		*target, err = toURL(node.Value)
+	case *net.IP:
+		*target, err = toIP(node.Value)
+	case **net.IPNet:
+		*target, err = toCIDR(node.Value)
+	case **regexp.Regexp:
+		*target, err = toRegexp(node)
+	case *[]string:
+		*target, err = toSlice(node, toString)
+	case *[]int:
+		*target, err = toSlice(node, toInt)
+	case *[]int64:
+		*target, err = toSlice(node, toInt64)
+	case *[]uint64:
+		*target, err = toSlice(node, toUint)
+	case *[]float64:
+		*target, err = toSlice(node, toFloat)
+	case *[]bool:
+		*target, err = toSlice(node, toBool)
+	case *[]time.Duration:
+		*target, err = toSlice(node, toDuration)
+	case *[]time.Time:
+		*target, err = toSlice(node, toTime)
+	case *[]net.IP:
+		*target, err = toSlice(node, toIP)
+	default:
+		ptr := reflect.ValueOf(target)
+		if ptr.Kind() == reflect.Ptr && !ptr.IsNil() && node.Value != nil {
+			if v := reflect.ValueOf(node.Value); v.Type().AssignableTo(ptr.Elem().Type()) {
+				ptr.Elem().Set(v)
+				return nil
+			}
+		}
+		typ := reflect.TypeOf(target)
+		if typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		return fmt.Errorf("Unsupported type: %v", typ)
+	}
+	return err
+}
+
+// GetInto sets target, which must be a pointer to one of the types supported
+// by GetAs, to the value of the first node matching the spec; if it can't
+// find a value or if there's a conversion error, an error is returned.
+func (node *Node) GetInto(target interface{}, keys ...interface{}) error {
+	_, err := tryGetAs(node, keys, func(found *Node) (struct{}, error) {
+		return struct{}{}, internalConvert(found, target)
+	})
+	return err
+}
+
+// GetAs returns the value of the first node matching the spec, converted to
+// T like the corresponding Try getter does; if it can't find a value or if
+// there's a conversion error, an error is returned instead.
+//
+// T can be string, int, int64, uint64, float64, float32, bool, time.Duration,
+// time.Time or net.IP, a slice of one of those, or *url.URL, *net.IPNet or
+// *regexp.Regexp; for other types, the value is returned if it can be
+// assigned to T, e.g. a struct set with SetKey, or else an error naming T is
+// returned. Slices are returned as is if the value already is one, or else
+// split on unescaped commas, e.g. "a,b\,c" is []string{"a", "b,c"}; if the
+// node has no value, its children's values are used instead.
+func GetAs[T any](node *Node, keys ...interface{}) (T, error) {
+	var result T
+	err := node.GetInto(&result, keys...)
+	return result, err
+}
+
+// GetAsDefault returns the value of the first node matching the spec,
+// converted to T as done by GetAs. If no node matches, or converting fails,
+// return the default value instead.
+func GetAsDefault[T any](node *Node, def T, keys ...interface{}) T {
+	return getDefault(node, def, keys, true, func(found *Node) (T, error) {
+		var result T
+		err := internalConvert(found, &result)
+		return result, err
+	})
+}
+
+// MustGetAs returns the value of the first node matching the spec, converted
+// to T as done by GetAs. If no node matches, or converting fails, panic.
+// This is most suited for intializations.
+func MustGetAs[T any](node *Node, keys ...interface{}) T {
+	val, err := GetAs[T](node, keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
@@ -0,0 +1,137 @@
+package trix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestGetAs(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("text", "hello")
+	root.SetKey("int", 42)
+	root.SetKey("intStr", "-42")
+	root.SetKey("float", 1.5)
+	root.SetKey("floatStr", "2.5")
+	root.SetKey("bool", true)
+	root.SetKey("boolStr", "on")
+	root.SetKey("duration", time.Hour)
+	root.SetKey("durationStr", "1d2h")
+	root.SetKey("time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	root.SetKey("timeStr", "2020-01-02T03:04:05Z")
+	root.SetKey("empty", "")
+	root.AddNode("branch.a")
+
+	// same results as the concrete getters, for every key (and a missing one)
+	sameError := func(a, b error) bool { return fmt.Sprint(a) == fmt.Sprint(b) }
+	for _, key := range append(root.ChildKeys, "missing") {
+		s, err := GetAs[string](root, key)
+		s2, err2 := root.TryGetString(key)
+		testDeepEqual(t, s, s2)
+		testTrue(t, sameError(err, err2))
+
+		i, err := GetAs[int](root, key)
+		i2, err2 := root.TryGetInt(key)
+		testDeepEqual(t, i, i2)
+		testTrue(t, sameError(err, err2))
+
+		f, err := GetAs[float64](root, key)
+		f2, err2 := root.TryGetFloat(key)
+		testDeepEqual(t, f, f2)
+		testTrue(t, sameError(err, err2))
+
+		b, err := GetAs[bool](root, key)
+		b2, err2 := root.TryGetBool(key)
+		testDeepEqual(t, b, b2)
+		testTrue(t, sameError(err, err2))
+
+		d, err := GetAs[time.Duration](root, key)
+		d2, err2 := root.TryGetDuration(key)
+		testDeepEqual(t, d, d2)
+		testTrue(t, sameError(err, err2))
+
+		tm, err := GetAs[time.Time](root, key)
+		tm2, err2 := root.TryGetTime(key)
+		testDeepEqual(t, tm, tm2)
+		testTrue(t, sameError(err, err2))
+	}
+
+	i64, err := GetAs[int64](root, "intStr")
+	testError(t, err, "")
+	testDeepEqual(t, i64, int64(-42))
+
+	// slices
+	root.SetKey("list", []int{1, 2})
+	root.SetKey("csv", `a,b\,c`)
+	root.FillKey("items", "1")
+	root.FillKey("items", 2)
+	root.SetKey("map.a", 1)
+	ints, err := GetAs[[]int](root, "list")
+	testError(t, err, "")
+	testDeepEqual(t, ints, []int{1, 2})
+	strs, err := GetAs[[]string](root, "csv")
+	testError(t, err, "")
+	testDeepEqual(t, strs, []string{"a", "b,c"})
+	ints, err = GetAs[[]int](root, "items")
+	testError(t, err, "")
+	testDeepEqual(t, ints, []int{1, 2})
+	_, err = GetAs[[]string](root, "map")
+	testError(t, err, `key "map": Not a list: key "a" is not numeric`)
+	_, err = GetAs[[]int](root, "csv")
+	testError(t, err, `key "csv": Bad element 0 "a": strconv.ParseInt: parsing "a": invalid syntax`)
+	testDeepEqual(t, GetAsDefault(root, []bool{true}, "missing"), []bool{true})
+
+	// network and regexp types
+	root.SetKey("ip", "10.0.0.1")
+	root.SetKey("ips", "10.0.0.1,::1")
+	root.SetKey("cidr", "10.0.0.0/8")
+	root.SetKey("pattern", "^a+$")
+	ip, err := GetAs[net.IP](root, "ip")
+	testError(t, err, "")
+	testTrue(t, ip.Equal(root.GetIP("ip")))
+	testDeepEqual(t, MustGetAs[[]net.IP](root, "ips"), root.GetIPSlice("ips"))
+	testDeepEqual(t, MustGetAs[*net.IPNet](root, "cidr"), root.GetCIDR("cidr"))
+	testTrue(t, MustGetAs[*regexp.Regexp](root, "pattern") == root.GetRegexp("pattern"))
+	_, err = GetAs[net.IP](root, "text")
+	testTrue(t, sameError(err, func() error { _, err := root.TryGetIP("text"); return err }()))
+
+	// GetInto
+	var d time.Duration
+	testError(t, root.GetInto(&d, "durationStr"), "")
+	testDeepEqual(t, d, 26*time.Hour)
+
+	// unsupported types
+	_, err = GetAs[Args](root, "text")
+	testError(t, err, `key "text": Unsupported type: trix.Args`)
+	testError(t, root.GetInto(d, "text"), `key "text": Unsupported type: time.Duration`)
+
+	// other types are returned if assignable
+	type endpoint struct{ Host string }
+	root.SetKey("endpoint", endpoint{"a"})
+	root.SetKey("buf", &bytes.Buffer{})
+	ep, err := GetAs[endpoint](root, "endpoint")
+	testError(t, err, "")
+	testDeepEqual(t, ep, endpoint{"a"})
+	_, err = GetAs[io.Writer](root, "buf")
+	testError(t, err, "")
+	_, err = GetAs[io.Writer](root, "text")
+	testError(t, err, `key "text": Unsupported type: io.Writer`)
+	_, err = GetAs[endpoint](root, "items")
+	testError(t, err, `key "items": Unsupported type: trix.endpoint`)
+
+	// Default and Must variants
+	testDeepEqual(t, GetAsDefault(root, 7, "text"), 7)
+	testDeepEqual(t, GetAsDefault(root, 7, "int"), 42)
+	testDeepEqual(t, GetAsDefault(root, time.Minute, "missing"), time.Minute)
+	testDeepEqual(t, GetAsDefault(root, endpoint{"b"}, "text"), endpoint{"b"})
+	testDeepEqual(t, GetAsDefault(root, endpoint{"b"}, "endpoint"), endpoint{"a"})
+	testDeepEqual(t, MustGetAs[float64](root, "floatStr"), 2.5)
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "missing": node not found`)
+	}()
+	MustGetAs[int](root, "missing")
+}
@@ -0,0 +1,54 @@
+package trix
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// Current holds the current version of a tree that's replaced as a whole,
+// like the ones reloaded by ReloadOnSignal and fetched by a RemoteSource, so
+// that other goroutines can keep reading it while it's replaced: Load returns
+// the latest version, and versions aren't changed once they're replaced, so
+// readers holding an older one, or scopes created from it, see it unchanged.
+// Each version is a new root that keeps the state of the first one, like its
+// hooks, aliases, limits and trackers; access counts start over with each
+// version. See NewCurrent.
+type Current struct {
+	tree atomic.Pointer[Node]
+}
+
+// NewCurrent returns a Current whose first version is root, which should be a
+// root, or a new one if it's nil. Like any tree, root shouldn't be changed
+// while other goroutines read it.
+func NewCurrent(root *Node) *Current {
+	if root == nil {
+		root = NewRoot()
+	}
+	current := &Current{}
+	current.tree.Store(root)
+	return current
+}
+
+// Load returns the current version of the tree.
+func (current *Current) Load() *Node {
+	return current.tree.Load()
+}
+
+// next returns a new, empty root with the state of the current version, to
+// build the next one.
+func (current *Current) next() *Node {
+	root := NewRoot()
+	atomic.StorePointer(&root.state, unsafe.Pointer(current.Load().ensureRootState()))
+	return root
+}
+
+// replace makes root, which must not be changed afterwards, the current
+// version, giving it the state of the previous one.
+func (current *Current) replace(root *Node) {
+	state := current.Load().ensureRootState()
+	atomic.StorePointer(&root.state, unsafe.Pointer(state))
+	if state.access.Load() != nil {
+		state.access.Store(&accessTracker{})
+	}
+	current.tree.Store(root)
+}
@@ -0,0 +1,86 @@
+package trix
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Encrypted is the value of the nodes set from "key:enc=<scheme>:<ciphertext>"
+// entries, e.g. "db.password:enc=vault:v1:AbC...". Its plaintext is only
+// returned by the string getters, like GetString, TryGetString and their
+// variants, by GetAs[string] and, decoded, by GetBytes and TryGetBytes, which
+// decrypt it the first time with the function registered for the scheme (see
+// RegisterDecrypter) and then keep the result; everything else, including
+// the serialisers, only ever sees the ciphertext.
+type Encrypted struct {
+	// Ciphertext is the value as written, including the scheme.
+	Ciphertext string
+
+	mutex     sync.Mutex
+	plaintext *string
+}
+
+// decrypters has the functions registered with RegisterDecrypter.
+var decrypters sync.Map // scheme string -> func(ciphertext string) (string, error)
+
+// RegisterDecrypter registers fn to decrypt the Encrypted values with the
+// scheme, replacing any previous one; fn gets the ciphertext that follows the
+// scheme and its colon, e.g. "v1:AbC..." for "vault:v1:AbC...". A nil fn
+// removes it. The errors returned by fn are returned by the getters, so they
+// shouldn't include the ciphertext.
+func RegisterDecrypter(scheme string, fn func(ciphertext string) (string, error)) {
+	if fn == nil {
+		decrypters.Delete(scheme)
+	} else {
+		decrypters.Store(scheme, fn)
+	}
+}
+
+// String returns the ciphertext.
+func (e *Encrypted) String() string {
+	return e.Ciphertext
+}
+
+// MarshalJSON returns the ciphertext, as a JSON string.
+func (e *Encrypted) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Ciphertext)
+}
+
+// decrypt returns the plaintext, decrypting it if necessary.
+func (e *Encrypted) decrypt() (string, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.plaintext != nil {
+		return *e.plaintext, nil
+	}
+
+	scheme, ciphertext, found := strings.Cut(e.Ciphertext, ":")
+	if !found {
+		return "", fmt.Errorf("Missing encryption scheme")
+	}
+	fn, found := decrypters.Load(scheme)
+	if !found {
+		return "", fmt.Errorf(`Unknown encryption scheme "%s"`, scheme)
+	}
+	plaintext, err := fn.(func(string) (string, error))(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	e.plaintext = &plaintext
+	return plaintext, nil
+}
+
+// internalPlainString returns the node's value as a string, like
+// internalStringValue, but decrypting Encrypted values.
+func (node *Node) internalPlainString() (string, error) {
+	if e, ok := node.Value.(*Encrypted); ok && e != nil {
+		s, err := e.decrypt()
+		if err != nil {
+			return "", fmt.Errorf("Cannot decrypt value: %w", err)
+		}
+		return s, nil
+	}
+	return node.internalStringValue(), nil
+}
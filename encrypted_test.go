@@ -0,0 +1,104 @@
+package trix
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// xorCrypt is a trivial reversible "encryption", for tests.
+func xorCrypt(b []byte) []byte {
+	result := make([]byte, len(b))
+	for i := range b {
+		result[i] = b[i] ^ 0x5a
+	}
+	return result
+}
+
+func xorEncrypt(plaintext string) string {
+	return "xor:" + base64.StdEncoding.EncodeToString(xorCrypt([]byte(plaintext)))
+}
+
+func TestEncrypted(t *testing.T) {
+	calls := 0
+	RegisterDecrypter("xor", func(ciphertext string) (string, error) {
+		calls++
+		b, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", errors.New("bad xor data")
+		}
+		return string(xorCrypt(b)), nil
+	})
+	defer RegisterDecrypter("xor", nil)
+
+	secret := xorEncrypt("s3cr3t")
+	conf := "db.password:enc=" + secret + "\ndb.broken:enc=xor:!!!\ndb.other:enc=rot13:fRpErG\ndb.plain:enc=noscheme\n"
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader(conf), true), "")
+	testDeepEqual(t, root.Get("db.password").(*Encrypted).Ciphertext, secret)
+
+	// decrypted once, when read as a string
+	testDeepEqual(t, calls, 0)
+	testDeepEqual(t, root.GetString("db.password"), "s3cr3t")
+	s, err := root.TryGetString("db.password")
+	testError(t, err, "")
+	testDeepEqual(t, s, "s3cr3t")
+	testDeepEqual(t, root.MustGetString("db.password"), "s3cr3t")
+	testDeepEqual(t, root.GetStringDefault("x", "db.password"), "s3cr3t")
+	testDeepEqual(t, root.GetStringFirst("db.missing", "db.password"), "s3cr3t")
+	testDeepEqual(t, GetAsDefault(root, "x", "db.password"), "s3cr3t")
+	testDeepEqual(t, calls, 1)
+
+	// failures are not cached, and don't include the ciphertext
+	_, err = root.TryGetString("db.broken")
+	testError(t, err, `key "db.broken": Cannot decrypt value: bad xor data`)
+	_, err = root.TryGetString("db.broken")
+	testDeepEqual(t, calls, 3)
+	_, err = root.TryGetString("db.other")
+	testError(t, err, `key "db.other": Cannot decrypt value: Unknown encryption scheme "rot13"`)
+	_, err = GetAs[string](root, "db.plain")
+	testError(t, err, `key "db.plain": Cannot decrypt value: Missing encryption scheme`)
+	testDeepEqual(t, root.GetString("db.other"), "")
+	testDeepEqual(t, root.GetStringDefault("none", "db.other"), "none")
+	func() {
+		defer func() {
+			testDeepEqual(t, recover(), `Required conf key "db.broken": Cannot decrypt value: bad xor data`)
+		}()
+		root.MustGetString("db.broken")
+	}()
+
+	// the plaintext is never serialised
+	buf := bytes.Buffer{}
+	root.Dump(&buf, false)
+	testTrue(t, strings.Contains(buf.String(), "db.password="+secret+"\n"))
+	testTrue(t, strings.Contains(root.String(), "password="+secret))
+	byt, err := json.Marshal(root.GetNode("db"))
+	testError(t, err, "")
+	testTrue(t, strings.Contains(string(byt), `"password":"`+secret+`"`))
+	buf.Reset()
+	testError(t, root.WriteConf(&buf), "")
+	testTrue(t, strings.Contains(buf.String(), "db.password:enc="+secret+"\n"))
+	for _, out := range []string{buf.String(), string(byt), root.String()} {
+		testTrue(t, !strings.Contains(out, "s3cr3t"))
+	}
+
+	// converting values doesn't store the plaintext
+	root.GetNodes("db.*").ValuesToString()
+	testDeepEqual(t, root.Get("db.password").(*Encrypted).Ciphertext, secret)
+	byt, err = json.Marshal(root)
+	testError(t, err, "")
+	testTrue(t, !strings.Contains(string(byt), "s3cr3t"))
+	buf.Reset()
+	root.Dump(&buf, false)
+	testTrue(t, !strings.Contains(buf.String(), "s3cr3t"))
+	buf.Reset()
+	testError(t, root.WriteConf(&buf), "")
+
+	// and the written conf can be read back
+	reloaded := NewRoot()
+	testError(t, reloaded.MergeReader(&buf, true), "")
+	testDeepEqual(t, reloaded.GetString("db.password"), "s3cr3t")
+}
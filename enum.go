@@ -0,0 +1,74 @@
+package trix
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnumOptions changes how the enum getters ending in With, like
+// TryGetEnumWith, check values.
+type EnumOptions struct {
+	// Allowed are the values accepted.
+	Allowed []string
+
+	// IgnoreCase makes the comparison case-insensitive; the allowed value is
+	// returned, with its own case, e.g. "prod" for "PROD".
+	IgnoreCase bool
+}
+
+// toEnum returns the allowed value matching v.
+func toEnum(v Value, opts EnumOptions) (string, error) {
+	s, _ := toString(v)
+	for _, allowed := range opts.Allowed {
+		if s == allowed || (opts.IgnoreCase && strings.EqualFold(s, allowed)) {
+			return allowed, nil
+		}
+	}
+	return "", fmt.Errorf(`Bad value "%s": must be one of "%s"`, s, strings.Join(opts.Allowed, `", "`))
+}
+
+// TryGetEnum returns value for the first node matching the spec, as a
+// string, if it's one of the allowed values; if it can't find a value or if
+// it's not allowed, an error is returned instead.
+func (node *Node) TryGetEnum(allowed []string, keys ...interface{}) (string, error) {
+	return node.TryGetEnumWith(EnumOptions{Allowed: allowed}, keys...)
+}
+
+// TryGetEnumWith is like TryGetEnum, but checks the value as specified by
+// opts.
+func (node *Node) TryGetEnumWith(opts EnumOptions, keys ...interface{}) (string, error) {
+	return tryGetAs(node, keys, func(found *Node) (string, error) { return toEnum(found.Value, opts) })
+}
+
+// GetEnumDefault returns the value of the first node that matches the spec,
+// as a string, if it's one of the allowed values. If no node matches, or the
+// value is not allowed, return the default value instead.
+func (node *Node) GetEnumDefault(def string, allowed []string, keys ...interface{}) string {
+	return node.GetEnumDefaultWith(def, EnumOptions{Allowed: allowed}, keys...)
+}
+
+// GetEnumDefaultWith is like GetEnumDefault, but checks the value as
+// specified by opts.
+func (node *Node) GetEnumDefaultWith(def string, opts EnumOptions, keys ...interface{}) string {
+	return getDefault(node, def, keys, true, func(found *Node) (string, error) {
+		return toEnum(found.Value, opts)
+	})
+}
+
+// MustGetEnum returns the value of the first node that matches the spec, as
+// a string, if it's one of the allowed values. If no node matches, or the
+// value is not allowed, panic.
+// This is most suited for intializations.
+func (node *Node) MustGetEnum(allowed []string, keys ...interface{}) string {
+	return node.MustGetEnumWith(EnumOptions{Allowed: allowed}, keys...)
+}
+
+// MustGetEnumWith is like MustGetEnum, but checks the value as specified by
+// opts.
+func (node *Node) MustGetEnumWith(opts EnumOptions, keys ...interface{}) string {
+	val, err := node.TryGetEnumWith(opts, keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
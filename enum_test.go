@@ -0,0 +1,50 @@
+package trix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnum(t *testing.T) {
+	envs := []string{"dev", "stage", "prod"}
+	root := NewRoot()
+	root.SetKey("level", 2)
+	testError(t, root.MergeReader(strings.NewReader("env=prod\nshouting=PROD\nbad=qa\n"), true), "")
+
+	c := func(key string, expected string, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetEnum(envs, key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("env", "prod", "")
+	c("shouting", "", `key "shouting": Bad value "PROD": must be one of "dev", "stage", "prod"`)
+	c("bad", "", `key "bad": Bad value "qa": must be one of "dev", "stage", "prod"`)
+	c("missing", "", `key "missing": node not found`)
+
+	val, err := root.TryGetEnum([]string{"1", "2"}, "level")
+	testError(t, err, "")
+	testDeepEqual(t, val, "2")
+	val, err = root.TryGetEnumWith(EnumOptions{Allowed: envs, IgnoreCase: true}, "shouting")
+	testError(t, err, "")
+	testDeepEqual(t, val, "prod")
+	val, err = root.View().TryGetEnumWith(EnumOptions{Allowed: envs, IgnoreCase: true}, "bad")
+	testError(t, err, `key "bad": Bad value "qa": must be one of "dev", "stage", "prod"`)
+	testDeepEqual(t, val, "")
+
+	testDeepEqual(t, root.GetEnumDefault("dev", envs, "env"), "prod")
+	testDeepEqual(t, root.GetEnumDefault("dev", envs, "bad"), "dev")
+	testDeepEqual(t, root.GetEnumDefault("dev", envs, "missing"), "dev")
+	testDeepEqual(t, root.GetEnumDefault("dev", envs, "shouting"), "dev")
+	ignoreCase := EnumOptions{Allowed: envs, IgnoreCase: true}
+	testDeepEqual(t, root.GetEnumDefaultWith("dev", ignoreCase, "shouting"), "prod")
+	testDeepEqual(t, root.GetEnumDefaultWith("dev", ignoreCase, "bad"), "dev")
+	testDeepEqual(t, root.View().GetEnumDefaultWith("dev", ignoreCase, "shouting"), "prod")
+
+	testDeepEqual(t, root.MustGetEnum(envs, "env"), "prod")
+	testDeepEqual(t, root.MustGetEnumWith(ignoreCase, "shouting"), "prod")
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "bad": Bad value "qa": must be one of "dev", "stage", "prod"`)
+	}()
+	root.MustGetEnum(envs, "bad")
+}
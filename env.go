@@ -0,0 +1,124 @@
+package trix
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// envReplacer maps key separators to the environment-variable separator.
+var envReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// envName returns the environment variable name for the key, e.g.
+// "server.timeout" with prefix "app" becomes "APP_SERVER_TIMEOUT".
+func envName(prefix, key string) string {
+	name := strings.ToUpper(envReplacer.Replace(key))
+	if prefix != "" {
+		name = strings.ToUpper(prefix) + "_" + name
+	}
+	return name
+}
+
+// envKey returns the key MergeEnv sets for the environment variable name,
+// once the prefix is removed.
+func envKey(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", ".", -1))
+}
+
+// TryEnviron returns the arguments as a sorted list of "NAME=value" entries,
+// suitable for exec.Cmd.Env. Names are uppercased, with dots replaced by
+// underscores, and prefixed by the uppercased prefix and an underscore, if
+// it's not empty. Values are converted like GetString does. Keys that
+// MergeEnv wouldn't read back as they are, because they have underscores,
+// dashes or uppercase letters (e.g. "a.b_c", which would be read back as
+// "a.b.c"), are left out, and an error listing them is returned.
+func (args Args) TryEnviron(prefix string) ([]string, error) {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, 0, len(keys))
+	rejected := []string{}
+	for _, key := range keys {
+		name := envName(prefix, key)
+		if readBack := envKey(envName("", key)); readBack != key {
+			rejected = append(rejected, fmt.Sprintf(`"%s" (%s, read back as "%s")`, key, name, readBack))
+			continue
+		}
+		result = append(result, name+"="+args.GetString(key))
+	}
+	sort.Strings(result)
+
+	if len(rejected) > 0 {
+		return result, fmt.Errorf("keys not supported in environment names: %s", strings.Join(rejected, ", "))
+	}
+	return result, nil
+}
+
+// Environ is like TryEnviron, but ignores errors; keys that aren't supported
+// are left out.
+func (args Args) Environ(prefix string) []string {
+	result, _ := args.TryEnviron(prefix)
+	return result
+}
+
+// nodeArgs returns the values of all descendants of the node, keyed by their
+// dot-joined path relative to the node.
+func (node *Node) nodeArgs() Args {
+	args := Args{}
+	var walk func(*Node, []string)
+	walk = func(n *Node, path []string) {
+		if n.Value != nil && len(path) > 0 {
+			args[strings.Join(path, ".")] = n.Value
+		}
+		for _, key := range n.ChildKeys {
+			walk(n.Children[key], append(path[:len(path):len(path)], key))
+		}
+	}
+	walk(node, []string{})
+	return args
+}
+
+// TryEnviron returns the values of all descendants of the node matching the
+// spec, as a list of "NAME=value" entries; see Args.TryEnviron.
+func (node *Node) TryEnviron(prefix string, keys ...interface{}) ([]string, error) {
+	subnode, err := node.TryGetNode(keys...)
+	if err != nil {
+		return nil, err
+	}
+	return subnode.nodeArgs().TryEnviron(prefix)
+}
+
+// Environ is like TryEnviron, but ignores errors.
+func (node *Node) Environ(prefix string, keys ...interface{}) []string {
+	result, _ := node.TryEnviron(prefix, keys...)
+	return result
+}
+
+// MergeEnv adds the entries from environ (or os.Environ, if it's nil) whose
+// names start with the uppercased prefix and an underscore, as the inverse
+// of Environ: the prefix is removed, names are lowercased, and underscores are
+// used as key separators, e.g. "APP_SERVER_TIMEOUT" becomes "server.timeout".
+// Keys can't have underscores, so "APP_MAX_CONNS" becomes "max.conns".
+// Return the original node.
+func (node *Node) MergeEnv(prefix string, environ []string) *Node {
+	if environ == nil {
+		environ = os.Environ()
+	}
+	namePrefix := ""
+	if prefix != "" {
+		namePrefix = strings.ToUpper(prefix) + "_"
+	}
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], namePrefix) || len(parts[0]) == len(namePrefix) {
+			continue
+		}
+		key := envKey(parts[0][len(namePrefix):])
+		internalSetFrom(node, ParseKeys([]interface{}{key}), parts[1], Origin{Kind: "env", Source: parts[0]}, nil)
+	}
+	return node
+}
@@ -0,0 +1,57 @@
+package trix
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnviron(t *testing.T) {
+	args := Args{"server.timeout": time.Second * 10, "server.max.conns": 5, "debug": true}
+	testDeepEqual(t, args.Environ("app"), []string{
+		"APP_DEBUG=true",
+		"APP_SERVER_MAX_CONNS=5",
+		"APP_SERVER_TIMEOUT=10s",
+	})
+	testDeepEqual(t, args.Environ(""), []string{
+		"DEBUG=true",
+		"SERVER_MAX_CONNS=5",
+		"SERVER_TIMEOUT=10s",
+	})
+
+	// keys that can't be read back, including collisions
+	env, err := Args{"a.b_c": 1, "a.b.c": 2, "a-b.c": 3, "A.b": 4}.TryEnviron("x")
+	testError(t, err, `keys not supported in environment names: "A.b" (X_A_B, read back as "a.b"), `+
+		`"a-b.c" (X_A_B_C, read back as "a.b.c"), "a.b_c" (X_A_B_C, read back as "a.b.c")`)
+	testDeepEqual(t, env, []string{"X_A_B_C=2"})
+	testDeepEqual(t, Args{"a.b_c": 1, "a.b.c": 2}.Environ("x"), []string{"X_A_B_C=2"})
+
+	// from a node
+	root := NewRoot()
+	root.SetKey("worker.queue.name", "jobs")
+	root.SetKey("worker.queue.size", 10)
+	root.SetKey("worker.timeout", "1m")
+	root.SetKey("other.key", "x")
+	testDeepEqual(t, root.Environ("w", "worker"), []string{
+		"W_QUEUE_NAME=jobs",
+		"W_QUEUE_SIZE=10",
+		"W_TIMEOUT=1m",
+	})
+	_, err = root.TryEnviron("w", "missing")
+	testError(t, err, `key "missing": node not found`)
+
+	// round-trip
+	copied := NewRoot().MergeEnv("w", append(root.Environ("w", "worker"), "OTHER=1", "W_=2", "bad"))
+	copied.SortRecursively()
+	testEqualString(t, copied, `{queue={name=jobs,size=10},timeout=1m}`)
+	testDeepEqual(t, copied.Environ("w"), root.Environ("w", "worker"))
+
+	// only keys that can be read back are returned
+	root.SetKey("worker.max-conns", 5)
+	root.SetKey("worker.queue.max_size", 100)
+	_, err = root.TryEnviron("w", "worker")
+	testError(t, err, `keys not supported in environment names: "max-conns" (W_MAX_CONNS, read back as "max.conns"), `+
+		`"queue.max_size" (W_QUEUE_MAX_SIZE, read back as "queue.max.size")`)
+	copied = NewRoot().MergeEnv("w", root.Environ("w", "worker"))
+	copied.SortRecursively()
+	testEqualString(t, copied, `{queue={name=jobs,size=10},timeout=1m}`)
+}
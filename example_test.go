@@ -1,11 +1,16 @@
 package trix_test
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"sort"
+	texttemplate "text/template"
 
 	"github.com/paupin2/trix"
 )
@@ -77,12 +82,12 @@ func ExampleNode_TryGet() {
 	// Output:
 	// int 1 | <nil>
 	// *trix.Node {int=1} | <nil>
-	// *trix.Node  | node not found
+	// *trix.Node  | key "missing.node": node not found
 	// int 1 | <nil>
 	// float64 1 | <nil>
 	// string 1 | <nil>
 	// bool true | <nil>
-	// time.Duration 0s | bad duration
+	// time.Duration 0s | key "m.int": bad duration
 }
 
 func ExampleNode_GetMap() {
@@ -174,23 +179,228 @@ func ExampleNode_TemplateFuncs() {
 		Funcs(t.TemplateFuncs()).
 		Parse(`
 		<ul>
-			{{ range (t_getnodes "item.*") }}
-				<li>
-					<a href="{{ t_get "url.base" }}/{{.Get "id"}}">{{ .Get "name" }}</a>
-				</li>
-			{{ end }}
+			{{- range (getnodes "item.*") }}
+			<li><a href="{{ get "url.base" }}/{{.Get "id"}}">{{ .Get "name" }}</a></li>
+			{{- end }}
 		</ul>
 	`)
 	tpl.Execute(os.Stdout, "")
 
 	// Output:
 	// <ul>
-	// <li>
-	// <a href="http://example.com/jhn">John</a>
-	// </li>
-	// <li>
-	// <a href="http://example.com/mry">Mary</a>
-	// </li>
-
-	// </ul>
+	// 			<li><a href="http://example.com/jhn">John</a></li>
+	// 			<li><a href="http://example.com/mry">Mary</a></li>
+	// 		</ul>
+}
+
+func ExampleNode_TemplateFuncsPrefixed() {
+	// Initialize a new root, add a few nodes
+	t := trix.NewRoot()
+	t.SetKey("url.base", "http://example.com")
+	t.AddNode("item").Push().MergeArgs(trix.Args{"id": "jhn", "name": "John"})
+	t.GetNode("item").Push().MergeArgs(trix.Args{"id": "mry", "name": "Mary"})
+
+	// prefixed names don't collide with the application's own functions
+	tpl, _ := template.New("home").
+		Funcs(t.TemplateFuncsPrefixed("t_")).
+		Funcs(template.FuncMap{"get": func() string { return "app" }}).
+		Parse(`
+		<ul>
+			{{- range (t_getnodes "item.*") }}
+			<li><a href="{{ t_get "url.base" }}/{{.Get "id"}}">{{ .Get "name" }}</a></li>
+			{{- end }}
+		</ul>
+	`)
+	tpl.Execute(os.Stdout, "")
+
+	// Output:
+	// <ul>
+	// 			<li><a href="http://example.com/jhn">John</a></li>
+	// 			<li><a href="http://example.com/mry">Mary</a></li>
+	// 		</ul>
+}
+
+func ExampleNode_TextTemplateFuncs() {
+	conf := trix.NewRoot()
+	conf.MergeReader(bytes.NewBufferString(`
+		job.backup.every:duration=1d
+		job.cleanup.every:duration=6h30m
+		job.report.every:duration=90m
+	`), true)
+
+	tpl := texttemplate.Must(texttemplate.New("jobs").
+		Funcs(conf.TextTemplateFuncs()).
+		Parse(`{{ range (getnodes "job.*") }}{{ .Key }} runs every {{ .Get "every" }}
+{{ end }}`))
+	tpl.Execute(os.Stdout, nil)
+
+	// Output:
+	// backup runs every 24h0m0s
+	// cleanup runs every 6h30m0s
+	// report runs every 1h30m0s
+}
+
+func ExampleNode_TemplateFuncs_typed() {
+	conf := trix.NewRoot()
+	conf.MergeReader(bytes.NewBufferString(`
+		shop.name=Cool store
+		shop.open=on
+		shop.items=41
+		shop.vat=0.23
+		shop.timeout=1h30m
+		shop.opened=2019-03-01
+	`), true)
+
+	tpl := texttemplate.Must(texttemplate.New("typed").
+		Funcs(conf.TextTemplateFuncs()).
+		Parse(`{{ getstring "shop.name" }}
+open: {{ if getbool "shop.open" }}yes{{ else }}no{{ end }}
+items: {{ getint "shop.items" | printf "%03d" }}
+vat: {{ getfloat "shop.vat" | printf "%.1f" }}
+timeout: {{ (getduration "shop.timeout").Minutes }} minutes ({{ getdurationstr "shop.timeout" }})
+since: {{ (gettime "shop.opened").Format "Jan 2006" }}
+`))
+	tpl.Execute(os.Stdout, nil)
+
+	// Output:
+	// Cool store
+	// open: yes
+	// items: 041
+	// vat: 0.2
+	// timeout: 90 minutes (1h30m0s)
+	// since: Mar 2019
+}
+
+func ExampleNode_TemplateFuncs_defaults() {
+	conf := trix.NewRoot()
+	conf.SetKey("site.title", "Cool store")
+	conf.SetKey("site.motto", "")
+
+	tpl := texttemplate.Must(texttemplate.New("defaults").
+		Funcs(conf.TextTemplateFuncs()).
+		Parse(`title: {{ getdefault "Untitled" "site.title" }}
+motto: "{{ getdefault "Buy stuff" "site.motto" }}"
+owner: {{ getdefault "nobody" "site.owner" }}
+{{ if has "site.banner" }}banner: {{ get "site.banner" }}{{ else }}no banner{{ end }}
+{{ if exists "site.title" }}has a title{{ end }}
+`))
+	tpl.Execute(os.Stdout, nil)
+
+	// Output:
+	// title: Cool store
+	// motto: ""
+	// owner: nobody
+	// no banner
+	// has a title
+}
+
+func ExampleNode_TemplateFuncs_settings() {
+	conf := trix.NewRoot()
+	sett := conf.AddNode("settings.zipcode")
+	sett.SetKey("1.default", "label:Zip code")
+	sett.SetKey("1.continue", "1")
+	sett.SetKey("2.keys.1", "category")
+	sett.SetKey("2.keys.2", "type")
+	sett.SetKey("2.3041.s.value", "suffix:(of house)")
+	sett.SetKey("2.3042.u.value", "suffix:(of apartment)")
+
+	tpl := texttemplate.Must(texttemplate.New("settings").
+		Funcs(conf.TextTemplateFuncs()).
+		Parse(`{{ define "label" }}{{ .Get "label" }}{{ if .Has "suffix" }} {{ .Get "suffix" }}{{ end }}{{ end -}}
+{{ template "label" (settings "settings.zipcode") }}
+{{ template "label" (settings "settings.zipcode" "category" 3041 "type" "s") }}
+{{ template "label" (settings "settings.zipcode" "category" 3042 "type" "u") }}
+`))
+	tpl.Execute(os.Stdout, nil)
+
+	// Output:
+	// Zip code
+	// Zip code (of house)
+	// Zip code (of apartment)
+}
+
+func ExampleNode_TemplateFuncs_sorted() {
+	t := trix.NewRoot()
+	t.SetKey("item.10.name", "Socks")
+	t.SetKey("item.10.price", "10")
+	t.SetKey("item.2.name", "Cool shirt")
+	t.SetKey("item.2.price", "25")
+	t.SetKey("item.3.name", "Coffee mug")
+	t.SetKey("item.3.price", "7.5")
+
+	tpl, _ := template.New("sorted").
+		Funcs(t.TemplateFuncs()).
+		Parse(`keys: {{ getkeys "item.*" }}
+by key:{{ range (getnodes "item.*" | sortbykey) }} {{ .Get "name" }}{{ end }}
+by price:{{ range (getnodes "item.*" | sortbyvalue "price") }} {{ .Get "name" }}{{ end }}
+`)
+	tpl.Execute(os.Stdout, "")
+
+	// Output:
+	// keys: [10 2 3]
+	// by key: Cool shirt Coffee mug Socks
+	// by price: Coffee mug Socks Cool shirt
+}
+
+func ExampleNode_TemplateFuncsForLang() {
+	en := trix.NewRoot()
+	en.SetKey("text.en.hello", "Hello")
+	en.SetKey("text.en.bye", "Goodbye")
+	en.SetKey("text.en.thanks", "Thanks")
+
+	fr := en.With()
+	fr.SetKey("text.fr.hello", "Bonjour")
+	fr.SetKey("text.fr.bye", "Au revoir")
+
+	de := fr.With()
+	de.SetKey("text.de.hello", "Hallo")
+
+	missing := []string{}
+	collect := func(lang, key string) {
+		missing = append(missing, lang+"."+key)
+	}
+
+	for _, lang := range []string{"de", "fr", "en"} {
+		tpl := texttemplate.Must(texttemplate.New(lang).
+			Funcs(de.TextTemplateFuncsForLang(lang, collect)).
+			Parse(`{{ tr "hello" }}, {{ tr "thanks" }}, {{ tr "bye" }}, {{ tr "unknown" }}` + "\n"))
+		tpl.Execute(os.Stdout, nil)
+	}
+	fmt.Println(missing)
+
+	// Output:
+	// Hallo, Thanks, Goodbye, unknown
+	// Bonjour, Thanks, Au revoir, unknown
+	// Hello, Thanks, Goodbye, unknown
+	// [de.thanks de.bye de.unknown fr.thanks fr.unknown en.unknown]
+}
+
+func ExampleReply_WriteTo() {
+	conf := trix.NewRoot()
+	conf.SetKey("settings.limits.1.keys.1", "plan")
+	conf.SetKey("settings.limits.1.pro.value", "images:20,video:1")
+	conf.SetKey("settings.limits.2.default", "images:5")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := conf.With(trix.Args{"plan": r.URL.Query().Get("plan")}).GetSettings("settings.limits")
+		reply.WriteTo(w)
+	}))
+	defer server.Close()
+
+	for _, plan := range []string{"pro", "free"} {
+		resp, err := http.Get(server.URL + "?plan=" + plan)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(resp.Header.Get("Content-Type"))
+		io.Copy(os.Stdout, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Output:
+	// text/plain; charset=utf-8
+	// images: 20
+	// video: 1
+	// text/plain; charset=utf-8
+	// images: 5
 }
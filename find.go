@@ -0,0 +1,111 @@
+package trix
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FindOptions changes how FindConfigWith looks for a configuration file.
+type FindOptions struct {
+	// Name is the file name, e.g. "app.conf".
+	Name string
+
+	// Dirs are the directories to search, in order. If empty, the defaults
+	// are used: the current directory, the user's config directory (e.g.
+	// $XDG_CONFIG_HOME/app) and /etc/app, where "app" is the name without
+	// its extension.
+	Dirs []string
+
+	// EnvVar, if not empty, is the name of an environment variable (e.g.
+	// "APP_CONFIG") that, when set, has the path to use instead of searching.
+	EnvVar string
+
+	// Getenv, if not nil, is used instead of os.Getenv to read EnvVar.
+	Getenv func(key string) string
+
+	// FS, if not nil, is used instead of the local disk; absolute paths are
+	// made relative to its root.
+	FS fs.FS
+}
+
+// defaultConfigDirs returns the default directories where the configuration
+// file with the specified name is searched.
+func defaultConfigDirs(name string) []string {
+	app := strings.TrimSuffix(name, filepath.Ext(name))
+	dirs := []string{"."}
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, app))
+	}
+	return append(dirs, filepath.Join("/etc", app))
+}
+
+// fsPath converts a path to one usable with an fs.FS.
+func fsPath(p string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "/")
+}
+
+// FindConfig returns the path of the first file with the specified name found
+// in the directories, or the default ones if none is specified (see
+// FindOptions.Dirs). If it's not found, an error is returned.
+func FindConfig(name string, dirs ...string) (string, error) {
+	return FindConfigWith(FindOptions{Name: name, Dirs: dirs})
+}
+
+// FindConfigWith is like FindConfig, but accepts options.
+func FindConfigWith(opts FindOptions) (string, error) {
+	exists := func(p string) bool {
+		var info fs.FileInfo
+		var err error
+		if opts.FS != nil {
+			info, err = fs.Stat(opts.FS, fsPath(p))
+		} else {
+			info, err = os.Stat(p)
+		}
+		return err == nil && !info.IsDir()
+	}
+
+	if opts.EnvVar != "" {
+		getenv := opts.Getenv
+		if getenv == nil {
+			getenv = os.Getenv
+		}
+		if p := getenv(opts.EnvVar); p != "" {
+			if !exists(p) {
+				return "", fmt.Errorf("%s=%s: %w", opts.EnvVar, p, fs.ErrNotExist)
+			}
+			return p, nil
+		}
+	}
+
+	dirs := opts.Dirs
+	if len(dirs) == 0 {
+		dirs = defaultConfigDirs(opts.Name)
+	}
+	for _, dir := range dirs {
+		if p := filepath.Join(dir, opts.Name); exists(p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s not found in %s: %w", opts.Name, strings.Join(dirs, ", "), fs.ErrNotExist)
+}
+
+// LoadFirst loads the first file found by FindConfig into a new root.
+func LoadFirst(name string, dirs ...string) (*Node, error) {
+	return LoadFirstWith(FindOptions{Name: name, Dirs: dirs})
+}
+
+// LoadFirstWith loads the first file found by FindConfigWith into a new root.
+func LoadFirstWith(opts FindOptions) (*Node, error) {
+	filename, err := FindConfigWith(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FS != nil {
+		return LoadWith(LoadOptions{Filename: fsPath(filename), FS: opts.FS})
+	}
+	return Load(filename)
+}
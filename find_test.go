@@ -0,0 +1,57 @@
+package trix
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFindConfig(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/app/app.conf":    {Data: []byte("source=etc\n")},
+		"home/app/app.conf":   {Data: []byte("source=home\n")},
+		"override/other.conf": {Data: []byte("source=override\n")},
+		"etc/app/dir.conf":    {Mode: fs.ModeDir},
+	}
+	env := map[string]string{}
+	opts := FindOptions{
+		Name:   "app.conf",
+		Dirs:   []string{".", "/home/app", "/etc/app"},
+		EnvVar: "APP_CONFIG",
+		Getenv: func(key string) string { return env[key] },
+		FS:     fsys,
+	}
+
+	p, err := FindConfigWith(opts)
+	testError(t, err, "")
+	testDeepEqual(t, p, "/home/app/app.conf")
+
+	opts.Dirs = []string{"/etc/app", "/home/app"}
+	root, err := LoadFirstWith(opts)
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("source"), "etc")
+
+	// environment override
+	env["APP_CONFIG"] = "/override/other.conf"
+	root, err = LoadFirstWith(opts)
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("source"), "override")
+	env["APP_CONFIG"] = "/override/missing.conf"
+	_, err = FindConfigWith(opts)
+	testError(t, err, "APP_CONFIG=/override/missing.conf: file does not exist")
+	delete(env, "APP_CONFIG")
+
+	// not found; directories don't count
+	opts.Name = "dir.conf"
+	_, err = LoadFirstWith(opts)
+	testError(t, err, "dir.conf not found in /etc/app, /home/app: file does not exist")
+	testTrue(t, errors.Is(err, fs.ErrNotExist))
+
+	// default directories
+	_, err = FindConfig("trix-missing-test.conf")
+	testTrue(t, errors.Is(err, fs.ErrNotExist))
+	p, err = FindConfig("main.conf", "missing", "examples")
+	testError(t, err, "")
+	testDeepEqual(t, p, "examples/main.conf")
+}
@@ -0,0 +1,55 @@
+package trix
+
+import "flag"
+
+// flagValue is a flag.Value that reads and writes a path in a tree.
+type flagValue struct {
+	node     *Node
+	path     string
+	typeName string
+}
+
+// FlagValue returns a flag.Value that writes to the path, using SetKey, the
+// flag's value parsed as typeName, one of the types accepted in files
+// (e.g. "int" or "[]duration"; an empty type is a string). Its String method
+// returns the path's current value, formatted like WriteConf does. Flags of
+// type "bool" can be used without a value, e.g. "-verbose".
+func (node *Node) FlagValue(path string, typeName string) flag.Value {
+	return &flagValue{node: node, path: path, typeName: typeName}
+}
+
+// BindFlag defines a flag on fs that writes to the path; see FlagValue.
+// The flag's default is the path's value when BindFlag is called.
+func (node *Node) BindFlag(fs *flag.FlagSet, name, path, typeName, usage string) {
+	fs.Var(node.FlagValue(path, typeName), name, usage)
+}
+
+// String returns the path's current value.
+func (v *flagValue) String() string {
+	if v == nil || v.node == nil {
+		// the flag package calls this on a zero value
+		return ""
+	}
+	value, err := v.node.TryGet(v.path)
+	if err != nil || value == nil {
+		return ""
+	}
+	_, s := formatConfValue(value)
+	return s
+}
+
+// Set parses s and writes it to the path.
+func (v *flagValue) Set(s string) error {
+	value, err := parseValueType(v.typeName, s)
+	if err != nil {
+		return err
+	}
+	v.node.SetKey(v.path, value)
+	return nil
+}
+
+// IsBoolFlag tells the flag package whether the flag can be used without a
+// value.
+func (v *flagValue) IsBoolFlag() bool {
+	return v.typeName == "bool"
+}
@@ -0,0 +1,46 @@
+package trix
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestBindFlag(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.timeout", 30*time.Second)
+	root.SetKey("server.name", "main")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(&bytes.Buffer{})
+	root.BindFlag(fs, "timeout", "server.timeout", "duration", "request timeout")
+	root.BindFlag(fs, "port", "server.port", "int", "port to listen on")
+	root.BindFlag(fs, "verbose", "log.verbose", "bool", "verbose logging")
+	root.BindFlag(fs, "hosts", "server.hosts", "[]string", "allowed hosts")
+	root.BindFlag(fs, "name", "server.name", "", "server name")
+
+	// defaults come from the tree
+	testDeepEqual(t, fs.Lookup("timeout").DefValue, "30s")
+	testDeepEqual(t, fs.Lookup("port").DefValue, "")
+	testDeepEqual(t, fs.Lookup("name").DefValue, "main")
+
+	err := fs.Parse([]string{"-timeout", "1m30s", "-port=8080", "-verbose", "-hosts", `a,b\,c`, "arg"})
+	testError(t, err, "")
+	testDeepEqual(t, fs.Args(), []string{"arg"})
+	testDeepEqual(t, root.Get("server.timeout"), 90*time.Second)
+	testDeepEqual(t, root.Get("server.port"), 8080)
+	testDeepEqual(t, root.Get("log.verbose"), true)
+	testDeepEqual(t, root.Get("server.hosts"), []string{"a", "b,c"})
+	testDeepEqual(t, root.Get("server.name"), "main")
+
+	// String reflects later changes
+	root.SetKey("server.timeout", time.Hour)
+	testDeepEqual(t, fs.Lookup("timeout").Value.String(), "1h")
+	testDeepEqual(t, fs.Lookup("hosts").Value.String(), `a,b\,c`)
+
+	// errors
+	testError(t, fs.Parse([]string{"-port", "eighty"}), `invalid value "eighty" for flag -port: strconv.ParseInt: parsing "eighty": invalid syntax`)
+	testDeepEqual(t, root.Get("server.port"), 8080)
+	testError(t, root.FlagValue("x", "complex").Set("1"), `Bad type: "complex"`)
+}
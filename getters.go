@@ -1,115 +1,260 @@
 package trix
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"net"
+	"net/url"
+	"regexp"
 	"strings"
 	"time"
 )
 
 var (
-	errorNodeNotFound = fmt.Errorf("node not found")
+	// ErrNodeNotFound is the error, wrapped in a KeyError, returned by the
+	// Try getters when no node matches the spec.
+	ErrNodeNotFound = errors.New("node not found")
+
+	errorKeyNotFound = fmt.Errorf("key not found")
 )
 
+// KeyError is the error returned by the Try getters, and by GetAs and
+// GetInto: it wraps the underlying error, like ErrNodeNotFound, ErrParse,
+// ErrParseDuration or a strconv.NumError, so that they can be checked with
+// errors.Is and errors.As, with the spec that was requested. Its message is
+// `key "<spec>": <error>`, e.g. `key "server.timeout": bad duration`.
+type KeyError struct {
+	Key string // the spec, with its elements joined by dots
+	Err error
+}
+
+func (e KeyError) Error() string { return fmt.Sprintf(`key "%s": %v`, e.Key, e.Err) }
+
+// Unwrap returns the underlying error.
+func (e KeyError) Unwrap() error { return e.Err }
+
 // GetNodes returns a slice with the nodes that match the spec.
 func (node *Node) GetNodes(keys ...interface{}) NodeList {
-	return internalGetNodes(node, ParseKeys(keys), 0)
+	return internalGetNodes(node, ParseKeys(keys), 0, false)
+}
+
+// tryGetAs converts the first node matching the spec with convert; errors,
+// including not finding the node, are returned as a KeyError.
+func tryGetAs[T any](node *Node, keys []interface{}, convert func(*Node) (T, error)) (T, error) {
+	parsedKeys := ParseKeys(keys)
+	found, err := internalTryGetNode(node, parsedKeys, false)
+	if err == nil {
+		var val T
+		if val, err = convert(found); err == nil {
+			return val, nil
+		}
+	}
+	var zero T
+	return zero, KeyError{Key: joinPath(parsedKeys), Err: err}
+}
+
+// getDefault converts the first node matching the spec with convert; if no
+// node matches, or converting fails, def is returned instead, and conversion
+// errors are reported to the hook registered with OnConversionError.
+// withDefault is true for the Default getters; see OnMiss.
+func getDefault[T any](node *Node, def T, keys []interface{}, withDefault bool, convert func(*Node) (T, error)) T {
+	found, err := internalTryGetNode(node, ParseKeys(keys), withDefault)
+	if err != nil {
+		return def
+	}
+	val, err := convert(found)
+	if err != nil {
+		if hook := node.baseRoot().rootState().hookSet.Load().conversionErrorHook(); hook != nil {
+			hook(found.Path(), err)
+		}
+		return def
+	}
+	return val
+}
+
+// valueOf adapts a function converting values to one converting nodes, for
+// tryGetAs and getDefault.
+func valueOf[T any](convert func(Value) (T, error)) func(*Node) (T, error) {
+	return func(node *Node) (T, error) { return convert(node.Value) }
+}
+
+// sliceOf returns a function converting nodes to slices with toSlice, for
+// tryGetAs and getDefault.
+func sliceOf[T any](convert func(Value) (T, error)) func(*Node) ([]T, error) {
+	return func(node *Node) ([]T, error) { return toSlice(node, convert) }
 }
 
 // ERROR GETTERS
 // These return node values, converted do different data types for convenience.
-// If no matching node is found return `errorNodeNotFound`.
-// If there is a conversion error, return it.
+// If no matching node is found return `ErrNodeNotFound`.
+// If there is a conversion error, return it. Either way, the error is wrapped
+// in a KeyError.
 
 // TryGet returns value for the first node matching the spec; if it can't find
 // any, an error is returned.
 func (node *Node) TryGet(keys ...interface{}) (Value, error) {
-	childNode, err := node.TryGetNode(keys...)
-	if err == nil {
-		return childNode.Value, nil
-	}
-	return nil, err
+	return tryGetAs(node, keys, func(found *Node) (Value, error) { return found.Value, nil })
 }
 
 // TryGetNode returns the first node matching the spec; if it can't find any,
 // an error is returned.
 func (node *Node) TryGetNode(keys ...interface{}) (*Node, error) {
-	return internalTryGetNode(node, ParseKeys(keys))
+	return tryGetAs(node, keys, func(found *Node) (*Node, error) { return found, nil })
 }
 
 // TryGetString returns value for the first node matching the spec, converted to
 // a string; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetString(keys ...interface{}) (string, error) {
-	childNode, err := node.TryGetNode(keys...)
-	if err != nil {
-		return "", err
-	}
-	return childNode.internalStringValue(), nil
+	return tryGetAs(node, keys, (*Node).internalPlainString)
+}
+
+// TryGetStringNonEmpty returns value for the first node matching the spec,
+// converted to a string, like TryGetString, but only if it isn't empty or
+// whitespace-only. Otherwise an error says which was the case: no node
+// matches (ErrNodeNotFound), the node has no value, including branches, whose
+// string value is empty, or its value is empty.
+func (node *Node) TryGetStringNonEmpty(keys ...interface{}) (string, error) {
+	return tryGetAs(node, keys, func(found *Node) (string, error) {
+		if found.Value == nil && len(found.Children) > 0 {
+			return "", fmt.Errorf("No value, only children")
+		} else if found.Value == nil {
+			return "", fmt.Errorf("No value")
+		}
+		s, err := found.internalPlainString()
+		if err == nil && strings.TrimSpace(s) == "" {
+			return "", fmt.Errorf("Empty value")
+		}
+		return s, err
+	})
 }
 
 // TryGetInt returns value for the first node matching the spec, converted to
 // an int; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetInt(keys ...interface{}) (int, error) {
-	if v, err := node.TryGet(keys...); err != nil {
-		return 0, err
-	} else if castd, ok := v.(int); ok {
-		return castd, nil
-	} else {
-		return parseInt(v)
-	}
+	return tryGetAs(node, keys, valueOf(toInt))
+}
+
+// TryGetUint returns value for the first node matching the spec, converted to
+// a uint64; if it can't find a value or if here's a conversion error,
+// including for negative values, an error is returned instead.
+func (node *Node) TryGetUint(keys ...interface{}) (uint64, error) {
+	return tryGetAs(node, keys, valueOf(toUint))
+}
+
+// TryGetSize returns value for the first node matching the spec, as a number
+// of bytes, parsed with ParseSize; if it can't find a value or if here's a
+// conversion error, an error is returned instead.
+func (node *Node) TryGetSize(keys ...interface{}) (int64, error) {
+	return tryGetAs(node, keys, valueOf(toSize))
 }
 
 // TryGetFloat returns value for the first node matching the spec, converted to
 // an int; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetFloat(keys ...interface{}) (float64, error) {
-	if v, err := node.TryGet(keys...); err != nil {
-		return 0, err
-	} else if castd, ok := v.(float64); ok {
-		return castd, nil
-	} else {
-		return strconv.ParseFloat(fmt.Sprint(v), 64)
-	}
+	return tryGetAs(node, keys, valueOf(toFloat))
+}
+
+// TryGetFloat32 returns value for the first node matching the spec, converted
+// to a float32; if it can't find a value or if here's a conversion error,
+// including for values out of the float32 range, an error is returned instead.
+func (node *Node) TryGetFloat32(keys ...interface{}) (float32, error) {
+	return tryGetAs(node, keys, valueOf(toFloat32))
 }
 
 // TryGetBool returns value for the first node matching the spec, converted to
 // a bool; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetBool(keys ...interface{}) (bool, error) {
-	if v, err := node.TryGet(keys...); err != nil {
-		return false, err
-	} else if castd, ok := v.(bool); ok {
-		return castd, nil
-	} else {
-		return parseBool(v)
-	}
+	return tryGetAs(node, keys, valueOf(toBool))
 }
 
 // TryGetDuration returns value for the first node matching the spec, converted to
 // a duraion; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetDuration(keys ...interface{}) (time.Duration, error) {
-	if v, err := node.TryGet(keys...); err != nil {
-		return 0, err
-	} else if castd, ok := v.(time.Duration); ok {
-		return castd, nil
-	} else {
-		return parseDuration(v)
-	}
+	return tryGetAs(node, keys, valueOf(toDuration))
 }
 
 // TryGetTime returns value for the first node matching the spec, converted to
 // a duraion; if it can't find a value or if here's a conversion error,
 // an error is returned instead.
 func (node *Node) TryGetTime(keys ...interface{}) (time.Time, error) {
-	if v, err := node.TryGet(keys...); err != nil {
-		return time.Time{}, err
-	} else if castd, ok := v.(time.Time); ok {
-		return castd, nil
-	} else {
-		return parseTime(v)
+	return tryGetAs(node, keys, valueOf(toTime))
+}
+
+// TryGetURL returns value for the first node matching the spec, parsed as an
+// absolute URL; if it can't find a value, if it can't be parsed, or if it has
+// no scheme or host, an error is returned instead.
+func (node *Node) TryGetURL(keys ...interface{}) (*url.URL, error) {
+	return tryGetAs(node, keys, valueOf(toURL))
+}
+
+// TryGetIP returns value for the first node matching the spec, parsed as an
+// IPv4 or IPv6 address; IPv4 addresses, including IPv4-mapped ones, use the
+// 4-byte form. If it can't find a value or if parsing fails, an error is
+// returned instead.
+func (node *Node) TryGetIP(keys ...interface{}) (net.IP, error) {
+	return tryGetAs(node, keys, valueOf(toIP))
+}
+
+// TryGetCIDR returns value for the first node matching the spec, parsed as a
+// network in CIDR notation, like "10.0.0.0/8" or "2001:db8::/32". If it can't
+// find a value or if parsing fails, an error is returned instead.
+func (node *Node) TryGetCIDR(keys ...interface{}) (*net.IPNet, error) {
+	return tryGetAs(node, keys, valueOf(toCIDR))
+}
+
+// TryGetRegexp returns value for the first node matching the spec, compiled
+// with regexp.Compile; if it can't find a node or if compiling fails, an
+// error is returned instead. Compiled regexps are cached, and reused for
+// values with the same pattern.
+func (node *Node) TryGetRegexp(keys ...interface{}) (*regexp.Regexp, error) {
+	return tryGetAs(node, keys, toRegexp)
+}
+
+// TryGetBytes returns value for the first node matching the spec, decoded
+// from base64 (standard or URL-safe) or, if it starts with "hex:", from hex;
+// []byte values are returned as is. If it can't find a node or if decoding
+// fails, an error is returned instead.
+func (node *Node) TryGetBytes(keys ...interface{}) ([]byte, error) {
+	return tryGetAs(node, keys, toBytes)
+}
+
+// TryGetJSON unmarshals the first node matching the spec into target, with
+// json.Unmarshal: nodes with children are unmarshalled from their JSON
+// representation (see MarshalJSON), and other nodes from their value, e.g. a
+// string like `{"enabled":true}`. If it can't find a node or if unmarshalling
+// fails, an error is returned.
+func (node *Node) TryGetJSON(target interface{}, keys ...interface{}) error {
+	_, err := tryGetAs(node, keys, unmarshalInto(target))
+	return err
+}
+
+// unmarshalInto returns a function unmarshalling nodes into target, as done
+// by TryGetJSON, for tryGetAs and getDefault.
+func unmarshalInto(target interface{}) func(*Node) (bool, error) {
+	return func(node *Node) (bool, error) {
+		var data []byte
+		var err error
+		if len(node.Children) > 0 {
+			data, err = node.MarshalJSON()
+		} else if raw, ok := node.Value.([]byte); ok {
+			data = raw
+		} else if raw, ok := node.Value.(json.RawMessage); ok {
+			data = raw
+		} else {
+			var s string
+			s, err = node.internalPlainString()
+			data = []byte(s)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, target)
+		}
+		return err == nil, err
 	}
 }
 
@@ -120,8 +265,8 @@ func (node *Node) TryGetTime(keys ...interface{}) (time.Time, error) {
 // GetNodeDefault returns the first node that matches the spec.
 // If no node matches, return the default value instead.
 func (node *Node) GetNodeDefault(def *Node, keys ...interface{}) *Node {
-	if val, err := node.TryGetNode(keys...); err == nil {
-		return val
+	if found, err := internalTryGetNode(node, ParseKeys(keys), true); err == nil {
+		return found
 	}
 	return def
 }
@@ -129,59 +274,94 @@ func (node *Node) GetNodeDefault(def *Node, keys ...interface{}) *Node {
 // GetDefault returns the value of the first node that matches the spec.
 // If no node matches, return the default value instead.
 func (node *Node) GetDefault(def Value, keys ...interface{}) Value {
-	if val, err := node.TryGet(keys...); err == nil {
-		return val
+	if found, err := internalTryGetNode(node, ParseKeys(keys), true); err == nil {
+		return found.Value
 	}
 	return def
 }
 
 // GetStringDefault returns the value of the first node that matches the spec.
-// If no node matches, return the default value instead.
+// If no node matches, or decrypting it fails, return the default value
+// instead.
 func (node *Node) GetStringDefault(def string, keys ...interface{}) string {
-	if val, err := node.TryGetString(keys...); err == nil {
-		return val
-	}
-	return def
+	return getDefault(node, def, keys, true, (*Node).internalPlainString)
 }
 
 // GetIntDefault returns the value of the first node that matches the spec,
 // converted to an int. If no node matches, or converting fails, return
 // the default value instead.
 func (node *Node) GetIntDefault(def int, keys ...interface{}) int {
-	if val, err := node.TryGetInt(keys...); err == nil {
-		return val
-	}
-	return def
+	return getDefault(node, def, keys, true, valueOf(toInt))
+}
+
+// GetUintDefault returns the value of the first node that matches the spec,
+// converted to a uint64. If no node matches, or converting fails, return
+// the default value instead.
+func (node *Node) GetUintDefault(def uint64, keys ...interface{}) uint64 {
+	return getDefault(node, def, keys, true, valueOf(toUint))
+}
+
+// GetSizeDefault returns the value of the first node that matches the spec,
+// as a number of bytes (see ParseSize). If no node matches, or converting
+// fails, return the default value instead.
+func (node *Node) GetSizeDefault(def int64, keys ...interface{}) int64 {
+	return getDefault(node, def, keys, true, valueOf(toSize))
 }
 
 // GetFloatDefault returns the value of the first node that matches the spec,
 // converted to a float64. If no node matches, or converting fails, return
 // the default value instead.
 func (node *Node) GetFloatDefault(def float64, keys ...interface{}) float64 {
-	if val, err := node.TryGetFloat(keys...); err == nil {
-		return val
-	}
-	return def
+	return getDefault(node, def, keys, true, valueOf(toFloat))
+}
+
+// GetFloat32Default returns the value of the first node that matches the spec,
+// converted to a float32. If no node matches, or converting fails, return
+// the default value instead.
+func (node *Node) GetFloat32Default(def float32, keys ...interface{}) float32 {
+	return getDefault(node, def, keys, true, valueOf(toFloat32))
 }
 
 // GetBoolDefault returns the value of the first node that matches the spec,
 // converted to a bool. If no node matches, or converting fails, return
 // the default value instead.
 func (node *Node) GetBoolDefault(def bool, keys ...interface{}) bool {
-	if val, err := node.TryGetBool(keys...); err == nil {
-		return val
-	}
-	return def
+	return getDefault(node, def, keys, true, valueOf(toBool))
 }
 
 // GetDurationDefault returns the value of the first node that matches the spec,
 // converted to a duration. If no node matches, or converting fails, return
 // the default value instead.
 func (node *Node) GetDurationDefault(def time.Duration, keys ...interface{}) time.Duration {
-	if val, err := node.TryGetDuration(keys...); err == nil {
-		return val
-	}
-	return def
+	return getDefault(node, def, keys, true, valueOf(toDuration))
+}
+
+// GetTimeDefault returns the value of the first node that matches the spec,
+// converted to a timestamp. If no node matches, or converting fails, return
+// the default value instead.
+func (node *Node) GetTimeDefault(def time.Time, keys ...interface{}) time.Time {
+	return getDefault(node, def, keys, true, valueOf(toTime))
+}
+
+// GetURLDefault returns the value of the first node that matches the spec,
+// parsed as an absolute URL, as done by TryGetURL. If no node matches, or
+// parsing fails, return the default value instead.
+func (node *Node) GetURLDefault(def *url.URL, keys ...interface{}) *url.URL {
+	return getDefault(node, def, keys, true, valueOf(toURL))
+}
+
+// GetIPDefault returns the value of the first node that matches the spec,
+// parsed as an IP address, as done by TryGetIP. If no node matches, or
+// parsing fails, return the default value instead.
+func (node *Node) GetIPDefault(def net.IP, keys ...interface{}) net.IP {
+	return getDefault(node, def, keys, true, valueOf(toIP))
+}
+
+// GetCIDRDefault returns the value of the first node that matches the spec,
+// parsed as a network in CIDR notation. If no node matches, or parsing fails,
+// return the default value instead.
+func (node *Node) GetCIDRDefault(def *net.IPNet, keys ...interface{}) *net.IPNet {
+	return getDefault(node, def, keys, true, valueOf(toCIDR))
 }
 
 // SIMPLE GETTERS
@@ -191,7 +371,8 @@ func (node *Node) GetDurationDefault(def time.Duration, keys ...interface{}) tim
 // GetNode returns the first node that matches the spec.
 // If no node matches, return nil.
 func (node *Node) GetNode(keys ...interface{}) *Node {
-	return node.GetNodeDefault(nil, keys...)
+	found, _ := node.TryGetNode(keys...)
+	return found
 }
 
 // Get returns the value of the first node that matches the spec.
@@ -207,8 +388,7 @@ func (node *Node) Get(keys ...interface{}) Value {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetString(keys ...interface{}) string {
-	val, _ := node.TryGetString(keys...)
-	return val
+	return getDefault(node, "", keys, false, (*Node).internalPlainString)
 }
 
 // GetInt returns the value of the first node that matches the spec,
@@ -216,8 +396,23 @@ func (node *Node) GetString(keys ...interface{}) string {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetInt(keys ...interface{}) int {
-	val, _ := node.TryGetInt(keys...)
-	return val
+	return getDefault(node, 0, keys, false, valueOf(toInt))
+}
+
+// GetUint returns the value of the first node that matches the spec,
+// converted to a uint64. If no node matches, or converting fails, return
+// the type's default value instead.
+// If no argument is given, the current node is used.
+func (node *Node) GetUint(keys ...interface{}) uint64 {
+	return getDefault(node, 0, keys, false, valueOf(toUint))
+}
+
+// GetSize returns the value of the first node that matches the spec, as a
+// number of bytes (see ParseSize). If no node matches, or converting fails,
+// return the type's default value instead.
+// If no argument is given, the current node is used.
+func (node *Node) GetSize(keys ...interface{}) int64 {
+	return getDefault(node, 0, keys, false, valueOf(toSize))
 }
 
 // GetFloat returns the value of the first node that matches the spec,
@@ -225,8 +420,15 @@ func (node *Node) GetInt(keys ...interface{}) int {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetFloat(keys ...interface{}) float64 {
-	val, _ := node.TryGetFloat(keys...)
-	return val
+	return getDefault(node, 0, keys, false, valueOf(toFloat))
+}
+
+// GetFloat32 returns the value of the first node that matches the spec,
+// converted to a float32. If no node matches, or converting fails, return
+// the type's default value instead.
+// If no argument is given, the current node is used.
+func (node *Node) GetFloat32(keys ...interface{}) float32 {
+	return getDefault(node, 0, keys, false, valueOf(toFloat32))
 }
 
 // GetBool returns the value of the first node that matches the spec,
@@ -234,8 +436,7 @@ func (node *Node) GetFloat(keys ...interface{}) float64 {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetBool(keys ...interface{}) bool {
-	val, _ := node.TryGetBool(keys...)
-	return val
+	return getDefault(node, false, keys, false, valueOf(toBool))
 }
 
 // GetDuration returns the value of the first node that matches the spec,
@@ -243,8 +444,7 @@ func (node *Node) GetBool(keys ...interface{}) bool {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetDuration(keys ...interface{}) time.Duration {
-	val, _ := node.TryGetDuration(keys...)
-	return val
+	return getDefault(node, 0, keys, false, valueOf(toDuration))
 }
 
 // GetTime returns the value of the first node that matches the spec,
@@ -252,8 +452,62 @@ func (node *Node) GetDuration(keys ...interface{}) time.Duration {
 // the type's default value instead.
 // If no argument is given, the current node is used.
 func (node *Node) GetTime(keys ...interface{}) time.Time {
-	val, _ := node.TryGetTime(keys...)
-	return val
+	return getDefault(node, time.Time{}, keys, false, valueOf(toTime))
+}
+
+// GetURL returns the value of the first node that matches the spec, parsed as
+// an absolute URL, as done by TryGetURL. If no node matches, or parsing
+// fails, return nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetURL(keys ...interface{}) *url.URL {
+	return getDefault(node, nil, keys, false, valueOf(toURL))
+}
+
+// GetRelativeURL returns the value of the first node that matches the spec,
+// parsed as a URL, like GetURL, but relative URLs and ones without a scheme
+// are also accepted, so an empty value is an empty URL. If no node matches,
+// or parsing fails, return nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetRelativeURL(keys ...interface{}) *url.URL {
+	return getDefault(node, nil, keys, false, valueOf(parseURL))
+}
+
+// GetIP returns the value of the first node that matches the spec, parsed as
+// an IP address, as done by TryGetIP. If no node matches, or parsing fails,
+// return nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetIP(keys ...interface{}) net.IP {
+	return getDefault(node, nil, keys, false, valueOf(toIP))
+}
+
+// GetCIDR returns the value of the first node that matches the spec, parsed
+// as a network in CIDR notation. If no node matches, or parsing fails, return
+// nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetCIDR(keys ...interface{}) *net.IPNet {
+	return getDefault(node, nil, keys, false, valueOf(toCIDR))
+}
+
+// GetRegexp returns the value of the first node that matches the spec,
+// compiled as done by TryGetRegexp. If no node matches, or compiling fails,
+// return nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetRegexp(keys ...interface{}) *regexp.Regexp {
+	return getDefault(node, nil, keys, false, toRegexp)
+}
+
+// GetBytes returns the value of the first node that matches the spec,
+// decoded as done by TryGetBytes. If no node matches, or decoding fails,
+// return nil.
+// If no argument is given, the current node is used.
+func (node *Node) GetBytes(keys ...interface{}) []byte {
+	return getDefault(node, nil, keys, false, toBytes)
+}
+
+// GetJSON unmarshals the first node matching the spec into target, as done by
+// TryGetJSON, and returns whether it succeeded.
+func (node *Node) GetJSON(target interface{}, keys ...interface{}) bool {
+	return getDefault(node, false, keys, false, unmarshalInto(target))
 }
 
 // MUST GETTERS
@@ -265,10 +519,7 @@ func (node *Node) GetTime(keys ...interface{}) time.Time {
 func (node *Node) MustGetNode(keys ...interface{}) *Node {
 	val, err := node.TryGetNode(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -278,10 +529,7 @@ func (node *Node) MustGetNode(keys ...interface{}) *Node {
 func (node *Node) MustGet(keys ...interface{}) Value {
 	val, err := node.TryGet(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -292,10 +540,19 @@ func (node *Node) MustGet(keys ...interface{}) Value {
 func (node *Node) MustGetString(keys ...interface{}) string {
 	val, err := node.TryGetString(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
+
+// MustGetStringNonEmpty returns the value of the first node that matches the
+// spec, converted to a string, as done by TryGetStringNonEmpty. If no node
+// matches, or its value is empty, panic.
+// This is most suited for intializations.
+func (node *Node) MustGetStringNonEmpty(keys ...interface{}) string {
+	val, err := node.TryGetStringNonEmpty(keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -306,10 +563,18 @@ func (node *Node) MustGetString(keys ...interface{}) string {
 func (node *Node) MustGetInt(keys ...interface{}) int {
 	val, err := node.TryGetInt(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
+
+// MustGetUint returns the value of the first node that matches the spec,
+// converted to a uint64. If no node matches, or converting fails, panic.
+// This is most suited for intializations.
+func (node *Node) MustGetUint(keys ...interface{}) uint64 {
+	val, err := node.TryGetUint(keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -320,10 +585,7 @@ func (node *Node) MustGetInt(keys ...interface{}) int {
 func (node *Node) MustGetFloat(keys ...interface{}) float64 {
 	val, err := node.TryGetFloat(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -334,10 +596,7 @@ func (node *Node) MustGetFloat(keys ...interface{}) float64 {
 func (node *Node) MustGetBool(keys ...interface{}) bool {
 	val, err := node.TryGetBool(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
@@ -348,18 +607,70 @@ func (node *Node) MustGetBool(keys ...interface{}) bool {
 func (node *Node) MustGetDuration(keys ...interface{}) time.Duration {
 	val, err := node.TryGetDuration(keys...)
 	if err != nil {
-		panic(fmt.Sprintf("Required conf key %s: %v",
-			strings.Join(ParseKeys(keys), "."),
-			err,
-		))
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
+
+// MustGetTime returns the value of the first node that matches the spec,
+// converted to a timestamp. If no node matches, or converting fails, panic.
+// This is most suited for intializations.
+func (node *Node) MustGetTime(keys ...interface{}) time.Time {
+	val, err := node.TryGetTime(keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
+
+// MustGetURL returns the value of the first node that matches the spec,
+// parsed as an absolute URL, as done by TryGetURL. If no node matches, or
+// parsing fails, panic.
+// This is most suited for intializations.
+func (node *Node) MustGetURL(keys ...interface{}) *url.URL {
+	val, err := node.TryGetURL(keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
+	}
+	return val
+}
+
+// MustGetRegexp returns the value of the first node that matches the spec,
+// compiled as done by TryGetRegexp. If no node matches, or compiling fails,
+// panic.
+// This is most suited for intializations.
+func (node *Node) MustGetRegexp(keys ...interface{}) *regexp.Regexp {
+	val, err := node.TryGetRegexp(keys...)
+	if err != nil {
+		panic("Required conf " + err.Error())
 	}
 	return val
 }
 
 // EXTRA GETTERS
 
-// GetValues return the values of all of the nodes that match the spec.
+// hasValue returns whether the node is used by the extra getters: leaves,
+// and branches that have a value.
+func (node *Node) hasValue() bool {
+	return node.Value != nil || node.IsLeaf()
+}
+
+// GetValues return the values of all of the nodes that match the spec,
+// including branches that have a value, but not those without one. See
+// GetLeafValues.
 func (node *Node) GetValues(keys ...interface{}) []Value {
+	values := make([]Value, 0, 10)
+	for _, node := range node.GetNodes(keys...) {
+		if node.hasValue() {
+			values = append(values, node.Value)
+		}
+	}
+	return values
+}
+
+// GetLeafValues return the values of the nodes without children that match
+// the spec.
+func (node *Node) GetLeafValues(keys ...interface{}) []Value {
 	values := make([]Value, 0, 10)
 	for _, node := range node.GetNodes(keys...) {
 		if node.IsLeaf() {
@@ -371,21 +682,30 @@ func (node *Node) GetValues(keys ...interface{}) []Value {
 
 // GetMap returns a key/value pair for a spec like "*.*.common.region.*.name".
 // Use the position of the last star as the key, and the node's value.
+// A spec without a star, like "server.http", is the same as "server.http.*",
+// that is, the children of the matching node, keyed by their own keys; and
+// no spec is the same as "*". Like GetValues, branches without a value are
+// skipped.
 func (node *Node) GetMap(keys ...interface{}) Args {
 	if len(keys) == 0 {
 		return node.GetMap("*")
 	}
 
 	// split the original spec in two, one before and one after the last `*`
-	lastStarPos := 0
+	lastStarPos := -1
 	parseKeys := ParseKeys(keys)
 	ifParsedKeys := make([]interface{}, len(parseKeys))
 	for index, part := range parseKeys {
-		ifParsedKeys[index] = parseKeys[index]
+		ifParsedKeys[index] = exactKey(part)
 		if part == "*" {
 			lastStarPos = index
 		}
 	}
+	if lastStarPos < 0 {
+		// no star; use the children
+		ifParsedKeys = append(ifParsedKeys, "*")
+		lastStarPos = len(ifParsedKeys) - 1
+	}
 	keysUntilStar := ifParsedKeys[:lastStarPos+1]
 	keysAfterStar := ifParsedKeys[lastStarPos+1:]
 
@@ -396,7 +716,7 @@ func (node *Node) GetMap(keys ...interface{}) Args {
 		if len(keysAfterStar) > 0 {
 			subnode = subnode.GetNode(keysAfterStar...)
 		}
-		if subnode == nil {
+		if subnode == nil || !subnode.hasValue() {
 			continue
 		}
 		result[key] = subnode.internalStringValue()
@@ -407,19 +727,223 @@ func (node *Node) GetMap(keys ...interface{}) Args {
 // GetStringMap returns a map for a spec like "*.*.common.region.*.name".
 // Use the position of the last star as the key, and the node's string value.
 func (node *Node) GetStringMap(keys ...interface{}) StrArgs {
-	result := map[string]string{}
-	for key, value := range node.GetMap(keys...) {
-		result[key] = fmt.Sprint(value)
-	}
-	return result
+	return ArgsToStrArgs(node.GetMap(keys...))
 }
 
-// GetStringValues returns a slice with values for all matching node values.
+// GetStringValues returns a slice with values for all matching node values;
+// like GetValues, branches without a value are skipped.
 func (node *Node) GetStringValues(keys ...interface{}) []string {
 	found := node.GetNodes(keys...)
-	result := make([]string, len(found))
-	for i, subnode := range found {
-		result[i] = subnode.internalStringValue()
+	result := make([]string, 0, len(found))
+	for _, subnode := range found {
+		if subnode.hasValue() {
+			result = append(result, subnode.internalStringValue())
+		}
 	}
 	return result
 }
+
+// GetKeys returns the distinct keys of all nodes that match the spec, in the
+// order they are found.
+func (node *Node) GetKeys(keys ...interface{}) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	for _, subnode := range node.GetNodes(keys...) {
+		if !seen[subnode.Key] {
+			seen[subnode.Key] = true
+			result = append(result, subnode.Key)
+		}
+	}
+	return result
+}
+
+// TryGetFirst returns the value of the first node matching any of the paths,
+// tried in order, e.g. the new name of a key, and then its legacy one. Each
+// path is a spec on its own: a string like "server.port", or a
+// []interface{} with the keys of a spec. Every path is searched through the
+// parent scopes before moving to the next one, and paths with wildcards
+// match as in TryGet, that is, their first match is used, if any. If no path
+// matches, an error is returned, with the first path as its Key.
+func (node *Node) TryGetFirst(paths ...interface{}) (Value, error) {
+	found, err := internalGetFirst(node, paths)
+	if err != nil {
+		return nil, err
+	}
+	return found.Value, nil
+}
+
+// internalGetFirst returns the first node matching any of the paths; see
+// TryGetFirst.
+func internalGetFirst(node *Node, paths []interface{}) (*Node, error) {
+	var first string
+	for i, path := range paths {
+		keys, ok := path.([]interface{})
+		if !ok {
+			keys = []interface{}{path}
+		}
+		parsedKeys := ParseKeys(keys)
+		if found, err := internalTryGetNode(node, parsedKeys, false); err == nil {
+			return found, nil
+		} else if i == 0 {
+			first = joinPath(parsedKeys)
+		}
+	}
+	return nil, KeyError{Key: first, Err: ErrNodeNotFound}
+}
+
+// GetStringFirst returns the value of the first node matching any of the
+// paths, as done by TryGetFirst, converted to a string as done by GetString.
+// If no path matches, or decrypting fails, return an empty string.
+func (node *Node) GetStringFirst(paths ...interface{}) string {
+	found, err := internalGetFirst(node, paths)
+	if err != nil {
+		return ""
+	}
+	return getDefault(found, "", nil, false, (*Node).internalPlainString)
+}
+
+// GetIntFirst returns the value of the first node matching any of the paths,
+// as done by TryGetFirst, converted to an int. If no path matches, or
+// converting fails, return 0.
+func (node *Node) GetIntFirst(paths ...interface{}) int {
+	found, err := internalGetFirst(node, paths)
+	if err != nil {
+		return 0
+	}
+	return getDefault(found, 0, nil, false, valueOf(toInt))
+}
+
+// SLICE GETTERS
+// These return the value of the first node that matches the spec as a slice:
+// values that already are slices of the type are returned as is, other values
+// are split on unescaped commas (e.g. "a,b\,c" is "a" and "b,c"), and nodes
+// without a value use their children's values, in order, like the ones added
+// by Push. The non-Try variants return a nil slice if no node matches, or
+// converting fails.
+
+// TryGetStringSlice returns the value of the first node that matches the
+// spec, as a slice of strings; if it can't find a node, an error is returned
+// instead.
+func (node *Node) TryGetStringSlice(keys ...interface{}) ([]string, error) {
+	return tryGetAs(node, keys, sliceOf(toString))
+}
+
+// GetStringSlice returns the value of the first node that matches the spec,
+// as a slice of strings. A node without a value or children returns an empty
+// slice.
+func (node *Node) GetStringSlice(keys ...interface{}) []string {
+	return getDefault(node, nil, keys, false, sliceOf(toString))
+}
+
+// GetStringSliceDefault returns the value of the first node that matches the
+// spec, as a slice of strings. If no node matches, return the default value
+// instead.
+func (node *Node) GetStringSliceDefault(def []string, keys ...interface{}) []string {
+	return getDefault(node, def, keys, true, sliceOf(toString))
+}
+
+// TryGetIntSlice returns the value of the first node that matches the spec,
+// as a slice of ints; if it can't find a node or if any element can't be
+// converted, an error is returned instead.
+func (node *Node) TryGetIntSlice(keys ...interface{}) ([]int, error) {
+	return tryGetAs(node, keys, sliceOf(toInt))
+}
+
+// GetIntSlice returns the value of the first node that matches the spec, as
+// a slice of ints.
+func (node *Node) GetIntSlice(keys ...interface{}) []int {
+	return getDefault(node, nil, keys, false, sliceOf(toInt))
+}
+
+// GetIntSliceDefault returns the value of the first node that matches the
+// spec, as a slice of ints. If no node matches, or any element can't be
+// converted, return the default value instead.
+func (node *Node) GetIntSliceDefault(def []int, keys ...interface{}) []int {
+	return getDefault(node, def, keys, true, sliceOf(toInt))
+}
+
+// TryGetFloatSlice returns the value of the first node that matches the spec,
+// as a slice of float64s; if it can't find a node or if any element can't be
+// converted, an error is returned instead.
+func (node *Node) TryGetFloatSlice(keys ...interface{}) ([]float64, error) {
+	return tryGetAs(node, keys, sliceOf(toFloat))
+}
+
+// GetFloatSlice returns the value of the first node that matches the spec, as
+// a slice of float64s.
+func (node *Node) GetFloatSlice(keys ...interface{}) []float64 {
+	return getDefault(node, nil, keys, false, sliceOf(toFloat))
+}
+
+// GetFloatSliceDefault returns the value of the first node that matches the
+// spec, as a slice of float64s. If no node matches, or any element can't be
+// converted, return the default value instead.
+func (node *Node) GetFloatSliceDefault(def []float64, keys ...interface{}) []float64 {
+	return getDefault(node, def, keys, true, sliceOf(toFloat))
+}
+
+// TryGetBoolSlice returns the value of the first node that matches the spec,
+// as a slice of bools; if it can't find a node or if any element can't be
+// converted, an error is returned instead.
+func (node *Node) TryGetBoolSlice(keys ...interface{}) ([]bool, error) {
+	return tryGetAs(node, keys, sliceOf(toBool))
+}
+
+// GetBoolSlice returns the value of the first node that matches the spec, as
+// a slice of bools.
+func (node *Node) GetBoolSlice(keys ...interface{}) []bool {
+	return getDefault(node, nil, keys, false, sliceOf(toBool))
+}
+
+// GetBoolSliceDefault returns the value of the first node that matches the
+// spec, as a slice of bools. If no node matches, or any element can't be
+// converted, return the default value instead.
+func (node *Node) GetBoolSliceDefault(def []bool, keys ...interface{}) []bool {
+	return getDefault(node, def, keys, true, sliceOf(toBool))
+}
+
+// TryGetDurationSlice returns the value of the first node that matches the spec,
+// as a slice of durations; if it can't find a node or if any element can't be
+// converted, an error is returned instead.
+func (node *Node) TryGetDurationSlice(keys ...interface{}) ([]time.Duration, error) {
+	return tryGetAs(node, keys, sliceOf(toDuration))
+}
+
+// GetDurationSlice returns the value of the first node that matches the spec, as
+// a slice of durations.
+func (node *Node) GetDurationSlice(keys ...interface{}) []time.Duration {
+	return getDefault(node, nil, keys, false, sliceOf(toDuration))
+}
+
+// GetDurationSliceDefault returns the value of the first node that matches the
+// spec, as a slice of durations. If no node matches, or any element can't be
+// converted, return the default value instead.
+func (node *Node) GetDurationSliceDefault(def []time.Duration, keys ...interface{}) []time.Duration {
+	return getDefault(node, def, keys, true, sliceOf(toDuration))
+}
+
+// TryGetTimeSlice returns the value of the first node that matches the spec,
+// as a slice of timestamps; if it can't find a node or if any element can't be
+// converted, an error is returned instead.
+func (node *Node) TryGetTimeSlice(keys ...interface{}) ([]time.Time, error) {
+	return tryGetAs(node, keys, sliceOf(toTime))
+}
+
+// GetTimeSlice returns the value of the first node that matches the spec, as
+// a slice of timestamps.
+func (node *Node) GetTimeSlice(keys ...interface{}) []time.Time {
+	return getDefault(node, nil, keys, false, sliceOf(toTime))
+}
+
+// TryGetIPSlice returns the value of the first node that matches the spec,
+// as a slice of IP addresses, parsed as done by TryGetIP; if it can't find a
+// node or if any element can't be parsed, an error is returned instead.
+func (node *Node) TryGetIPSlice(keys ...interface{}) ([]net.IP, error) {
+	return tryGetAs(node, keys, sliceOf(toIP))
+}
+
+// GetIPSlice returns the value of the first node that matches the spec, as a
+// slice of IP addresses, e.g. for an allow-list like "10.0.0.1,::1".
+func (node *Node) GetIPSlice(keys ...interface{}) []net.IP {
+	return getDefault(node, nil, keys, false, sliceOf(toIP))
+}
@@ -1,6 +1,15 @@
 package trix
 
 import (
+	"bytes"
+	"errors"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -14,23 +23,23 @@ func TestTryGetNode(t *testing.T) {
 	}
 
 	// node == nil, but this should not segfault
-	shouldFail("node not found", "x.y")
+	shouldFail(`key "x.y": node not found`, "x.y")
 
 	node = NewNode("lol")
-	shouldFail("node not found", "x.y")
+	shouldFail(`key "x.y": node not found`, "x.y")
 
 	node.SetKey("x.y", "a")
 	_, err := node.TryGetInt("x.y")
-	testError(t, err, `strconv.ParseInt: parsing "a": invalid syntax`)
+	testError(t, err, `key "x.y": strconv.ParseInt: parsing "a": invalid syntax`)
 
 	_, err = node.TryGetFloat("x.y")
-	testError(t, err, `strconv.ParseFloat: parsing "a": invalid syntax`)
+	testError(t, err, `key "x.y": strconv.ParseFloat: parsing "a": invalid syntax`)
 
 	_, err = node.TryGetDuration("x.y")
-	testError(t, err, `bad duration`)
+	testError(t, err, `key "x.y": bad duration`)
 
 	_, err = node.TryGetBool("x.y")
-	testError(t, err, `bad value`)
+	testError(t, err, `key "x.y": bad value`)
 
 	node.SetKey("x.a", "true")
 	testDeepEqual(t, node.GetBool("x.a"), true)
@@ -42,6 +51,79 @@ func TestTryGetNode(t *testing.T) {
 	testDeepEqual(t, node.GetDuration("x.c"), time.Hour*49+time.Minute*20)
 }
 
+func TestStringNonEmpty(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.dsn", "postgres://db/app")
+	root.SetKey("db.blank", " \t")
+	root.SetKey("db.empty", "")
+	root.SetKey("db.port", 0)
+	root.AddNode("db.none")
+	root.GetNode("db").Value = "db"
+
+	c := func(key string, expected string, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetStringNonEmpty(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("db.dsn", "postgres://db/app", "")
+	c("db.port", "0", "")
+	c("db", "db", "")
+	c("db.blank", "", `key "db.blank": Empty value`)
+	c("db.empty", "", `key "db.empty": Empty value`)
+	c("db.none", "", `key "db.none": No value`)
+	c("missing", "", `key "missing": node not found`)
+	root.GetNode("db").Value = nil
+	c("db", "", `key "db": No value, only children`)
+
+	_, err := root.View().TryGetStringNonEmpty("db.blank")
+	testTrue(t, err != nil)
+	testDeepEqual(t, root.MustGetStringNonEmpty("db.dsn"), "postgres://db/app")
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "db.empty": Empty value`)
+	}()
+	root.MustGetStringNonEmpty("db.empty")
+}
+
+func TestKeyError(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.timeout", "soon")
+	root.SetKey("server.port", "http")
+	scope := root.GetNode("server")
+
+	_, err := scope.TryGetDuration("timeout")
+	testError(t, err, `key "timeout": bad duration`)
+	testTrue(t, errors.Is(err, ErrParseDuration))
+	var keyErr KeyError
+	testTrue(t, errors.As(err, &keyErr))
+	testDeepEqual(t, keyErr, KeyError{Key: "timeout", Err: ErrParseDuration})
+
+	_, err = root.TryGetBool("server", "port")
+	testError(t, err, `key "server.port": bad value`)
+	testTrue(t, errors.Is(err, ErrParse))
+
+	_, err = root.TryGetInt("server.port")
+	var numErr *strconv.NumError
+	testTrue(t, errors.As(err, &numErr))
+	testDeepEqual(t, numErr.Num, "http")
+
+	for _, err := range []error{
+		func() error { _, err := root.TryGet("server.host"); return err }(),
+		func() error { _, err := root.TryGetNode("server.host"); return err }(),
+		func() error { _, err := root.TryGetStringSlice("server.host"); return err }(),
+		func() error { _, err := GetAs[int](root, "server.host"); return err }(),
+		root.GetInto(new(int), "server.host"),
+	} {
+		testError(t, err, `key "server.host": node not found`)
+		testTrue(t, errors.Is(err, ErrNodeNotFound))
+	}
+
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "server.timeout": bad duration`)
+	}()
+	root.MustGetDuration("server.timeout")
+}
+
 func TestIterate(t *testing.T) {
 	de := NewRoot()
 	de.SetKey("de.2", "zwei")
@@ -157,6 +239,385 @@ func TestMustGetters(t *testing.T) {
 	testTrue(t, !p(func() { root.MustGetDuration("duration.one") }))
 }
 
+func TestUintGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("port", uint(8080))
+	root.SetKey("mask", uint64(1<<63))
+	root.SetKey("int", 42)
+	root.SetKey("negative", -1)
+	root.SetKey("negativeStr", "-5")
+	root.SetKey("text", "x")
+	testError(t, root.MergeReader(strings.NewReader("big=18446744073709551615\nsmall=7\n"), true), "")
+
+	c := func(key string, expected uint64, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetUint(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("port", 8080, "")
+	c("mask", 1<<63, "")
+	c("int", 42, "")
+	c("big", 18446744073709551615, "")
+	c("small", 7, "")
+	c("negative", 0, `key "negative": Negative value: -1`)
+	c("negativeStr", 0, `key "negativeStr": Negative value: -5`)
+	c("text", 0, `key "text": strconv.ParseUint: parsing "x": invalid syntax`)
+	c("missing", 0, `key "missing": node not found`)
+
+	testDeepEqual(t, root.GetUint("port"), uint64(8080))
+	testDeepEqual(t, root.GetUint("negative"), uint64(0))
+	testDeepEqual(t, root.GetUintDefault(3, "negative"), uint64(3))
+	testDeepEqual(t, root.GetUintDefault(3, "small"), uint64(7))
+	testDeepEqual(t, root.MustGetUint("big"), uint64(18446744073709551615))
+	testDeepEqual(t, MustGetAs[uint64](root, "port"), uint64(8080))
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "negativeStr": Negative value: -5`)
+	}()
+	root.MustGetUint("negativeStr")
+}
+
+func TestFloat32Getters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("f32", float32(0.1))
+	root.SetKey("f64", 0.1)
+	root.SetKey("huge", 1e300)
+	root.SetKey("inf", math.Inf(-1))
+	testError(t, root.MergeReader(strings.NewReader("w:float=0.25\ns=1.5\nbig=1e40\nbigTyped:float=1e40\nbad=x\n"), true), "")
+
+	c := func(key string, expected float32, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetFloat32(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("f32", 0.1, "")
+	c("f64", 0.1, "")
+	c("inf", float32(math.Inf(-1)), "")
+	c("w", 0.25, "")
+	c("s", 1.5, "")
+	c("huge", 0, `key "huge": Out of float32 range: 1e+300`)
+	c("bigTyped", 0, `key "bigTyped": Out of float32 range: 1e+40`)
+	c("big", 0, `key "big": strconv.ParseFloat: parsing "1e40": value out of range`)
+	c("bad", 0, `key "bad": strconv.ParseFloat: parsing "x": invalid syntax`)
+	c("missing", 0, `key "missing": node not found`)
+
+	testDeepEqual(t, root.GetFloat32("w"), float32(0.25))
+	testDeepEqual(t, root.GetFloat32("huge"), float32(0))
+	testDeepEqual(t, root.GetFloat32Default(2, "huge"), float32(2))
+	testDeepEqual(t, root.GetFloat32Default(2, "s"), float32(1.5))
+	testDeepEqual(t, GetAsDefault[float32](root, 2, "missing"), float32(2))
+
+	// round trip
+	buf := bytes.Buffer{}
+	testError(t, root.WriteConf(&buf), "")
+	reloaded := NewRoot()
+	testError(t, reloaded.MergeReader(&buf, true), "")
+	testDeepEqual(t, reloaded.GetFloat32("w"), float32(0.25))
+}
+
+func TestURLGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("number", 8080)
+	root.SetKey("parsed", &url.URL{Scheme: "https", Host: "example.com"})
+	testError(t, root.MergeReader(strings.NewReader(
+		"api=https://user@api.example.com:8443/v1?x=1\nrelative=/v1/items\nnoscheme=example.com/v1\n"+
+			"hostport=localhost:8080\nempty=\nbad=http://[::1\ndsn=postgres://u:pw@/db\nbaddsn=postgres://u:pw@[::1/db\n"),
+		true), "")
+
+	c := func(key string, expected string, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetURL(key)
+		testError(t, err, expectedErr)
+		if expected == "" {
+			testTrue(t, val == nil)
+		} else {
+			testDeepEqual(t, val.String(), expected)
+		}
+	}
+	c("api", "https://user@api.example.com:8443/v1?x=1", "")
+	c("parsed", "https://example.com", "")
+	c("relative", "", `key "relative": Bad URL "/v1/items": missing scheme`)
+	c("noscheme", "", `key "noscheme": Bad URL "example.com/v1": missing scheme`)
+	c("hostport", "", `key "hostport": Bad URL "localhost:8080": missing host`)
+	c("number", "", `key "number": Bad URL "8080": missing scheme`)
+	c("empty", "", `key "empty": Bad URL "": missing scheme`)
+	c("bad", "", `key "bad": Bad URL: missing ']' in host`)
+	c("dsn", "", `key "dsn": Bad URL "postgres://u:xxxxx@/db": missing host`)
+	c("baddsn", "", `key "baddsn": Bad URL: missing ']' in host`)
+	c("missing", "", `key "missing": node not found`)
+
+	// the returned URLs are copies
+	root.GetURL("parsed").Host = "changed"
+	testDeepEqual(t, root.GetURL("parsed").Host, "example.com")
+
+	// GetURL only accepts absolute URLs, like TryGetURL
+	testDeepEqual(t, root.GetURL("api").Port(), "8443")
+	testTrue(t, root.GetURL("relative") == nil)
+	testTrue(t, root.GetURL("empty") == nil)
+	testTrue(t, root.GetURL("missing") == nil)
+
+	// GetRelativeURL accepts relative URLs, and tells missing keys from empty ones
+	testDeepEqual(t, root.GetRelativeURL("api").Port(), "8443")
+	testDeepEqual(t, root.GetRelativeURL("relative").Path, "/v1/items")
+	testDeepEqual(t, root.GetRelativeURL("empty"), &url.URL{})
+	testTrue(t, root.GetRelativeURL("missing") == nil)
+	testTrue(t, root.GetRelativeURL("bad") == nil)
+	testDeepEqual(t, root.View().GetRelativeURL("relative").Path, "/v1/items")
+
+	def := &url.URL{Scheme: "http", Host: "localhost"}
+	testTrue(t, root.GetURLDefault(def, "relative") == def)
+	testTrue(t, root.GetURLDefault(def, "missing") == def)
+	testDeepEqual(t, root.GetURLDefault(def, "api").Host, "api.example.com:8443")
+	testDeepEqual(t, root.View().GetURL("api").Path, "/v1")
+	u, err := GetAs[*url.URL](root, "api")
+	testError(t, err, "")
+	testDeepEqual(t, u.Scheme, "https")
+
+	testDeepEqual(t, root.MustGetURL("api").Hostname(), "api.example.com")
+	func() {
+		defer func() {
+			testDeepEqual(t, recover(), `Required conf key "relative": Bad URL "/v1/items": missing scheme`)
+		}()
+		root.MustGetURL("relative")
+	}()
+}
+
+func TestIPGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("typed", net.IPv4(192, 168, 0, 1))
+	testError(t, root.MergeReader(strings.NewReader(
+		"bind=10.0.0.1\nv6=2001:db8::1\nmapped=::ffff:10.0.0.2\nbad=10.0.0.256\n"+
+			"net=10.0.0.0/8\nnet6=2001:db8::/32\nbadnet=10.0.0.1\n"+
+			"allow=10.0.0.1, ::1\nallowbad=10.0.0.1,x\nlist.1=127.0.0.1\nlist.2=::1\n"), true), "")
+
+	c := func(key string, expected net.IP, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetIP(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("bind", net.IP{10, 0, 0, 1}, "")
+	c("typed", net.IP{192, 168, 0, 1}, "")
+	c("mapped", net.IP{10, 0, 0, 2}, "")
+	c("v6", net.ParseIP("2001:db8::1"), "")
+	c("bad", nil, `key "bad": Bad IP address "10.0.0.256"`)
+	c("net", nil, `key "net": Bad IP address "10.0.0.0/8"`)
+	c("missing", nil, `key "missing": node not found`)
+	_, err := root.TryGetIP("bad")
+	var keyErr KeyError
+	testTrue(t, errors.As(err, &keyErr))
+	testDeepEqual(t, keyErr.Key, "bad")
+
+	testTrue(t, root.GetIP("bad") == nil)
+	testTrue(t, root.GetIP("mapped").Equal(net.ParseIP("10.0.0.2")))
+	def := net.IPv6loopback
+	testDeepEqual(t, root.GetIPDefault(def, "bad"), def)
+	testDeepEqual(t, root.GetIPDefault(def, "missing"), def)
+	testDeepEqual(t, root.View().GetIPDefault(def, "bind"), net.IP{10, 0, 0, 1})
+
+	ipNet, err := root.TryGetCIDR("net")
+	testError(t, err, "")
+	testDeepEqual(t, ipNet.String(), "10.0.0.0/8")
+	testTrue(t, ipNet.Contains(root.GetIP("bind")))
+	testTrue(t, ipNet.Contains(root.GetIP("mapped")))
+	testTrue(t, root.GetCIDR("net6").Contains(root.GetIP("v6")))
+	_, err = root.TryGetCIDR("badnet")
+	testError(t, err, `key "badnet": Bad CIDR "10.0.0.1"`)
+	testTrue(t, root.GetCIDR("badnet") == nil)
+	_, defNet, _ := net.ParseCIDR("127.0.0.0/8")
+	testTrue(t, root.GetCIDRDefault(defNet, "badnet") == defNet)
+	testDeepEqual(t, root.GetCIDRDefault(defNet, "net").String(), "10.0.0.0/8")
+
+	testDeepEqual(t, root.GetIPSlice("allow"), []net.IP{{10, 0, 0, 1}, net.IPv6loopback})
+	testDeepEqual(t, root.GetIPSlice("list"), []net.IP{{127, 0, 0, 1}, net.IPv6loopback})
+	_, err = root.TryGetIPSlice("allowbad")
+	testError(t, err, `key "allowbad": Bad element 1 "x": Bad IP address "x"`)
+	testTrue(t, root.GetIPSlice("allowbad") == nil)
+}
+
+func TestRegexpGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("compiled", regexp.MustCompile("^x+$"))
+	testError(t, root.MergeReader(strings.NewReader("user=^[a-z]+$\nbad=(\nnum:int=42\n"), true), "")
+
+	re, err := root.TryGetRegexp("user")
+	testError(t, err, "")
+	testTrue(t, re.MatchString("alice"))
+	testTrue(t, !re.MatchString("Alice"))
+	testTrue(t, root.GetRegexp("user") == re)
+	testTrue(t, root.View().GetRegexp("user") == re)
+	testTrue(t, root.GetRegexp("compiled").MatchString("xx"))
+	testTrue(t, root.GetRegexp("num").MatchString("42"))
+
+	_, err = root.TryGetRegexp("bad")
+	testError(t, err, "key \"bad\": error parsing regexp: missing closing ): `(`")
+	testTrue(t, root.GetRegexp("bad") == nil)
+	_, err = root.TryGetRegexp("missing")
+	testError(t, err, `key "missing": node not found`)
+
+	// the cache is by pattern, so changing the value compiles another one,
+	// while nodes with the same pattern share it
+	root.SetKey("other", "^[a-z]+$")
+	testTrue(t, root.GetRegexp("other") == re)
+	root.SetKey("user", "^[0-9]+$")
+	changed := root.GetRegexp("user")
+	testTrue(t, changed != re)
+	testTrue(t, changed.MatchString("123"))
+	root.GetNode("bad").Value = "[a-c]"
+	testTrue(t, root.GetRegexp("bad").MatchString("b"))
+
+	testTrue(t, root.MustGetRegexp("user") == changed)
+	root.SetKey("bad", "(")
+	func() {
+		defer func() {
+			testDeepEqual(t, recover(), "Required conf key \"bad\": error parsing regexp: missing closing ): `(`")
+		}()
+		root.MustGetRegexp("bad")
+	}()
+
+	// concurrent reads compile the same pattern
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			testTrue(t, root.GetRegexp("user").MatchString("7"))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBytesGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("raw", []byte{0, 1})
+	testError(t, root.MergeReader(strings.NewReader(
+		"hmac.std=+/8=\nhmac.url=-_8=\nhmac.unpadded=-_8\nhmac.hex=hex:fbff\nhmac.upper=hex:FBFF\n"+
+			"hmac.empty=\nhmac.bad=s3cr3t!\nhmac.badhex=hex:s3cr3t\n"), true), "")
+
+	c := func(key string, expected []byte, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetBytes(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("raw", []byte{0, 1}, "")
+	c("hmac.std", []byte{0xfb, 0xff}, "")
+	c("hmac.url", []byte{0xfb, 0xff}, "")
+	c("hmac.unpadded", []byte{0xfb, 0xff}, "")
+	c("hmac.hex", []byte{0xfb, 0xff}, "")
+	c("hmac.upper", []byte{0xfb, 0xff}, "")
+	c("hmac.empty", []byte{}, "")
+	c("hmac.bad", nil, `key "hmac.bad": Bad base64 value`)
+	c("hmac.badhex", nil, `key "hmac.badhex": Bad hex value`)
+	c("missing", nil, `key "missing": node not found`)
+
+	testDeepEqual(t, root.GetBytes("hmac.url"), []byte{0xfb, 0xff})
+	testTrue(t, root.GetBytes("hmac.bad") == nil)
+	testDeepEqual(t, root.View().GetBytes("raw"), []byte{0, 1})
+}
+
+func TestJSONGetters(t *testing.T) {
+	type flag struct {
+		Enabled bool     `json:"enabled"`
+		Percent int      `json:"percent"`
+		Groups  []string `json:"groups"`
+	}
+	root := NewRoot()
+	root.SetKey("raw", []byte(`{"percent":5}`))
+	testError(t, root.MergeReader(strings.NewReader(
+		`flags.beta={"enabled":true,"groups":["qa"]}`+"\n"+
+			"flags.tree.enabled:bool=true\nflags.tree.percent:int=20\nflags.tree.groups.1=a\nflags.tree.groups.2=b\n"+
+			"flags.bad={\nflags.wrong.percent=many\n"), true), "")
+
+	var f flag
+	testError(t, root.TryGetJSON(&f, "flags.beta"), "")
+	testDeepEqual(t, f, flag{Enabled: true, Groups: []string{"qa"}})
+	f = flag{}
+	testError(t, root.TryGetJSON(&f, "flags.tree"), "")
+	testDeepEqual(t, f, flag{Enabled: true, Percent: 20, Groups: []string{"a", "b"}})
+	f = flag{}
+	testError(t, root.TryGetJSON(&f, "raw"), "")
+	testDeepEqual(t, f, flag{Percent: 5})
+
+	testError(t, root.TryGetJSON(&f, "flags.bad"), `key "flags.bad": unexpected end of JSON input`)
+	testError(t, root.TryGetJSON(&f, "flags.wrong"),
+		`key "flags.wrong": json: cannot unmarshal string into Go struct field flag.percent of type int`)
+	testError(t, root.TryGetJSON(&f, "missing"), `key "missing": node not found`)
+	var m map[string]interface{}
+	testError(t, root.TryGetJSON(m, "flags.beta"), `key "flags.beta": json: Unmarshal(non-pointer map[string]interface {})`)
+
+	testTrue(t, root.GetJSON(&m, "flags.beta"))
+	testDeepEqual(t, m["enabled"], true)
+	testTrue(t, !root.GetJSON(&m, "flags.bad"))
+	testTrue(t, root.View().GetJSON(&f, "flags.tree"))
+}
+
+func TestGetFirst(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("legacy.port", 8080)
+	root.SetKey("legacy.host", "old.example.com")
+	root.SetKey("servers.a.port", "81")
+	root.SetKey("servers.b.port", "82")
+	root.SetKey("servers.b.host", "b.example.com")
+
+	v, err := root.TryGetFirst("server.port", "legacy.port")
+	testError(t, err, "")
+	testDeepEqual(t, v, 8080)
+	v, err = root.TryGetFirst([]interface{}{"legacy", "host"}, "legacy.port")
+	testError(t, err, "")
+	testDeepEqual(t, v, "old.example.com")
+	_, err = root.TryGetFirst("server.port", "other.port")
+	testError(t, err, `key "server.port": node not found`)
+	_, err = root.TryGetFirst()
+	testError(t, err, `key "": node not found`)
+
+	// each path is searched through all scopes before the next one
+	scope := root.With(Args{"legacy.host": "scoped.example.com"})
+	testDeepEqual(t, scope.GetStringFirst("server.host", "legacy.host"), "scoped.example.com")
+	testDeepEqual(t, scope.GetIntFirst("server.port", "legacy.port"), 8080)
+	scope.SetKey("server.port", 9090)
+	testDeepEqual(t, scope.GetIntFirst("server.port", "legacy.port"), 9090)
+	testDeepEqual(t, root.GetIntFirst("server.port", "legacy.port"), 8080)
+
+	// a wildcard path returns its first match, if any
+	testDeepEqual(t, root.GetIntFirst("servers.*.port", "legacy.port"), 81)
+	testDeepEqual(t, root.GetStringFirst("servers.*.host", "legacy.host"), "b.example.com")
+	testDeepEqual(t, root.GetStringFirst("servers.*.user", "legacy.host"), "old.example.com")
+
+	testDeepEqual(t, root.GetStringFirst("a", "b"), "")
+	testDeepEqual(t, root.GetIntFirst("legacy.host"), 0)
+	testDeepEqual(t, root.View().GetIntFirst("server.port", "legacy.port"), 8080)
+}
+
+func TestSizeGetters(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("int", 4096)
+	root.SetKey("negative", -1)
+	testError(t, root.MergeReader(strings.NewReader("cache=1.5GiB\nbuffer=64kb\ntyped:int=10\nbad=12XB\n"), true), "")
+
+	c := func(key string, expected int64, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetSize(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("int", 4096, "")
+	c("typed", 10, "")
+	c("cache", 3<<29, "")
+	c("buffer", 64000, "")
+	c("negative", 0, `key "negative": Bad size "-1": out of range`)
+	c("bad", 0, `key "bad": Bad size "12XB": unknown unit "XB"`)
+	c("missing", 0, `key "missing": node not found`)
+
+	testDeepEqual(t, root.GetSize("buffer"), int64(64000))
+	testDeepEqual(t, root.GetSize("bad"), int64(0))
+	testDeepEqual(t, root.GetSizeDefault(1024, "bad"), int64(1024))
+	testDeepEqual(t, root.GetSizeDefault(1024, "missing"), int64(1024))
+	testDeepEqual(t, root.GetSizeDefault(1024, "cache"), int64(3<<29))
+	testDeepEqual(t, root.View().GetSize("cache"), int64(3<<29))
+}
+
 func TestExtraGetters(t *testing.T) {
 	root := NewRoot()
 	root.SetKey("main.string.one", "1")
@@ -186,6 +647,204 @@ func TestExtraGetters(t *testing.T) {
 
 }
 
+func TestGetMap(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.http.port", 80)
+	root.SetKey("server.http.host", "localhost")
+	root.SetKey("server.https.port", 443)
+	root.SetKey("server.https.host", "example.com")
+
+	// no star: the children of the matched node
+	testDeepEqual(t, root.GetMap("server.http"), Args{"port": "80", "host": "localhost"})
+	testDeepEqual(t, root.GetMap("server", "https"), Args{"port": "443", "host": "example.com"})
+	testDeepEqual(t, root.GetMap("server.missing"), Args{})
+	testDeepEqual(t, root.GetNode("server.http").GetMap(), Args{"port": "80", "host": "localhost"})
+
+	// star first and last
+	testDeepEqual(t, root.GetMap("*"), Args{}) // branches without values are skipped
+	testDeepEqual(t, root.GetNode("server").GetMap("*.port"), Args{"http": "80", "https": "443"})
+	testDeepEqual(t, root.GetMap("server.http.*"), root.GetMap("server.http"))
+
+	// the last star is used as the key
+	testDeepEqual(t, root.GetMap("*.*.port"), Args{"http": "80", "https": "443"})
+	testDeepEqual(t, root.GetStringMap("server.https"), StrArgs{"port": "443", "host": "example.com"})
+}
+
+func TestGetValues_Branches(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("weights.a", 1)
+	root.SetKey("weights.b", 2)
+	root.SetKey("weights.b.extra", 5) // b is now a branch, with a value
+	root.SetKey("weights.c.extra", 7) // c is a branch, without a value
+	root.FillKey("weights.d", 3)
+	root.FillKey("weights.d", 4) // d is a branch, without a value
+
+	testDeepEqual(t, root.GetValues("weights.*"), []Value{1, 2})
+	testDeepEqual(t, root.GetLeafValues("weights.*"), []Value{1})
+	testDeepEqual(t, root.GetStringValues("weights.*"), []string{"1", "2"})
+	testDeepEqual(t, root.GetMap("weights"), Args{"a": "1", "b": "2"})
+	testDeepEqual(t, root.GetMap("weights.*"), Args{"a": "1", "b": "2"})
+	testDeepEqual(t, root.GetValues("weights.*.extra"), []Value{5, 7})
+
+	// leaves without a value are still returned
+	root.AddNode("weights.e")
+	testDeepEqual(t, root.GetValues("weights.*"), []Value{1, 2, nil})
+	testDeepEqual(t, root.GetStringValues("weights.*"), []string{"1", "2", ""})
+}
+
 func TestPreventSegfault(t *testing.T) {
 	testTrue(t, (*Node)(nil).GetNode("missing.key") == nil)
 }
+
+func TestStringSliceGetters(t *testing.T) {
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader("typed:[]string=a,b\\,c\ncsv=x,y\n"), true), "")
+	root.AddNode("pushed").PushValues("one", 2, "three")
+	root.AddNode("empty")
+	root.SetKey("single", "solo")
+	root.SetKey("db.host", "localhost")
+	root.SetKey("db.port", 5432)
+	root.AddNode("nested").PushValues("a").Push().SetKey("b", "c")
+
+	c := func(key string, expected []string, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetStringSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("typed", []string{"a", "b,c"}, "")
+	c("csv", []string{"x", "y"}, "")
+	c("pushed", []string{"one", "2", "three"}, "")
+	c("single", []string{"solo"}, "")
+	c("empty", []string{}, "")
+	c("missing", nil, `key "missing": node not found`)
+	c("db", nil, `key "db": Not a list: key "host" is not numeric`)
+	c("nested", nil, `key "nested": Not a list: element "2" has children`)
+
+	testTrue(t, root.GetStringSlice("db") == nil)
+	testDeepEqual(t, root.GetStringSlice("pushed"), []string{"one", "2", "three"})
+	testTrue(t, root.GetStringSlice("empty") != nil)
+	testTrue(t, root.GetStringSlice("missing") == nil)
+	testDeepEqual(t, root.GetStringSliceDefault([]string{"d"}, "missing"), []string{"d"})
+	testDeepEqual(t, root.GetStringSliceDefault([]string{"d"}, "csv"), []string{"x", "y"})
+}
+
+func TestIntSliceGetters(t *testing.T) {
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader("ids:[]int=1,2,3\ncsv=4,5\nbad=6,x,8\n"), true), "")
+	root.AddNode("pushed").PushValues(7, "8")
+	root.AddNode("badPushed").PushValues(1, "two")
+
+	c := func(key string, expected []int, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetIntSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("ids", []int{1, 2, 3}, "")
+	c("csv", []int{4, 5}, "")
+	c("pushed", []int{7, 8}, "")
+	c("bad", nil, `key "bad": Bad element 1 "x": strconv.ParseInt: parsing "x": invalid syntax`)
+	c("badPushed", nil, `key "badPushed": Bad element 1 "two": strconv.ParseInt: parsing "two": invalid syntax`)
+	c("missing", nil, `key "missing": node not found`)
+
+	testDeepEqual(t, root.GetIntSlice("ids"), []int{1, 2, 3})
+	testTrue(t, root.GetIntSlice("bad") == nil)
+	testDeepEqual(t, root.GetIntSliceDefault([]int{0}, "bad"), []int{0})
+	testDeepEqual(t, root.GetIntSliceDefault([]int{0}, "missing"), []int{0})
+	testDeepEqual(t, root.GetIntSliceDefault([]int{0}, "csv"), []int{4, 5})
+}
+
+func TestFloatAndBoolSliceGetters(t *testing.T) {
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader(
+		"weights:[]float=0.5,1.5\ncsv=2,2.5\nbad=1,x\nflags:[]bool=true,false\nflagsCSV=on,off,1\nbadFlags=true,maybe\n"), true), "")
+	root.AddNode("pushed").PushValues(1.25, "3")
+	root.AddNode("pushedFlags").PushValues(true, "f")
+
+	f := func(key string, expected []float64, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetFloatSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	f("weights", []float64{0.5, 1.5}, "")
+	f("csv", []float64{2, 2.5}, "")
+	f("pushed", []float64{1.25, 3}, "")
+	f("bad", nil, `key "bad": Bad element 1 "x": strconv.ParseFloat: parsing "x": invalid syntax`)
+	f("missing", nil, `key "missing": node not found`)
+	testDeepEqual(t, root.GetFloatSlice("csv"), []float64{2, 2.5})
+	testDeepEqual(t, root.GetFloatSliceDefault([]float64{1}, "bad"), []float64{1})
+
+	b := func(key string, expected []bool, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetBoolSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	b("flags", []bool{true, false}, "")
+	b("flagsCSV", []bool{true, false, true}, "")
+	b("pushedFlags", []bool{true, false}, "")
+	b("badFlags", nil, `key "badFlags": Bad element 1 "maybe": bad value`)
+	b("missing", nil, `key "missing": node not found`)
+	testTrue(t, root.GetBoolSlice("badFlags") == nil)
+	testDeepEqual(t, root.GetBoolSliceDefault([]bool{true}, "missing"), []bool{true})
+	testDeepEqual(t, root.GetBoolSliceDefault(nil, "flags"), []bool{true, false})
+}
+
+func TestDurationSliceGetters(t *testing.T) {
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader("retry.backoff:[]duration=1s,5s,30s,2m\ncsv=1h,1d\nbad=1s,soon\nempty=\n"), true), "")
+	root.AddNode("pushed").PushValues(time.Minute, "10s")
+
+	c := func(key string, expected []time.Duration, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetDurationSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("retry.backoff", []time.Duration{time.Second, 5 * time.Second, 30 * time.Second, 2 * time.Minute}, "")
+	c("csv", []time.Duration{time.Hour, 24 * time.Hour}, "")
+	c("pushed", []time.Duration{time.Minute, 10 * time.Second}, "")
+	c("bad", nil, `key "bad": Bad element 1 "soon": bad duration`)
+	c("empty", nil, `key "empty": Bad element 0 "": bad duration`)
+	c("missing", nil, `key "missing": node not found`)
+	_, err := root.TryGetDurationSlice("empty")
+	testTrue(t, errors.Is(err, ErrParseDuration))
+	_, err = root.TryGetDuration("empty")
+	testTrue(t, errors.Is(err, ErrParseDuration))
+
+	testDeepEqual(t, root.GetDurationSlice("csv"), []time.Duration{time.Hour, 24 * time.Hour})
+	testTrue(t, root.GetDurationSlice("bad") == nil)
+	testDeepEqual(t, root.GetDurationSliceDefault([]time.Duration{time.Second}, "bad"), []time.Duration{time.Second})
+}
+
+func TestTimeGetters(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC) }
+	root := NewRoot()
+	testError(t, root.MergeReader(strings.NewReader("holidays:[]date=2020-01-01,2020-01-06\ncsv=2020-01-02,2020-01-03T00:00:00Z\nbad=2020-01-01,never\nwhen=2020-01-04\n"), true), "")
+	root.AddNode("pushed").PushValues(day(5), "2020-01-07")
+
+	c := func(key string, expected []time.Time, expectedErr string) {
+		t.Helper()
+		val, err := root.TryGetTimeSlice(key)
+		testError(t, err, expectedErr)
+		testDeepEqual(t, val, expected)
+	}
+	c("holidays", []time.Time{day(1), day(6)}, "")
+	c("csv", []time.Time{day(2), day(3)}, "")
+	c("pushed", []time.Time{day(5), day(7)}, "")
+	c("bad", nil, `key "bad": Bad element 1 "never": Bad time format: never`)
+	c("missing", nil, `key "missing": node not found`)
+	testDeepEqual(t, root.GetTimeSlice("csv"), []time.Time{day(2), day(3)})
+	testTrue(t, root.GetTimeSlice("bad") == nil)
+
+	testDeepEqual(t, root.GetTimeDefault(day(9), "when"), day(4))
+	testDeepEqual(t, root.GetTimeDefault(day(9), "bad"), day(9))
+	testDeepEqual(t, root.GetTimeDefault(day(9), "missing"), day(9))
+	testDeepEqual(t, root.MustGetTime("when"), day(4))
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "bad": Bad time format: 2020-01-01,never`)
+	}()
+	root.MustGetTime("bad")
+}
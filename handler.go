@@ -0,0 +1,187 @@
+package trix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// redactedValue replaces the values of redacted nodes; see HandlerOptions.
+const redactedValue = "[redacted]"
+
+// HandlerOptions changes how the handler returned by Handler serves the tree.
+type HandlerOptions struct {
+	// Redact lists specs, relative to the handler's node, of the nodes whose
+	// values (and those of their descendants) are replaced by "[redacted]",
+	// e.g. "db.password" or "*.token". A "*" matches any single key.
+	Redact []string
+
+	// Indent is the number of spaces used to indent JSON responses, unless
+	// the request has an "indent" query parameter; if zero, JSON is compact.
+	Indent int
+}
+
+// Handler returns an HTTP handler that serves the node's descendants, for
+// debugging purposes. Only GET (and HEAD) requests are accepted. The URL
+// path is mapped to the path of a descendant, e.g. "/server/timeout" serves
+// "server.timeout", including parent scopes; a "q" query parameter can be
+// used instead, to serve all nodes matching a spec, as accepted by GetNodes,
+// as a JSON array.
+//
+// The "format" query parameter selects the output: "json" (the default), as
+// returned by MarshalJSON, "conf", as written by WriteConf, or "tree", as
+// written by Dump; "conf" is not supported with "q". The "indent" query
+// parameter overrides HandlerOptions.Indent.
+//
+// Errors, including missing paths, are returned as a JSON object with an
+// "error" key, e.g. `{"error":"not found: server.timeout"}`.
+func (node *Node) Handler(opts HandlerOptions) http.Handler {
+	redact := make([][]string, len(opts.Redact))
+	for i, spec := range opts.Redact {
+		redact[i] = ParseKeys([]interface{}{spec})
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeHandlerError(w, http.StatusMethodNotAllowed, "method not allowed: %s", r.Method)
+			return
+		}
+
+		query := r.URL.Query()
+		format := query.Get("format")
+		indent := opts.Indent
+		if s := query.Get("indent"); s != "" {
+			var err error
+			if indent, err = strconv.Atoi(s); err != nil || indent < 0 {
+				writeHandlerError(w, http.StatusBadRequest, "invalid indent: %q", s)
+				return
+			}
+		}
+
+		var nodes NodeList
+		spec := query.Get("q")
+		if spec != "" {
+			if format == "conf" {
+				writeHandlerError(w, http.StatusBadRequest, "format not supported with q: %s", format)
+				return
+			}
+			for _, found := range node.GetNodes(spec) {
+				nodes = append(nodes, internalRedactedCopy(node, found, redact))
+			}
+		} else {
+			path := strings.Trim(r.URL.Path, "/")
+			found := node
+			if path != "" {
+				found = node.GetByPathString(joinPath(strings.Split(path, "/")))
+			}
+			if found == nil {
+				writeHandlerError(w, http.StatusNotFound, "not found: %s", strings.Replace(path, "/", ".", -1))
+				return
+			}
+			nodes = NodeList{internalRedactedCopy(node, found, redact)}
+		}
+
+		buf := bytes.Buffer{}
+		switch format {
+		case "", "json":
+			var (
+				byt []byte
+				err error
+			)
+			if spec != "" {
+				byt, err = nodes.ToJSON(strings.Repeat(" ", indent))
+			} else if indent > 0 {
+				byt, err = json.MarshalIndent(nodes[0], "", strings.Repeat(" ", indent))
+			} else {
+				byt, err = json.Marshal(nodes[0])
+			}
+			if err != nil {
+				writeHandlerError(w, http.StatusInternalServerError, "%v", err)
+				return
+			}
+			buf.Write(byt)
+			buf.WriteByte('\n')
+			w.Header().Set("Content-Type", "application/json")
+		case "conf":
+			if err := nodes[0].WriteConf(&buf); err != nil {
+				writeHandlerError(w, http.StatusInternalServerError, "%v", err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		case "tree":
+			for _, n := range nodes {
+				n.Dump(&buf, false)
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		default:
+			writeHandlerError(w, http.StatusBadRequest, "unknown format: %s", format)
+			return
+		}
+		w.Write(buf.Bytes())
+	})
+}
+
+// writeHandlerError writes a JSON error response.
+func writeHandlerError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	byt, _ := json.Marshal(map[string]string{"error": fmt.Sprintf(format, args...)})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(byt, '\n'))
+}
+
+// internalRedactedCopy returns a copy of node, in a new root but with the
+// same path, where the values of the nodes matching the redact specs,
+// relative to base, and their descendants, are replaced.
+func internalRedactedCopy(base, node *Node, redact [][]string) *Node {
+	path := node.Path()
+	copied := NewRoot()
+	if len(path) > 0 {
+		parent := copied
+		for _, key := range path[:len(path)-1] {
+			parent = internalSet(parent, []string{key}, nil)
+		}
+		copied = internalMerge(parent, node)
+	} else {
+		for _, key := range node.ChildKeys {
+			internalMerge(copied, node.Children[key])
+		}
+	}
+
+	depth := base.Depth()
+	copied.FindFunc(func(n *Node) bool {
+		if n.Value == nil {
+			return false
+		}
+		keys := n.Path()
+		if len(keys) < depth {
+			return false
+		}
+		keys = keys[depth:]
+		for _, spec := range redact {
+			if matchSpecPrefix(spec, keys) {
+				n.Value = redactedValue
+				break
+			}
+		}
+		return false
+	})
+	return copied
+}
+
+// matchSpecPrefix returns whether the spec matches the keys, or any of their
+// prefixes. A "*" in the spec matches any key.
+func matchSpecPrefix(spec, keys []string) bool {
+	if len(spec) > len(keys) {
+		return false
+	}
+	for i, key := range spec {
+		if key != "*" && key != keys[i] {
+			return false
+		}
+	}
+	return true
+}
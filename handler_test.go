@@ -0,0 +1,72 @@
+package trix
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.host", "localhost")
+	root.SetKey("server.timeout", 30*time.Second)
+	root.SetKey("server.admin.token", "abc")
+	root.SetKey("db.main.timeout", 5)
+	root.SetKey("db.main.password", "hunter2")
+	root.SetKey("db.replica.timeout", 10)
+	handler := root.Handler(HandlerOptions{Redact: []string{"*.*.password", "server.admin"}})
+
+	c := func(method, target string, status int, contentType, body string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		testDeepEqual(t, w.Code, status)
+		testDeepEqual(t, w.Header().Get("Content-Type"), contentType)
+		testDeepEqual(t, w.Body.String(), body)
+	}
+	const (
+		typeJSON = "application/json"
+		typeText = "text/plain; charset=utf-8"
+	)
+
+	// paths
+	c("GET", "/server/host", 200, typeJSON, "\"localhost\"\n")
+	c("GET", "/db/main", 200, typeJSON, `{"timeout":5,"password":"[redacted]"}`+"\n")
+	c("GET", "/server/admin", 200, typeJSON, `{"token":"[redacted]"}`+"\n")
+	c("GET", "/db/main/?indent=2", 200, typeJSON, "{\n  \"timeout\": 5,\n  \"password\": \"[redacted]\"\n}\n")
+	c("GET", "/db", 200, typeJSON, `{"main":{"timeout":5,"password":"[redacted]"},"replica":{"timeout":10}}`+"\n")
+	c("GET", "/db/*", 404, typeJSON, `{"error":"not found: db.*"}`+"\n")
+	c("GET", "/db/backup", 404, typeJSON, `{"error":"not found: db.backup"}`+"\n")
+
+	// formats
+	c("GET", "/db?format=conf", 200, typeText, strings.Join([]string{
+		"main.timeout:int=5",
+		"main.password=[redacted]",
+		"replica.timeout:int=10",
+		"",
+	}, "\n"))
+	c("GET", "/server?format=tree", 200, typeText, strings.Join([]string{
+		"server.host=localhost",
+		"server.timeout=30s",
+		"server.admin.token=[redacted]",
+		"",
+	}, "\n"))
+	c("GET", "/?format=xml", 400, typeJSON, `{"error":"unknown format: xml"}`+"\n")
+	c("GET", "/?indent=x", 400, typeJSON, `{"error":"invalid indent: \"x\""}`+"\n")
+
+	// queries
+	c("GET", "/?q=db.*.timeout", 200, typeJSON, "[5,10]\n")
+	c("GET", "/?q=db.*.password", 200, typeJSON, "[\"[redacted]\"]\n")
+	c("GET", "/?q=db.*.missing", 200, typeJSON, "[]\n")
+	c("GET", "/?q=db.*.timeout&format=tree", 200, typeText, "db.main.timeout=5\ndb.replica.timeout=10\n")
+	c("GET", "/?q=db&format=conf", 400, typeJSON, `{"error":"format not supported with q: conf"}`+"\n")
+
+	// read-only
+	c("PUT", "/server/host", 405, typeJSON, `{"error":"method not allowed: PUT"}`+"\n")
+
+	// the original tree is untouched, and redaction is relative to the node
+	testDeepEqual(t, root.GetString("db.main.password"), "hunter2")
+	handler = root.GetNode("db").Handler(HandlerOptions{Redact: []string{"*.password"}, Indent: 1})
+	c("GET", "/main", 200, typeJSON, "{\n \"timeout\": 5,\n \"password\": \"[redacted]\"\n}\n")
+}
@@ -40,3 +40,22 @@ func testTrue(t *testing.T, value bool) {
 		t.Errorf(`Expected true, got "%v"`, value)
 	}
 }
+
+// testConsistent checks that each node's Children and ChildKeys match, and
+// that children point back to their parent.
+func testConsistent(t *testing.T, node *Node) {
+	t.Helper()
+	if len(node.Children) != len(node.ChildKeys) {
+		t.Errorf(`Node "%s": %d children but %d child keys`, node.Key, len(node.Children), len(node.ChildKeys))
+	}
+	for _, key := range node.ChildKeys {
+		child, found := node.Children[key]
+		if !found {
+			t.Errorf(`Node "%s": missing child "%s"`, node.Key, key)
+			continue
+		} else if child.Parent != node {
+			t.Errorf(`Node "%s": child "%s" has a different parent`, node.Key, key)
+		}
+		testConsistent(t, child)
+	}
+}
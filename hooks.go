@@ -0,0 +1,141 @@
+package trix
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// hookSet keeps the hooks registered on a base root; they're looked up from
+// it by its scopes.
+type hookSet struct {
+	onAccess      atomic.Value // func(path []string, found bool)
+	onMiss        atomic.Value // *missHook
+	onDefaultMiss atomic.Value // *missHook
+
+	onConversionError atomic.Value // func(path []string, err error)
+}
+
+// hooks returns the base root's hooks, creating them if necessary.
+func (node *Node) hooks() *hookSet {
+	state := node.baseRoot().ensureRootState()
+	if hooks := state.hookSet.Load(); hooks != nil {
+		return hooks
+	}
+	state.hookSet.CompareAndSwap(nil, &hookSet{})
+	return state.hookSet.Load()
+}
+
+// OnAccess registers fn on the node's base root, the one its scope was
+// created from, so that it applies to it and to all its scopes, including
+// existing ones, to be called once for each getter lookup, with the absolute
+// path that was looked up and whether any node was found, including on parent
+// scopes. A nil fn removes the hook. Hooks can be registered or replaced at
+// any time, even while other goroutines use the tree. Return the original
+// node.
+func (node *Node) OnAccess(fn func(path []string, found bool)) *Node {
+	node.hooks().onAccess.Store(fn)
+	return node
+}
+
+// accessHook returns the hook registered with OnAccess, or nil.
+func (hooks *hookSet) accessHook() func(path []string, found bool) {
+	if hooks == nil {
+		return nil
+	}
+	fn, _ := hooks.onAccess.Load().(func(path []string, found bool))
+	return fn
+}
+
+// maxMissPaths is the number of paths remembered by each miss hook, so that
+// it's only called once for each; see OnMiss.
+const maxMissPaths = 10000
+
+// missHook calls fn once for each path that wasn't found, remembering the
+// most recently missed maxMissPaths paths.
+type missHook struct {
+	fn    func(path string)
+	mutex sync.Mutex
+	paths map[string]*list.Element
+	order *list.List // most recently missed first
+}
+
+// OnMiss registers fn on the node's base root, like OnAccess, to be called
+// when a getter finds no node, including on parent scopes, with the absolute
+// path that was looked up. Lookups done by the Default getters (e.g.
+// GetIntDefault and GetAsDefault), which are often intentional, are reported
+// to OnDefaultMiss instead. fn is called only once for each path, as long as
+// it's among the 10000 most recently missed ones. A nil fn removes the hook.
+// Return the original node.
+func (node *Node) OnMiss(fn func(path string)) *Node {
+	node.hooks().onMiss.Store(newMissHook(fn))
+	return node
+}
+
+// OnDefaultMiss is like OnMiss, but fn is only called for the lookups done
+// by the Default getters.
+func (node *Node) OnDefaultMiss(fn func(path string)) *Node {
+	node.hooks().onDefaultMiss.Store(newMissHook(fn))
+	return node
+}
+
+// newMissHook returns a hook calling fn, or nil if fn is nil.
+func newMissHook(fn func(path string)) *missHook {
+	if fn == nil {
+		return nil
+	}
+	return &missHook{fn: fn, paths: map[string]*list.Element{}, order: list.New()}
+}
+
+// missHook returns the hook registered with OnDefaultMiss, if withDefault is
+// true, or with OnMiss otherwise; or nil.
+func (hooks *hookSet) missHook(withDefault bool) *missHook {
+	if hooks == nil {
+		return nil
+	}
+	registered := &hooks.onMiss
+	if withDefault {
+		registered = &hooks.onDefaultMiss
+	}
+	hook, _ := registered.Load().(*missHook)
+	return hook
+}
+
+// miss calls the hook's fn with the path, unless it was recently missed.
+func (hook *missHook) miss(path string) {
+	hook.mutex.Lock()
+	if elem, found := hook.paths[path]; found {
+		hook.order.MoveToFront(elem)
+		hook.mutex.Unlock()
+		return
+	}
+	hook.paths[path] = hook.order.PushFront(path)
+	if hook.order.Len() > maxMissPaths {
+		oldest := hook.order.Remove(hook.order.Back())
+		delete(hook.paths, oldest.(string))
+	}
+	hook.mutex.Unlock()
+	hook.fn(path)
+}
+
+// OnConversionError registers fn on the node's base root, like OnAccess, to
+// be called when a Default or simple getter (e.g. GetIntDefault or GetInt)
+// finds a node, but can't convert its value, with the node's absolute path
+// and the conversion error. Those getters return the default value either
+// way, so this is the way to tell bad values, like typos in a configuration
+// file, from missing ones. A nil fn removes the hook. Return the original
+// node.
+func (node *Node) OnConversionError(fn func(path []string, err error)) *Node {
+	node.hooks().onConversionError.Store(fn)
+	return node
+}
+
+// conversionErrorHook returns the hook registered with OnConversionError, or
+// nil.
+func (hooks *hookSet) conversionErrorHook() func(path []string, err error) {
+	if hooks == nil {
+		return nil
+	}
+	fn, _ := hooks.onConversionError.Load().(func(path []string, err error))
+	return fn
+}
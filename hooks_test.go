@@ -0,0 +1,209 @@
+package trix
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnAccess(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("settings.labels.1.default", "label:Zip code")
+	root.SetKey("settings.labels.1.continue", "1")
+	root.SetKey("settings.labels.2.keys.1", "category")
+	root.SetKey("settings.labels.2.3041.value", "suffix:(of house)")
+	root.SetKey("category", 3041)
+
+	calls := []string{}
+	root.OnAccess(func(path []string, found bool) {
+		calls = append(calls, fmt.Sprintf("%s %v", joinPath(path), found))
+	})
+	scope := root.With(Args{"type": "s"})
+	testDeepEqual(t, scope.GetSettings("settings.labels"), Reply{"label": {"Zip code"}, "suffix": {"(of house)"}})
+	testDeepEqual(t, calls, []string{
+		"settings.labels true",
+		"settings.labels.* true",
+		"settings.labels.1.continue true",
+		"settings.labels.2.keys.* true",
+		"category true",
+		"settings.labels.2.continue false",
+	})
+
+	// once per lookup, regardless of the scopes probed
+	calls = calls[:0]
+	scope.GetString("type")
+	scope.GetNode("settings").GetString("missing")
+	scope.GetStringDefault("none", "category.value")
+	testDeepEqual(t, calls, []string{"type true", "settings true", "settings.missing false", "category.value false"})
+
+	// removing the hook
+	root.OnAccess(nil)
+	scope.GetString("type")
+	testDeepEqual(t, len(calls), 4)
+}
+
+func TestOnMiss(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "localhost")
+	root.SetKey("db.port", 5432)
+	root.SetKey("settings.labels.1.keys.1", "category")
+	root.SetKey("settings.labels.1.3041.value", "house")
+	root.SetKey("settings.labels.2.default", "any")
+
+	misses, defaultMisses := []string{}, []string{}
+	root.OnMiss(func(path string) { misses = append(misses, path) })
+	root.OnDefaultMiss(func(path string) { defaultMisses = append(defaultMisses, path) })
+	scope := root.With(Args{"db.user": "app"})
+
+	// found, also on a parent scope
+	scope.GetString("db.host")
+	scope.GetString("db.user")
+	scope.GetNode("db").GetInt("port")
+	testDeepEqual(t, len(misses), 0)
+
+	// once per path
+	for i := 0; i < 3; i++ {
+		scope.GetString("db.hots")
+		scope.GetNode("db").GetInt("prot")
+		root.GetNodes("cache.*")
+	}
+	testDeepEqual(t, misses, []string{"db.hots", "db.prot", "cache.*"})
+
+	// lookups with defaults are reported separately
+	scope.GetIntDefault(10, "db.timeout")
+	scope.GetDurationDefault(0, "db.timeout")
+	GetAsDefault(scope, "x", "db.name")
+	scope.GetStringDefault("x", "db.host")
+	testDeepEqual(t, defaultMisses, []string{"db.timeout", "db.name"})
+	scope.GetInt("db.timeout")
+	testDeepEqual(t, misses, []string{"db.hots", "db.prot", "cache.*", "db.timeout"})
+
+	// internal lookups of optional keys are done with defaults
+	testDeepEqual(t, scope.GetSettings("settings.labels"), Reply{"value": {"any"}})
+	testDeepEqual(t, misses[4:], []string{"category"})
+	testDeepEqual(t, defaultMisses[2:], []string{"settings.labels.2.continue"})
+
+	// removing the hooks
+	root.OnMiss(nil).OnDefaultMiss(nil)
+	scope.GetString("db.other")
+	scope.GetStringDefault("", "db.other")
+	testDeepEqual(t, len(misses), 5)
+	testDeepEqual(t, len(defaultMisses), 3)
+}
+
+func TestOnMiss_Evict(t *testing.T) {
+	root := NewRoot()
+	calls := map[string]int{}
+	root.OnMiss(func(path string) { calls[path]++ })
+	for i := 0; i <= maxMissPaths; i++ {
+		root.Get("missing", i)
+		if i%100 == 0 {
+			// recently missed paths are kept
+			root.Get("missing.0")
+		}
+	}
+	testDeepEqual(t, len(calls), maxMissPaths+1)
+	root.Get("missing.0")
+	root.Get("missing.2")
+	root.Get("missing.1") // evicted
+	testDeepEqual(t, calls["missing.0"], 1)
+	testDeepEqual(t, calls["missing.2"], 1)
+	testDeepEqual(t, calls["missing.1"], 2)
+	testDeepEqual(t, len(root.rootState().hookSet.Load().missHook(false).paths), maxMissPaths)
+}
+
+func TestOnConversionError(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.port", "80800x")
+	root.SetKey("server.timeout", "30s")
+	root.SetKey("server.hosts", "a,b")
+	root.SetKey("flags", "{")
+
+	calls := []string{}
+	root.OnConversionError(func(path []string, err error) {
+		calls = append(calls, fmt.Sprintf("%s: %v", joinPath(path), err))
+	})
+	scope := root.With(Args{"server.debug": "maybe"})
+
+	// bad values are reported, missing or good ones aren't
+	testDeepEqual(t, scope.GetIntDefault(80, "server.port"), 80)
+	testDeepEqual(t, scope.GetIntDefault(80, "server.missing"), 80)
+	testDeepEqual(t, scope.GetDuration("server.timeout"), 30*time.Second)
+	testDeepEqual(t, scope.GetBool("server.debug"), false)
+	testDeepEqual(t, scope.GetIntSlice("server.hosts"), []int(nil))
+	testDeepEqual(t, GetAsDefault(scope, 1.5, "server.port"), 1.5)
+	testDeepEqual(t, scope.GetIntFirst("server.new", "server.port"), 0)
+	testTrue(t, !scope.GetJSON(&map[string]int{}, "flags"))
+	testDeepEqual(t, calls, []string{
+		`server.port: strconv.ParseInt: parsing "80800x": invalid syntax`,
+		"server.debug: bad value",
+		`server.hosts: Bad element 0 "a": strconv.ParseInt: parsing "a": invalid syntax`,
+		`server.port: strconv.ParseFloat: parsing "80800x": invalid syntax`,
+		`server.port: strconv.ParseInt: parsing "80800x": invalid syntax`,
+		"flags: unexpected end of JSON input",
+	})
+
+	// Try and Must getters return their errors instead
+	calls = nil
+	_, err := scope.TryGetInt("server.port")
+	testError(t, err, `key "server.port": strconv.ParseInt: parsing "80800x": invalid syntax`)
+	func() {
+		defer func() { recover() }()
+		scope.MustGetInt("server.port")
+	}()
+	testTrue(t, len(calls) == 0)
+
+	root.OnConversionError(nil)
+	testDeepEqual(t, scope.GetInt("server.port"), 0)
+	testTrue(t, len(calls) == 0)
+}
+
+func TestHooks_Concurrent(t *testing.T) {
+	// registering the first hooks and aliases while the tree is read is safe
+	root := NewRoot()
+	root.SetKey("database.host", "localhost")
+	var accessed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			root.OnAccess(func([]string, bool) { accessed.Add(1) })
+			root.Alias("db", "database")
+		}()
+		go func() {
+			defer wg.Done()
+			root.GetString("db.host")
+			root.GetIntDefault(0, "database.host")
+		}()
+	}
+	wg.Wait()
+	testDeepEqual(t, root.GetString("db.host"), "localhost")
+	testTrue(t, accessed.Load() > 0)
+}
+
+func TestHooks_ExistingScopes(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("database.host", "localhost")
+	scope := root.With(Args{"request.id": 1})
+	nested := scope.With()
+
+	// registered after the scopes were created
+	var paths []string
+	root.OnAccess(func(path []string, found bool) { paths = append(paths, joinPath(path)) })
+	root.Alias("db.host", "database.host")
+	testDeepEqual(t, scope.GetString("db.host"), "localhost")
+	testDeepEqual(t, nested.GetString("request.id"), "1")
+	testDeepEqual(t, paths, []string{"db.host", "request.id"})
+	testDeepEqual(t, nested.Aliases(), map[string]string{"db.host": "database.host"})
+
+	// registered on a scope, they apply to the base root and all its scopes
+	paths = nil
+	nested.OnAccess(nil)
+	scope.OnMiss(func(path string) { paths = append(paths, path) })
+	root.GetString("missing")
+	nested.GetString("other")
+	testDeepEqual(t, paths, []string{"missing", "other"})
+}
@@ -1,7 +1,9 @@
 package trix
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 )
 
 func (node *Node) internalStringValue() string {
@@ -9,13 +11,27 @@ func (node *Node) internalStringValue() string {
 		return ""
 	} else if s, ok := node.Value.(string); ok {
 		return s
+	} else if raw, ok := node.Value.(json.RawMessage); ok {
+		return string(raw)
 	}
 	return fmt.Sprint(node.Value)
 }
 
+// internalSet sets the value of the node's descendant with the keys, creating
+// it and its ancestors as necessary, and returns it; nothing is set, and nil
+// is returned, if there are no keys or they would exceed the root's limits.
 func internalSet(node *Node, keys []string, value Value) *Node {
+	n, _ := internalTrySet(node, keys, value)
+	return n
+}
+
+// internalTrySet is like internalSet, but returns an error if the keys would
+// exceed the root's limits.
+func internalTrySet(node *Node, keys []string, value Value) (*Node, error) {
 	if len(keys) == 0 {
-		return nil
+		return nil, nil
+	} else if err := newLimiter(node, false).check(node, keys); err != nil {
+		return nil, err
 	}
 
 	// find the node to update, creating intermediate nodes as necessary
@@ -34,8 +50,159 @@ func internalSet(node *Node, keys []string, value Value) *Node {
 	// update the child's value
 	if value != nil {
 		nodeToUpdate.Value = value
+		internalRecordOrigin(nodeToUpdate, "set")
+	}
+	return nodeToUpdate, nil
+}
+
+// internalMerge clones original (and its descendants) into node, and returns
+// the clone.
+func internalMerge(node, original *Node) *Node {
+	if original == nil {
+		return nil
+	}
+	merged := internalMergeChild(node, original.Key)
+	internalMergeContents(merged, original)
+	return merged
+}
+
+// internalMergeChild returns the node's child with the key, creating it if
+// necessary.
+func internalMergeChild(node *Node, key string) *Node {
+	child := node.Children[key]
+	if child == nil {
+		child = NewNode(key)
+		child.Parent = node
+		node.Adopt(child)
 	}
-	return nodeToUpdate
+	return child
+}
+
+// internalMergeContents copies the value, flags and metadata of original
+// into node, and merges its children. The tree is walked iteratively, so that
+// deep trees can't exhaust the stack.
+func internalMergeContents(node, original *Node) {
+	type pending struct{ node, original *Node }
+	stack := []pending{{node, original}}
+	for len(stack) > 0 {
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		old, original := next.node, next.original
+
+		// overwrite the value, and add the flags
+		old.Value = original.Value
+		if flags := original.Flags &^ IsRoot; flags != 0 {
+			if flags&(ForceMap|ForceArray) != 0 {
+				// these replace each other
+				old.Flags &^= ForceMap | ForceArray
+			}
+			if flags&^old.Flags&KeepSorted != 0 {
+				old.Sort()
+			}
+			old.Flags |= flags
+		}
+		for key, v := range original.meta {
+			if key != MetaOrigin {
+				old.SetMeta(key, v)
+			}
+		}
+		if original.Value != nil {
+			internalRecordOrigin(old, "merge")
+		}
+
+		// merge children, in order
+		children := make([]pending, len(original.ChildKeys))
+		for i, key := range original.ChildKeys {
+			children[i] = pending{internalMergeChild(old, key), original.Children[key]}
+		}
+		for i := len(children) - 1; i >= 0; i-- {
+			stack = append(stack, children[i])
+		}
+	}
+}
+
+// internalDetach removes the node from its parent (but not from a parent
+// scope), and returns whether it was removed.
+func internalDetach(node *Node) bool {
+	parent := node.Parent
+	if parent == nil || node.Flags&IsRoot != 0 {
+		return false
+	}
+	if parent.Children[node.Key] == node {
+		delete(parent.Children, node.Key)
+		for index, ck := range parent.ChildKeys {
+			if ck == node.Key {
+				parent.ChildKeys = append(parent.ChildKeys[:index], parent.ChildKeys[index+1:]...)
+				break
+			}
+		}
+	}
+	node.Parent = nil
+	return true
+}
+
+// internalInsertKey adds the key to the node's ChildKeys: at the end, or in
+// its sorted position if the node has the KeepSorted flag.
+func internalInsertKey(node *Node, key string) {
+	if node.Flags&KeepSorted == 0 {
+		node.ChildKeys = append(node.ChildKeys, key)
+		return
+	} else if node.GetRoot().rootState().sortPolicy != SortDefault {
+		node.ChildKeys = append(node.ChildKeys, key)
+		node.Sort()
+		return
+	}
+
+	isInt := isIntString(key)
+	numeric := node.hasOnlyNumericKeys()
+	if numeric && !isInt && len(node.ChildKeys) > 0 {
+		// no longer numeric; sort everything alphabetically
+		node.ChildKeys = append(node.ChildKeys, key)
+		node.Sort()
+		return
+	}
+	index := sort.Search(len(node.ChildKeys), func(i int) bool {
+		if numeric && isInt {
+			return NumericStringSlice{key, node.ChildKeys[i]}.Less(0, 1)
+		}
+		return node.ChildKeys[i] > key
+	})
+	node.ChildKeys = append(node.ChildKeys, "")
+	copy(node.ChildKeys[index+1:], node.ChildKeys[index:])
+	node.ChildKeys[index] = key
+}
+
+// internalInsert adds the child to the node's parent, at the node's position
+// plus the offset, or in its sorted position if the parent has the KeepSorted
+// flag. Nothing is done if the node has no parent.
+func internalInsert(node, child *Node, offset int) *Node {
+	if child == nil || child == node || node.Index() < 0 {
+		return child
+	}
+	parent := node.Parent
+	internalDetach(child)
+	if other, found := parent.Children[child.Key]; found {
+		if other == node {
+			// replace the node, keeping its position
+			parent.Children[child.Key] = child
+			child.Parent = parent
+			node.Parent = nil
+			return child
+		}
+		internalDetach(other)
+	}
+	if parent.Flags&KeepSorted != 0 {
+		parent.Adopt(child)
+		return child
+	}
+
+	index := node.Index() + offset
+	parent.ChildKeys = append(parent.ChildKeys, "")
+	copy(parent.ChildKeys[index+1:], parent.ChildKeys[index:])
+	parent.ChildKeys[index] = child.Key
+	parent.Children[child.Key] = child
+	child.Parent = parent
+	return child
 }
 
 // internalUnset will remove the specified node and return it
@@ -63,8 +230,10 @@ func internalUnset(node *Node, keys []string) *Node {
 	return nil
 }
 
-// internalGetNodes will look for
-func internalGetNodes(node *Node, parsedKeys []string, limit int) NodeList {
+// internalGetNodes will look for the nodes matching the keys, stopping after
+// limit ones, if limit is positive. withDefault is true for lookups done by
+// the Default getters; see OnMiss.
+func internalGetNodes(node *Node, parsedKeys []string, limit int, withDefault bool) NodeList {
 	result := NodeList{}
 	if node == nil {
 		// so that calling GetNodes from a nil node doesn't segfault
@@ -73,84 +242,155 @@ func internalGetNodes(node *Node, parsedKeys []string, limit int) NodeList {
 		return NodeList{node}
 	}
 
-	var readNodes func(*Node, []string, int)
-	readNodes = func(node *Node, spec []string, index int) {
-		currentKey := spec[index]
-		last := index+1 == len(spec)
-		if currentKey == "*" {
-			for _, key := range node.ChildKeys {
-				childNode := node.Children[key]
-				if last {
-					result = append(result, childNode)
-					if limit > 0 && len(result) >= limit {
-						return
-					}
-				} else {
-					readNodes(childNode, spec, index+1)
-				}
+	root := node.GetRoot()
+	state := root.baseRoot().rootState()
+	if tracker := root.accessTracker(); tracker != nil {
+		defer func(node *Node, parsedKeys []string) {
+			if len(result) > 0 {
+				tracker.markRead(result)
+			} else {
+				tracker.miss(joinPath(append(node.Path(), parsedKeys...)))
 			}
-		} else {
-			if childNode, found := node.Children[currentKey]; found {
-				if last {
-					result = append(result, childNode)
-					if limit > 0 && len(result) >= limit {
-						return
-					}
-				} else {
-					readNodes(childNode, spec, index+1)
-				}
+		}(node, parsedKeys)
+	}
+	if hook := state.hookSet.Load().accessHook(); hook != nil {
+		defer func(node *Node, parsedKeys []string) {
+			hook(append(node.Path(), parsedKeys...), len(result) > 0)
+		}(node, parsedKeys)
+	}
+	if hook := state.hookSet.Load().missHook(withDefault); hook != nil {
+		defer func(node *Node, parsedKeys []string) {
+			if len(result) == 0 {
+				hook.miss(joinPath(append(node.Path(), parsedKeys...)))
+			}
+		}(node, parsedKeys)
+	}
+	var aliases []alias
+	var aliasedSpecs [][]string
+	if set := state.aliases.Load(); set != nil {
+		aliases, aliasedSpecs = set.rewrite(append(node.Path(), parsedKeys...))
+	}
+	if len(aliases) == 0 {
+		internalMatch(node, parsedKeys, func(_ []string, found *Node) bool {
+			result = append(result, found)
+			return limit <= 0 || len(result) < limit
+		})
+		return result
+	}
+
+	// with aliases, each path is returned once, as found with its original
+	// spelling, if possible; aliased paths are converted back to check this
+	seen := map[string]bool{}
+	returned := map[*Node]bool{}
+	nodePath := node.Path()
+	more := internalMatch(node, parsedKeys, func(path []string, found *Node) bool {
+		seen[joinPath(append(nodePath[:len(nodePath):len(nodePath)], path...))] = true
+		returned[found] = true
+		result = append(result, found)
+		return limit <= 0 || len(result) < limit
+	})
+	for i := 0; more && i < len(aliases); i++ {
+		alias := aliases[i]
+		more = internalMatch(root, aliasedSpecs[i], func(path []string, found *Node) bool {
+			oldPath := joinPath(append(append([]string{}, alias.from...), path[len(alias.to):]...))
+			if seen[oldPath] || returned[found] {
+				return true
+			}
+			seen[oldPath] = true
+			returned[found] = true
+			result = append(result, found)
+			return limit <= 0 || len(result) < limit
+		})
+	}
+	return result
+}
+
+// internalMatch calls yield with each node matching the spec, and its path
+// relative to node, in order, until it returns false; the path is reused
+// between calls. Parent scopes are also searched. Return false if yield did.
+func internalMatch(node *Node, parsedKeys []string, yield func(path []string, found *Node) bool) bool {
+	if node == nil {
+		return true
+	} else if len(parsedKeys) == 0 {
+		return yield([]string{}, node)
+	}
+
+	var readNodes func(node *Node, spec, path []string, offset int) bool
+	readNodes = func(node *Node, spec, path []string, offset int) bool {
+		visit := func(child *Node) bool {
+			path := append(path, child.Key)
+			if len(spec) == 1 {
+				return yield(path[offset:], child)
 			}
-			// "*" works both ways; this handles "server.app" prefixes (usually *.*)
-			if childNode, found := node.Children["*"]; found {
-				if last {
-					result = append(result, childNode)
-					if limit > 0 && len(result) >= limit {
-						return
-					}
-				} else {
-					readNodes(childNode, spec, index+1)
+			return readNodes(child, spec[1:], path, offset)
+		}
+
+		if spec[0] == "*" {
+			for _, key := range node.ChildKeys {
+				if !visit(node.Children[key]) {
+					return false
 				}
 			}
+			return true
+		}
+		if childNode, found := node.Children[spec[0]]; found && !visit(childNode) {
+			return false
 		}
+		// "*" works both ways; this handles "server.app" prefixes (usually *.*)
+		if childNode, found := node.Children["*"]; found && !visit(childNode) {
+			return false
+		}
+		return true
 	}
 
 	// if we have results from more than 1 scope, they will most likely not
 	// be sorted; if this is an issue we can count the number of scopes with
 	// results (when (count before `readNodes`) > count after) and if greater
 	// than 1, sort `result`.
-	for {
-		readNodes(node, parsedKeys, 0)
-		if limit > 0 && len(result) >= limit {
-			break
+	// parent scopes are searched using the absolute path, computed once
+	path := make([]string, 0, len(parsedKeys))
+	var absoluteKeys []string
+	offset := 0
+	for scope := node.GetRoot(); ; {
+		if !readNodes(node, parsedKeys, path, offset) {
+			return false
 		}
 
 		// is there a parent scope where can also look?
-		parentScope := node.GetRoot().Parent
+		parentScope := scope.Parent
 		if parentScope == nil {
-			break
+			return true
 		}
 
-		if node.Flags&IsRoot == 0 {
-			// the node is not a root, but a child; in order to try the parent
-			// scope, we have to use the full/absolute path.
+		if absoluteKeys == nil {
 			nodePath := node.Path()
-			absolutePath := make([]string, 0, len(nodePath)+len(parsedKeys))
-			absolutePath = append(absolutePath, nodePath...)
-			absolutePath = append(absolutePath, parsedKeys...)
-			parsedKeys = absolutePath
+			absoluteKeys = make([]string, 0, len(nodePath)+len(parsedKeys))
+			absoluteKeys = append(absoluteKeys, nodePath...)
+			absoluteKeys = append(absoluteKeys, parsedKeys...)
+			offset = len(nodePath)
 		}
 
 		// try again, using the parent scope as the new reference
-		node = parentScope
+		scope, node, parsedKeys = parentScope, parentScope, absoluteKeys
 	}
+}
 
-	return result
+// internalGetExact returns the descendant with the specified keys, without
+// handling wildcards or parent scopes, or nil if it's not found.
+func internalGetExact(node *Node, keys []string) *Node {
+	for _, key := range keys {
+		if node = node.Children[key]; node == nil {
+			return nil
+		}
+	}
+	return node
 }
 
 // internalTryGetNode will try o find the keys starting from the specified node.
-func internalTryGetNode(node *Node, parsedKeys []string) (*Node, error) {
-	if found := internalGetNodes(node, parsedKeys, 1); len(found) > 0 {
+// withDefault is as for internalGetNodes.
+func internalTryGetNode(node *Node, parsedKeys []string, withDefault bool) (*Node, error) {
+	if found := internalGetNodes(node, parsedKeys, 1, withDefault); len(found) > 0 {
 		return found[0], nil
 	}
-	return nil, errorNodeNotFound
+	return nil, ErrNodeNotFound
 }
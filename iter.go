@@ -0,0 +1,53 @@
+package trix
+
+import "iter"
+
+// All returns an iterator over the node's descendants, depth-first, in
+// ChildKeys order, with their paths relative to the node. Parent scopes are
+// not included. The path is reused between iterations, so it must be copied
+// (e.g. with slices.Clone) to be kept.
+func (node *Node) All() iter.Seq2[[]string, *Node] {
+	return func(yield func([]string, *Node) bool) {
+		if node != nil {
+			internalWalk(node, make([]string, 0, 8), false, yield)
+		}
+	}
+}
+
+// Leaves is like All, but only yields the descendants without children.
+func (node *Node) Leaves() iter.Seq2[[]string, *Node] {
+	return func(yield func([]string, *Node) bool) {
+		if node != nil {
+			internalWalk(node, make([]string, 0, 8), true, yield)
+		}
+	}
+}
+
+// Match returns an iterator over the nodes that match the spec, in the same
+// order as GetNodes, including parent scopes, with their paths relative to
+// the node; nodes are only looked up as needed. As with All, the path must be
+// copied to be kept. Unlike GetNodes, access tracking and hooks aren't
+// notified.
+func (node *Node) Match(keys ...interface{}) iter.Seq2[[]string, *Node] {
+	parsedKeys := ParseKeys(keys)
+	return func(yield func([]string, *Node) bool) {
+		internalMatch(node, parsedKeys, yield)
+	}
+}
+
+// internalWalk calls yield with the node's descendants (or only its leaves),
+// depth-first, appending their keys to path, until it returns false. Return
+// false if yield did.
+func internalWalk(node *Node, path []string, leaves bool, yield func([]string, *Node) bool) bool {
+	for _, key := range node.ChildKeys {
+		child := node.Children[key]
+		path := append(path, key)
+		if (!leaves || child.IsLeaf()) && !yield(path, child) {
+			return false
+		}
+		if !internalWalk(child, path, leaves, yield) {
+			return false
+		}
+	}
+	return true
+}
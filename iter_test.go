@@ -0,0 +1,117 @@
+package trix
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("a.b", 1)
+	root.SetKey("a.c.d", 2)
+	root.SetKey("a", 3)
+	root.SetKey("e", 4)
+	root.With(Args{"f": 5}) // parent scopes are not included
+
+	paths := []string{}
+	for path, n := range root.All() {
+		paths = append(paths, fmt.Sprintf("%s=%v", strings.Join(path, "."), n.Value))
+	}
+	testDeepEqual(t, paths, []string{"a=3", "a.b=1", "a.c=<nil>", "a.c.d=2", "e=4"})
+
+	paths = []string{}
+	for path, n := range root.GetNode("a").Leaves() {
+		paths = append(paths, fmt.Sprintf("%s=%v", strings.Join(path, "."), n.Value))
+	}
+	testDeepEqual(t, paths, []string{"b=1", "c.d=2"})
+
+	// break early, and keep a copy of the path
+	var kept []string
+	for path := range root.All() {
+		if len(path) == 2 {
+			kept = slices.Clone(path)
+			break
+		}
+	}
+	testDeepEqual(t, kept, []string{"a", "b"})
+
+	var nilNode *Node
+	for range nilNode.All() {
+		t.Fatal("unexpected node")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	parent := NewRoot()
+	parent.SetKey("main.string.one", 1)
+	parent.SetKey("main.*.two", 2)
+	parent.SetKey("item.1.name", "x")
+	parent.SetKey("item.2.name", "y")
+	parent.SetKey("item.2", "valued")
+	root := parent.With(Args{"main.string.three": 3, "item.3.name": "z"})
+
+	for _, node := range []*Node{root, root.GetNode("main"), parent} {
+		for _, spec := range []string{"main.*.*", "*.*", "string.*", "item.*", "item.*.name", "missing", ""} {
+			expected := node.GetNodes(spec)
+			found := NodeList{}
+			for _, n := range node.Match(spec) {
+				found = append(found, n)
+			}
+			testDeepEqual(t, found, expected)
+
+			// the same values as the extra getters
+			values := []Value{}
+			for _, n := range node.Match(spec) {
+				if n.hasValue() {
+					values = append(values, n.Value)
+				}
+			}
+			testDeepEqual(t, values, node.GetValues(spec))
+		}
+	}
+
+	// paths are relative to the node, also for parent scopes
+	paths := []string{}
+	for path := range root.GetNode("main").Match("*.*") {
+		paths = append(paths, strings.Join(path, "."))
+	}
+	testDeepEqual(t, paths, []string{"string.three", "string.one", "*.two"})
+
+	// break early
+	count := 0
+	for range root.Match("item.*.name") {
+		if count++; count == 2 {
+			break
+		}
+	}
+	testDeepEqual(t, count, 2)
+}
+
+func benchmarkTree() *Node {
+	root := NewRoot()
+	for i := 0; i < 1000; i++ {
+		root.SetKey(fmt.Sprintf("item.%d.name", i), i)
+	}
+	return root
+}
+
+func BenchmarkGetNodes_First(b *testing.B) {
+	root := benchmarkTree()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = root.GetNodes("item.*.name")[0]
+	}
+}
+
+func BenchmarkMatch_First(b *testing.B) {
+	root := benchmarkTree()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, n := range root.Match("item.*.name") {
+			_ = n
+			break
+		}
+	}
+}
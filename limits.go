@@ -0,0 +1,122 @@
+package trix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimit is returned, wrapped, when parsing an input or setting a key would
+// exceed the root's limits; see SetLimits.
+var ErrLimit = errors.New("limit exceeded")
+
+// Limits restricts the trees built from input, like files, readers and JSON
+// documents, and by setters. A zero value means there's no limit.
+type Limits struct {
+	// MaxDepth is the maximum depth of a node, relative to its root.
+	MaxDepth int
+
+	// MaxNodes is the maximum number of nodes a single input, or a single
+	// call to a setter, can create.
+	MaxNodes int
+
+	// MaxKeyLength is the maximum length of a key, in bytes.
+	MaxKeyLength int
+}
+
+// DefaultLimits are the limits of roots without their own; see SetLimits.
+var DefaultLimits = Limits{
+	MaxDepth:     1000,
+	MaxNodes:     10000000,
+	MaxKeyLength: 64 * 1024,
+}
+
+// SetLimits sets the limits enforced when parsing input into the node's root
+// (and new scopes created from it) with MergeFile, MergeReader, LoadWith and
+// UnmarshalJSON, or setting keys with TrySetKey, where exceeding them returns
+// an error wrapping ErrLimit. Other setters, like SetKey, Set, AddNode, Push
+// and With, set nothing and return nil instead; nodes added by Merge and
+// Adopt aren't checked. Serialising a tree deeper than MaxDepth with
+// MarshalJSON fails, and Dump skips the nodes beyond it. Return the original
+// node.
+func (node *Node) SetLimits(limits Limits) *Node {
+	if root := node.GetRoot(); root != nil {
+		root.ensureRootState().limits = &limits
+	}
+	return node
+}
+
+// Limits returns the limits of the node's root; see SetLimits.
+func (node *Node) Limits() Limits {
+	if root := node.GetRoot(); root != nil && root.rootState().limits != nil {
+		return *root.rootState().limits
+	}
+	return DefaultLimits
+}
+
+// limiter enforces the limits while parsing a single input.
+type limiter struct {
+	limits Limits
+	nodes  int
+
+	// pending has the paths of the nodes counted but not created yet, for
+	// inputs whose entries are only set at the end.
+	pending map[string]bool
+}
+
+// newLimiter returns a limiter for input parsed into node. If pending is
+// true, entries are only set after they're all checked.
+func newLimiter(node *Node, pending bool) *limiter {
+	l := &limiter{limits: node.Limits()}
+	if pending {
+		l.pending = map[string]bool{}
+	}
+	return l
+}
+
+// check returns an error if setting the keys under node would exceed the
+// limits, and otherwise counts the nodes that would be created. The limiter
+// may be nil.
+func (l *limiter) check(node *Node, keys []string) error {
+	if l == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if l.limits.MaxKeyLength > 0 && len(key) > l.limits.MaxKeyLength {
+			return fmt.Errorf("%w: key has %d bytes, max %d", ErrLimit, len(key), l.limits.MaxKeyLength)
+		}
+	}
+	if err := l.checkDepth(node.Depth() + len(keys)); err != nil {
+		return err
+	}
+
+	created := 0
+	for i, key := range keys {
+		if node != nil {
+			node = node.Children[key]
+		}
+		if node == nil {
+			if l.pending != nil {
+				path := joinPath(keys[:i+1])
+				if l.pending[path] {
+					continue
+				}
+				l.pending[path] = true
+			}
+			created++
+		}
+	}
+	if l.limits.MaxNodes > 0 && l.nodes+created > l.limits.MaxNodes {
+		return fmt.Errorf("%w: more than %d nodes", ErrLimit, l.limits.MaxNodes)
+	}
+	l.nodes += created
+	return nil
+}
+
+// checkDepth returns an error if the depth exceeds the limit. The limiter
+// may be nil.
+func (l *limiter) checkDepth(depth int) error {
+	if l != nil && l.limits.MaxDepth > 0 && depth > l.limits.MaxDepth {
+		return fmt.Errorf("%w: depth %d, max %d", ErrLimit, depth, l.limits.MaxDepth)
+	}
+	return nil
+}
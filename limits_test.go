@@ -0,0 +1,141 @@
+package trix
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLimits(t *testing.T) {
+	testDeepEqual(t, NewRoot().Limits(), DefaultLimits)
+	limits := Limits{MaxDepth: 3, MaxNodes: 5, MaxKeyLength: 4}
+	root := NewRoot().SetLimits(limits)
+	testDeepEqual(t, root.With().Limits(), limits)
+
+	// TrySetKey
+	_, err := root.TrySetKey("a.b.c", 1)
+	testError(t, err, "")
+	_, err = root.GetNode("a").TrySetKey("b.c.d", 1)
+	testError(t, err, "limit exceeded: depth 4, max 3")
+	testTrue(t, errors.Is(err, ErrLimit))
+	_, err = root.TrySetKey("a.toolong", 1)
+	testError(t, err, "limit exceeded: key has 7 bytes, max 4")
+
+	// other setters ignore the keys exceeding the limits
+	testTrue(t, root.SetKey("a.b.c.d.e", 1) == nil)
+	testTrue(t, root.AddNode("a.b.toolong") == nil)
+	testTrue(t, root.GetNode("a.b.c").Push() == nil)
+	_, err = root.GetNode("a.b.c").PushAt(1)
+	testError(t, err, "limit exceeded: depth 4, max 3")
+	testTrue(t, root.GetNode("a.b").Push() != nil)
+	testEqualString(t, root.With(Args{"x.y.z.w": 1, "x.y": 2}), "{x={y=2}}")
+	testEqualString(t, root, "{a={b={c=1,1=}}}")
+
+	// MergeReader
+	root = NewRoot().SetLimits(limits)
+	errs := root.MergeReaderLenient(strings.NewReader("a.b=1\na.b.c.d=2\ntoolong.k=3\nc=3\nd.e.f=4\ng=5\n"))
+	messages := []string{}
+	for _, e := range errs {
+		testTrue(t, errors.Is(e, ErrLimit))
+		messages = append(messages, e.Error())
+	}
+	testDeepEqual(t, messages, []string{
+		"line 2: limit exceeded: depth 4, max 3",
+		"line 3: limit exceeded: key has 7 bytes, max 4",
+		"line 5: limit exceeded: more than 5 nodes",
+	})
+	testEqualString(t, root, "{a={b=1},c=3,g=5}")
+
+	// the node count is per input
+	testError(t, root.MergeReader(strings.NewReader("d.e.f=4\ng=5\n"), true), "")
+	testEqualString(t, root, "{a={b=1},c=3,g=5,d={e={f=4}}}")
+
+	// files
+	fsys := fstest.MapFS{
+		"ok.conf":   {Data: []byte("a.b.c=1\na.b.d=2\na.e=3\n")},
+		"deep.conf": {Data: []byte("a=1\na.b.c.d=2\n")},
+		"wide.conf": {Data: []byte("a=1\nb=2\nc=3\nd=4\ne=5\nf=6\n")},
+	}
+	for _, atomic := range []bool{false, true} {
+		loaded, err := LoadWith(LoadOptions{FS: fsys, Filename: "ok.conf", Into: NewRoot().SetLimits(limits), Atomic: atomic})
+		testError(t, err, "")
+		testEqualString(t, loaded, "{a={b={c=1,d=2},e=3}}")
+		_, err = LoadWith(LoadOptions{FS: fsys, Filename: "deep.conf", Into: NewRoot().SetLimits(limits), Atomic: atomic})
+		testError(t, err, "deep.conf:2: limit exceeded: depth 4, max 3")
+		into := NewRoot().SetLimits(limits)
+		_, err = LoadWith(LoadOptions{FS: fsys, Filename: "wide.conf", Into: into, Atomic: atomic})
+		testError(t, err, "wide.conf:6: limit exceeded: more than 5 nodes")
+		if atomic {
+			testEqualString(t, into, "{}")
+		}
+	}
+
+	// JSON
+	root = NewRoot().SetLimits(limits)
+	testError(t, json.Unmarshal([]byte(`{"a":{"b":{"c":1}},"d":[1]}`), root), "")
+	testEqualString(t, root, "{a={b={c=1}},d={1=1}}")
+	root = NewRoot().SetLimits(limits)
+	testError(t, json.Unmarshal([]byte(`{"a":{"b":{"c":{"d":1}}}}`), root), "limit exceeded: depth 4, max 3")
+	testError(t, NewRoot().SetLimits(limits).UnmarshalJSON([]byte(`[1,2,3,4,5,6]`)), "limit exceeded: more than 5 nodes")
+	testError(t, NewRoot().SetLimits(limits).UnmarshalJSON([]byte(`{"toolong":1}`)), "limit exceeded: key has 7 bytes, max 4")
+	deep := strings.Repeat(`{"a":`, 5000) + "1" + strings.Repeat("}", 5000)
+	testTrue(t, errors.Is(NewRoot().UnmarshalJSON([]byte(deep)), ErrLimit))
+}
+
+func TestLimits_DeepTree(t *testing.T) {
+	// a tree built without checking the limits
+	const depth = 100000
+	deep := NewRoot().SetLimits(Limits{})
+	deep.SetKey(strings.TrimSuffix(strings.Repeat("a.", depth), "."), 1)
+
+	merged := NewRoot().Merge(deep.GetNode("a"))
+	testDeepEqual(t, merged.Path(), []string{"a"})
+	testDeepEqual(t, len(merged.GetNode(strings.Repeat("a.", depth-2)+"a").Path()), depth)
+
+	deep.SetLimits(DefaultLimits)
+	_, err := json.Marshal(deep)
+	testError(t, err, "json: error calling MarshalJSON for type *trix.Node: limit exceeded: depth 1001, max 1000")
+
+	deep.SetLimits(Limits{MaxDepth: 2})
+	testEqualString(t, deep, "{a={a={...}}}")
+	buf := bytes.Buffer{}
+	deep.Dump(&buf, false)
+	testDeepEqual(t, buf.String(), "")
+	deep.SetKey("a.a", "x")
+	buf.Reset()
+	deep.Dump(&buf, false)
+	testDeepEqual(t, buf.String(), "a.a=x\n")
+
+	deep.SetLimits(Limits{})
+	byt, err := json.Marshal(deep.GetNode(strings.Repeat("a.", depth-3) + "a"))
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), `{"a":{"a":1}}`)
+}
+
+func FuzzLimits(f *testing.F) {
+	f.Add([]byte("a.b.c=1\na.b=2\n"))
+	f.Add([]byte(`{"a":{"b":[1,2,{"c":3}]}}`))
+	f.Add([]byte(`[[[[[1]]]]]`))
+	limits := Limits{MaxDepth: 4, MaxNodes: 10, MaxKeyLength: 8}
+	check := func(t *testing.T, root *Node) {
+		count := 0
+		for path, n := range root.All() {
+			count++
+			testTrue(t, len(path) <= limits.MaxDepth)
+			testTrue(t, len(n.Key) <= limits.MaxKeyLength)
+		}
+		testTrue(t, count <= limits.MaxNodes)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		root := NewRoot().SetLimits(limits)
+		root.MergeReaderLenient(bytes.NewReader(data))
+		check(t, root)
+
+		root = NewRoot().SetLimits(limits)
+		root.UnmarshalJSON(data)
+		check(t, root)
+	})
+}
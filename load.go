@@ -0,0 +1,201 @@
+package trix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// LoadOptions changes how LoadWith loads a configuration file.
+type LoadOptions struct {
+	// Filename is the file to load; includes are relative to it.
+	Filename string
+
+	// FS, if not nil, is used to read files instead of the local disk.
+	FS fs.FS
+
+	// Into, if not nil, is the node where entries are merged; otherwise
+	// a new root is used.
+	Into *Node
+
+	// Atomic only merges the entries into the destination node if the whole
+	// file (and its includes) could be loaded, leaving it untouched otherwise.
+	Atomic bool
+
+	// Strict makes setting the same key more than once an error.
+	Strict bool
+
+	// ExpandEnv replaces $VAR and ${VAR} references in values by the
+	// values of environment variables, as returned by Getenv.
+	ExpandEnv bool
+
+	// Getenv, if not nil, is used instead of os.Getenv by ExpandEnv.
+	Getenv func(key string) string
+
+	// ExpandInclude, if not nil, is used instead of the default expansion of
+	// "~" and environment variables in include paths (see MergeFile).
+	ExpandInclude func(path string) (string, error)
+
+	// KeepComments makes the destination's root keep the comments found in
+	// the files, so they can be written back by WriteConf. See KeepComments.
+	KeepComments bool
+
+	// Allowed, if not nil, is a tree with the keys that may be set; "*"
+	// can be used to allow any key in that position. Other keys are errors.
+	Allowed *Node
+
+	// Context, if not nil, is checked before opening each file and setting
+	// each entry; if it's done, loading stops and its error is returned.
+	// With Atomic, the destination node is then left untouched.
+	Context context.Context
+}
+
+// loadEntry is a key/value loaded from a file.
+type loadEntry struct {
+	parsedEntry
+	origin Origin
+}
+
+// Load loads the specified file into a new root. See MergeFile.
+func Load(filename string) (*Node, error) {
+	return LoadWith(LoadOptions{Filename: filename})
+}
+
+// LoadWith loads a file using the specified options, and returns the node
+// the entries were merged into. If there is an error, it's returned.
+func LoadWith(opts LoadOptions) (*Node, error) {
+	fsys := regularFS
+	if opts.FS != nil {
+		fsys = tFS{opts.FS}
+	}
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	expand := opts.ExpandInclude
+	if expand == nil {
+		expand = expandIncludePath
+	}
+	node := opts.Into
+	if node == nil {
+		node = NewRoot()
+	}
+	if opts.KeepComments {
+		node.KeepComments()
+	}
+	tracker := node.GetRoot().rootState().comments
+
+	entries := []loadEntry{}
+	seen := map[string]string{}
+	limit := newLimiter(node, opts.Atomic)
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	fsys = ctxFileSystem{ctx, fsys}
+
+	footer, err := internalParseFile(fsys, opts.Filename, expand, tracker != nil, func(e parsedEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if opts.Allowed != nil && opts.Allowed.GetNode(exactKeys(e.keys)...) == nil {
+			return fmt.Errorf(`key "%s" is not allowed`, e.key)
+		}
+		if opts.Strict {
+			if where, found := seen[joinPath(e.keys)]; found {
+				return fmt.Errorf(`duplicate key "%s" (first set at %s)`, e.key, where)
+			}
+			seen[joinPath(e.keys)] = fmt.Sprintf("%s:%d", e.filename, e.lineNumber)
+		}
+		if s, ok := e.value.(string); ok && opts.ExpandEnv {
+			e.value = os.Expand(s, getenv)
+		}
+
+		origin := Origin{Kind: "file", Source: e.filename, Line: e.lineNumber}
+		if opts.Atomic {
+			if err := limit.check(node, e.keys); err != nil {
+				return err
+			}
+			entries = append(entries, loadEntry{e, origin})
+			return nil
+		}
+		n, err := internalSetFrom(node, e.keys, e.value, origin, limit)
+		if err != nil {
+			return err
+		}
+		tracker.set(n, e.comments, e.comment)
+		return nil
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		// the limits were checked while parsing
+		n, _ := internalSetFrom(node, entry.keys, entry.value, entry.origin, nil)
+		tracker.set(n, entry.comments, entry.comment)
+	}
+	tracker.setFooter(node, footer)
+	return node, nil
+}
+
+// ctxFileSystem fails to open files once the context is done.
+type ctxFileSystem struct {
+	ctx context.Context
+	tfileSystem
+}
+
+func (f ctxFileSystem) Open(name string) (tFile, error) {
+	if err := f.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.tfileSystem.Open(name)
+}
+
+// MergeFileCtx is like MergeFile, but stops if the context is done, returning
+// its error; like MergeFile, it's not atomic. Use LoadWith with the Context
+// and Atomic options to leave the node untouched in that case.
+func (node *Node) MergeFileCtx(ctx context.Context, filename string) error {
+	_, err := LoadWith(LoadOptions{Filename: filename, Into: node, Context: ctx})
+	return err
+}
+
+// LoadAll loads each of the files into its own root, stacking them like With
+// does, so the first file is the bottom-most scope and the last one is the
+// top-most, and its values take precedence. Filenames starting with "?" are
+// optional, and skipped if they don't exist. Return the top-most scope.
+func LoadAll(filenames ...string) (*Node, error) {
+	return LoadAllWith(LoadOptions{}, filenames...)
+}
+
+// LoadAllWith is like LoadAll, but uses the specified options to load each
+// file; their Filename and Into fields are ignored.
+func LoadAllWith(opts LoadOptions, filenames ...string) (*Node, error) {
+	var top *Node
+	for _, filename := range filenames {
+		optional := strings.HasPrefix(filename, "?")
+		if optional {
+			filename = filename[1:]
+		}
+
+		opts.Filename = filename
+		opts.Into = newScope(top)
+		root, err := LoadWith(opts)
+		if err != nil {
+			if optional && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		top = root
+	}
+	if top == nil {
+		top = NewRoot()
+	}
+	return top, nil
+}
@@ -0,0 +1,195 @@
+package trix
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	root, err := Load("missing-file.conf")
+	testTrue(t, root == nil)
+	testError(t, err, "open missing-file.conf: no such file or directory")
+
+	root, err = Load("examples/main.conf")
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("main.key"), "overwrite")
+	testTrue(t, root.Flags&IsRoot != 0)
+	testEqualString(t, MustLoad("examples/main.conf"), root)
+}
+
+func TestLoadWith(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":        {Data: []byte("a=1\nurl=${HOST}:$PORT\ninclude conf.d/more.conf\n")},
+		"conf.d/more.conf": {Data: []byte("b.c=2\n")},
+		"dup.conf":         {Data: []byte("a=1\ninclude conf.d/dup.conf\n")},
+		"conf.d/dup.conf":  {Data: []byte("\na=2\n")},
+		"bad.conf":         {Data: []byte("a=2\nb.c=3\nbad line\n")},
+		"extra.conf":       {Data: []byte("a=1\nb.d=4\n")},
+	}
+	env := map[string]string{"HOST": "example.com", "PORT": "80"}
+	getenv := func(key string) string { return env[key] }
+
+	// file system + env expansion
+	root, err := LoadWith(LoadOptions{Filename: "main.conf", FS: fsys, ExpandEnv: true, Getenv: getenv})
+	testError(t, err, "")
+	testEqualString(t, root, `{a=1,url=example.com:80,b={c=2}}`)
+
+	// without expansion
+	root, err = LoadWith(LoadOptions{Filename: "main.conf", FS: fsys})
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("url"), "${HOST}:$PORT")
+
+	// strict + file system
+	_, err = LoadWith(LoadOptions{Filename: "dup.conf", FS: fsys, Strict: true})
+	testError(t, err, `dup.conf:2: including "conf.d/dup.conf": conf.d/dup.conf:2: duplicate key "a" (first set at dup.conf:1)`)
+	root, err = LoadWith(LoadOptions{Filename: "dup.conf", FS: fsys})
+	testError(t, err, "")
+	testDeepEqual(t, root.Get("a"), "2")
+
+	// atomic + into: the destination is untouched on errors
+	dest := FromArgs(Args{"a": "0"})
+	_, err = LoadWith(LoadOptions{Filename: "bad.conf", FS: fsys, Into: dest, Atomic: true})
+	testError(t, err, `bad.conf:3: bad format: "bad line"`)
+	testEqualString(t, dest, `{a=0}`)
+	_, err = LoadWith(LoadOptions{Filename: "bad.conf", FS: fsys, Into: dest})
+	testError(t, err, `bad.conf:3: bad format: "bad line"`)
+	testEqualString(t, dest, `{a=2,b={c=3}}`) // partially updated
+	loaded, err := LoadWith(LoadOptions{Filename: "main.conf", FS: fsys, Into: dest, Atomic: true})
+	testError(t, err, "")
+	testTrue(t, loaded == dest)
+	testEqualString(t, dest, `{a=1,b={c=2},url=${HOST}:$PORT}`)
+
+	// allowed keys
+	allowed := FromArgs(Args{"a": "", "b.*": ""})
+	_, err = LoadWith(LoadOptions{Filename: "extra.conf", FS: fsys, Allowed: allowed})
+	testError(t, err, "")
+	_, err = LoadWith(LoadOptions{Filename: "main.conf", FS: fsys, Allowed: allowed})
+	testError(t, err, `main.conf:2: key "url" is not allowed`)
+}
+
+func TestLoadAll(t *testing.T) {
+	fsys := fstest.MapFS{
+		"defaults.conf": {Data: []byte("server.port=80\nserver.host=localhost\nlog.level=info\n")},
+		"site.conf":     {Data: []byte("server.host=example.com\nlog.level=warn\n")},
+		"local.conf":    {Data: []byte("log.level=debug\n")},
+		"bad.conf":      {Data: []byte("bad line\n")},
+	}
+
+	top, err := LoadAllWith(LoadOptions{FS: fsys}, "defaults.conf", "site.conf", "?missing.conf", "?local.conf")
+	testError(t, err, "")
+	testDeepEqual(t, top.Get("server.port"), "80")
+	testDeepEqual(t, top.Get("server.host"), "example.com")
+	testDeepEqual(t, top.Get("log.level"), "debug")
+	testDeepEqual(t, top.GetStringValues("log.level"), []string{"debug", "warn", "info"})
+	testEqualString(t, top, `{log={level=debug}}`) // only the top-most file
+	testEqualString(t, top.Parent, `{server={host=example.com},log={level=warn}}`)
+	testTrue(t, top.Parent.Parent.Parent == nil)
+
+	// layers are stacked like With stacks scopes, so hooks registered on the
+	// top-most one apply to all of them
+	var missed []string
+	top.OnMiss(func(path string) { missed = append(missed, path) })
+	top.Parent.GetString("server.user")
+	testDeepEqual(t, missed, []string{"server.user"})
+	testTrue(t, top.baseRoot() == top.Parent.Parent)
+
+	_, err = LoadAllWith(LoadOptions{FS: fsys}, "defaults.conf", "missing.conf")
+	testError(t, err, "open missing.conf: file does not exist")
+	_, err = LoadAllWith(LoadOptions{FS: fsys}, "defaults.conf", "?bad.conf")
+	testError(t, err, `bad.conf:1: bad format: "bad line"`)
+
+	top, err = LoadAll("?missing.conf")
+	testError(t, err, "")
+	testEqualString(t, top, `{}`)
+}
+
+func TestLoadWith_ExpandInclude(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":                {Data: []byte("a=1\ninclude ~/overrides.conf\ninclude $CONFDIR/site.conf\ninclude ${CONFDIR}/cost$$.conf\n")},
+		"home/user/overrides.conf": {Data: []byte("b=2\n")},
+		"etc/app/site.conf":        {Data: []byte("c=3\n")},
+		"etc/app/cost$.conf":       {Data: []byte("d=4\n")},
+		"unset.conf":               {Data: []byte("\ninclude $MISSING/x.conf\n")},
+		"nohome.conf":              {Data: []byte("include ~/x.conf\n")},
+	}
+	env := map[string]string{"CONFDIR": "/etc/app"}
+	home := "/home/user"
+	expand := func(s string) (string, error) {
+		return expandPath(s, func(key string) (string, bool) {
+			value, found := env[key]
+			return value, found
+		}, func() (string, error) {
+			if home == "" {
+				return "", errors.New("no home directory")
+			}
+			return home, nil
+		})
+	}
+	load := func(filename string) (*Node, error) {
+		return LoadWith(LoadOptions{Filename: filename, FS: fsys, ExpandInclude: expand})
+	}
+
+	root, err := load("main.conf")
+	testError(t, err, "")
+	testEqualString(t, root, `{a=1,b=2,c=3,d=4}`)
+
+	_, err = load("unset.conf")
+	testError(t, err, `unset.conf:2: expanding include "$MISSING/x.conf": environment variable not set: MISSING`)
+	home = ""
+	_, err = load("nohome.conf")
+	testError(t, err, `nohome.conf:1: expanding include "~/x.conf": no home directory`)
+
+	path, err := expandPath("~user/$$HOME", os.LookupEnv, os.UserHomeDir)
+	testError(t, err, "")
+	testDeepEqual(t, path, "~user/$HOME")
+}
+
+// delayFS calls delay before opening each file.
+type delayFS struct {
+	fs.FS
+	delay func(name string)
+}
+
+func (f delayFS) Open(name string) (fs.File, error) {
+	f.delay(name)
+	return f.FS.Open(name)
+}
+
+func TestLoadWith_Context(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.conf":       {Data: []byte("a=1\ninclude conf.d/one.conf\ninclude conf.d/two.conf\n")},
+		"conf.d/one.conf": {Data: []byte("b=2\n")},
+		"conf.d/two.conf": {Data: []byte("c=3\n")},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	opened := []string{}
+	slow := delayFS{fsys, func(name string) {
+		opened = append(opened, name)
+		if name == "conf.d/one.conf" {
+			// the load takes too long
+			time.Sleep(time.Millisecond)
+			cancel()
+		}
+	}}
+
+	dest := FromArgs(Args{"a": "0"})
+	_, err := LoadWith(LoadOptions{Filename: "main.conf", FS: slow, Into: dest, Atomic: true, Context: ctx})
+	testTrue(t, errors.Is(err, context.Canceled))
+	testDeepEqual(t, opened, []string{"main.conf", "conf.d/one.conf"})
+	testEqualString(t, dest, `{a=0}`)
+
+	// not atomic
+	_, err = LoadWith(LoadOptions{Filename: "main.conf", FS: fsys, Into: dest, Context: ctx})
+	testTrue(t, errors.Is(err, context.Canceled))
+	testEqualString(t, dest, `{a=0}`)
+	testTrue(t, errors.Is(dest.MergeFileCtx(ctx, "examples/main.conf"), context.Canceled))
+	testEqualString(t, dest, `{a=0}`)
+
+	testError(t, dest.MergeFileCtx(context.Background(), "examples/main.conf"), "")
+	testDeepEqual(t, dest.Get("main.key"), "overwrite")
+}
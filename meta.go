@@ -0,0 +1,63 @@
+package trix
+
+// Metadata keys used by trix itself; keys starting with "trix." are reserved.
+const (
+	// MetaOrigin is the node's Origin; see TrackOrigins.
+	MetaOrigin = "trix.origin"
+
+	// MetaComments are the comment and empty lines before the node's entry,
+	// as a []string; see KeepComments.
+	MetaComments = "trix.comments"
+
+	// MetaComment is the trailing comment of the node's entry, as a string,
+	// including the whitespace before the "#".
+	MetaComment = "trix.comment"
+
+	// MetaFooter are the comment and empty lines after the last entry of the
+	// node loaded from a file, as a []string.
+	MetaFooter = "trix.footer"
+)
+
+// SetMeta sets the node's metadata for the key: auxiliary data that isn't
+// part of the node's value, like a deprecation notice or a hint for a UI. A
+// nil value removes the key. Metadata belongs to the node itself, so it's
+// kept when the node is moved (e.g. by Adopt), it's copied by Merge, and it's
+// ignored by serialisers, other than MarshalJSONMeta. Return the original
+// node.
+func (node *Node) SetMeta(key string, v interface{}) *Node {
+	if node == nil {
+		return node
+	} else if v == nil {
+		delete(node.meta, key)
+		if len(node.meta) == 0 {
+			node.meta = nil
+		}
+		return node
+	}
+	if node.meta == nil {
+		node.meta = map[string]interface{}{}
+	}
+	node.meta[key] = v
+	return node
+}
+
+// Meta returns the node's metadata for the key, and whether it's set.
+func (node *Node) Meta(key string) (interface{}, bool) {
+	if node == nil {
+		return nil, false
+	}
+	v, found := node.meta[key]
+	return v, found
+}
+
+// Metas returns a copy of the node's metadata, or nil if it has none.
+func (node *Node) Metas() map[string]interface{} {
+	if node == nil || len(node.meta) == 0 {
+		return nil
+	}
+	metas := make(map[string]interface{}, len(node.meta))
+	for key, v := range node.meta {
+		metas[key] = v
+	}
+	return metas
+}
@@ -0,0 +1,97 @@
+package trix
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	root := NewRoot()
+	port := root.SetKey("db.port", 5432)
+	testTrue(t, port.meta == nil)
+	testTrue(t, port.Metas() == nil)
+	_, found := port.Meta("hint")
+	testTrue(t, !found)
+
+	port.SetMeta("hint", "the server's port").SetMeta("deprecated", true)
+	hint, found := port.Meta("hint")
+	testTrue(t, found)
+	testDeepEqual(t, hint, "the server's port")
+	testDeepEqual(t, port.Metas(), map[string]interface{}{"hint": "the server's port", "deprecated": true})
+
+	// Metas returns a copy, and nil values remove keys
+	port.Metas()["hint"] = "changed"
+	hint, _ = port.Meta("hint")
+	testDeepEqual(t, hint, "the server's port")
+	port.SetMeta("deprecated", nil)
+	testDeepEqual(t, port.Metas(), map[string]interface{}{"hint": "the server's port"})
+	port.SetMeta("hint", nil)
+	testTrue(t, port.meta == nil)
+
+	var nilNode *Node
+	testTrue(t, nilNode.SetMeta("a", 1) == nil)
+	_, found = nilNode.Meta("a")
+	testTrue(t, !found)
+	testTrue(t, nilNode.Metas() == nil)
+}
+
+func TestMeta_Moves(t *testing.T) {
+	root := NewRoot().TrackOrigins()
+	root.SetKey("db.port", 5432).SetMeta("hint", "port")
+	root.GetNode("db").SetMeta("ui", "collapsed")
+	origin, _ := root.Meta(MetaOrigin)
+	testTrue(t, origin == nil)
+	origin, _ = root.GetNode("db.port").Meta(MetaOrigin)
+	testDeepEqual(t, origin, Origin{Kind: "set"})
+
+	// adopted nodes keep their metadata
+	other := NewRoot()
+	other.AddNode("moved").Adopt(root.GetNode("db.port"))
+	hint, _ := other.GetNode("moved.port").Meta("hint")
+	testDeepEqual(t, hint, "port")
+
+	// merged nodes get a copy, with their own origin
+	root.SetKey("db.port", 5433).SetMeta("hint", "port")
+	merged := NewRoot().TrackOrigins()
+	merged.Merge(root.GetNode("db"))
+	testDeepEqual(t, merged.GetNode("db").Metas(), map[string]interface{}{"ui": "collapsed"})
+	testDeepEqual(t, merged.GetNode("db.port").Metas(), map[string]interface{}{
+		"hint":     "port",
+		MetaOrigin: Origin{Kind: "merge"},
+	})
+	merged.GetNode("db.port").SetMeta("hint", "changed")
+	hint, _ = root.GetNode("db.port").Meta("hint")
+	testDeepEqual(t, hint, "port")
+	untracked := NewRoot()
+	untracked.Merge(root.GetNode("db"))
+	_, found := untracked.GetNode("db.port").Meta(MetaOrigin)
+	testTrue(t, !found)
+}
+
+func TestMeta_Serialise(t *testing.T) {
+	root := NewRoot().KeepComments()
+	testError(t, root.MergeReader(strings.NewReader("# the database\ndb.host=localhost  # local\ndb.port:int=5432\nlist.1=a\n"), true), "")
+	root.GetNode("db.port").SetMeta("deprecated", true)
+	root.GetNode("list.1").SetMeta("hint", "first")
+	comments, _ := root.GetNode("db.host").Meta(MetaComments)
+	testDeepEqual(t, comments, []string{"# the database"})
+	comment, _ := root.GetNode("db.host").Meta(MetaComment)
+	testDeepEqual(t, comment, "  # local")
+
+	// ignored by default
+	testEqualString(t, root, "{db={host=localhost,port=5432},list={1=a}}")
+	byt, err := json.Marshal(root)
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), `{"db":{"host":"localhost","port":5432},"list":["a"]}`)
+
+	byt, err = root.MarshalJSONMeta()
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), `{"db":{"host":"localhost","port":5432,"__meta":{`+
+		`"host":{"trix.comment":"  # local","trix.comments":["# the database"]},`+
+		`"port":{"deprecated":true}}},"list":["a"]}`)
+
+	root.GetNode("db").SetMeta("bad", func() {})
+	_, err = root.MarshalJSONMeta()
+	testError(t, err, "json: unsupported type: func()")
+}
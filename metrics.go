@@ -0,0 +1,75 @@
+package trix
+
+import (
+	"expvar"
+	"fmt"
+	"strconv"
+)
+
+// metricValue returns the value of the first node matching the path, to be
+// published: ints and floats (including strings that parse as such) are
+// returned as int64 and float64 respectively, other values as strings, and
+// missing values as nil.
+func (node *Node) metricValue(path string) interface{} {
+	v, err := node.TryGet(path)
+	if err != nil || v == nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return v
+	}
+
+	s := fmt.Sprint(v)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// MetricsSnapshot returns the current values of the paths, keyed by path, for
+// publishing as metrics: numeric values (including strings that parse as
+// numbers) are returned as int64 or float64, others as strings, and missing
+// ones as nil. See PublishExpvar.
+func (node *Node) MetricsSnapshot(paths ...string) map[string]interface{} {
+	snapshot := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		snapshot[path] = node.metricValue(path)
+	}
+	return snapshot
+}
+
+// PublishExpvar publishes each path as an expvar variable named
+// "<prefix>.<path>" (or just the path, if prefix is empty), whose value is
+// looked up on the node every time it's read, so changes to the tree are
+// reflected automatically; values are converted like MetricsSnapshot does.
+// If any of the names is already in use, nothing is published and an error
+// is returned instead.
+func (node *Node) PublishExpvar(prefix string, paths ...string) error {
+	names := make([]string, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for i, path := range paths {
+		names[i] = path
+		if prefix != "" {
+			names[i] = prefix + "." + path
+		}
+		if seen[names[i]] || expvar.Get(names[i]) != nil {
+			return fmt.Errorf(`Expvar "%s" is already published`, names[i])
+		}
+		seen[names[i]] = true
+	}
+
+	for i, path := range paths {
+		path := path
+		expvar.Publish(names[i], expvar.Func(func() interface{} {
+			return node.metricValue(path)
+		}))
+	}
+	return nil
+}
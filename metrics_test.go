@@ -0,0 +1,57 @@
+package trix
+
+import (
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// metricsRuns makes the expvar names unique across runs of TestMetrics, since
+// they can't be unpublished.
+var metricsRuns int
+
+func TestMetrics(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("pool.size", 10)
+	root.SetKey("pool.ratio", "0.5")
+	root.SetKey("pool.timeout", 30*time.Second)
+	root.SetKey("features.beta", "true")
+
+	paths := []string{"pool.size", "pool.ratio", "pool.timeout", "features.beta", "missing"}
+	testDeepEqual(t, root.MetricsSnapshot(paths...), map[string]interface{}{
+		"pool.size":     int64(10),
+		"pool.ratio":    0.5,
+		"pool.timeout":  "30s",
+		"features.beta": "true",
+		"missing":       nil,
+	})
+
+	metricsRuns++
+	prefix := fmt.Sprintf("test_metrics%d", metricsRuns)
+	testError(t, root.PublishExpvar(prefix, paths...), "")
+	c := func(name, expected string) {
+		t.Helper()
+		v := expvar.Get(prefix + "." + name)
+		testTrue(t, v != nil)
+		testDeepEqual(t, v.String(), expected)
+	}
+	c("pool.size", "10")
+	c("pool.ratio", "0.5")
+	c("pool.timeout", `"30s"`)
+	c("features.beta", `"true"`)
+	c("missing", "null")
+
+	// values are read on each scrape
+	root.SetKey("pool.size", "20")
+	root.SetKey("missing", "now present")
+	c("pool.size", "20")
+	c("missing", `"now present"`)
+
+	// names can't be reused
+	err := root.PublishExpvar(prefix, "other", "pool.size")
+	testError(t, err, fmt.Sprintf(`Expvar "%s.pool.size" is already published`, prefix))
+	testTrue(t, expvar.Get(prefix+".other") == nil)
+	err = root.PublishExpvar(prefix, "other", "other")
+	testError(t, err, fmt.Sprintf(`Expvar "%s.other" is already published`, prefix))
+}
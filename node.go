@@ -3,8 +3,11 @@ package trix
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
+	"sync/atomic"
+	"unsafe"
 )
 
 // NodeFlag is the type used to associate flags with a node
@@ -25,6 +28,24 @@ const (
 	// IsRoot means the node is considered a Root node.
 	// That is, `Parent` points to a parent tree, not a parent node.
 	IsRoot
+
+	// KeepSorted means the node's children are always kept sorted, as if by
+	// Sort, when they're added or renamed. Without it, the insertion order is
+	// preserved by every operation, other than Sort and SortRecursively.
+	KeepSorted
+
+	// RawJSON means that UnmarshalJSON stores the node's JSON as is, as a
+	// json.RawMessage value, instead of creating child nodes.
+	RawJSON
+
+	// FillGaps means Push uses the smallest unused positive number as the
+	// key of new children, instead of one more than the largest one.
+	FillGaps
+
+	// UseNumber means that UnmarshalJSON stores numbers as json.Number
+	// values, instead of float64 ones, so that integers that don't fit in a
+	// float64, like 64-bit IDs, keep their precision.
+	UseNumber
 )
 
 // Value is the type for a trix node
@@ -38,6 +59,65 @@ type Node struct {
 	ChildKeys []string
 	Parent    *Node
 	Flags     NodeFlag
+
+	// state is only set on roots that need one; it's a *rootState, read
+	// and set atomically. See rootState.
+	state unsafe.Pointer
+
+	// meta is only set on nodes with metadata; see SetMeta.
+	meta map[string]interface{}
+}
+
+// rootState has the state of a root that isn't part of its tree, like its
+// trackers and hooks. It's only allocated when one of them is first used, so
+// that other nodes, and roots without them, only pay for a nil pointer.
+type rootState struct {
+	// origins is only set on roots tracking origins; see TrackOrigins.
+	origins *originTracker
+
+	// comments is only set on roots keeping comments; see KeepComments.
+	comments *commentTracker
+
+	// access is only set on roots tracking access; see TrackAccess.
+	access atomic.Pointer[accessTracker]
+
+	// hookSet is only set on base roots with hooks; see OnAccess.
+	hookSet atomic.Pointer[hookSet]
+
+	// aliases is only set on base roots with aliases; see Alias.
+	aliases atomic.Pointer[aliasSet]
+
+	// sortPolicy is used by Sort; see SetSortPolicy.
+	sortPolicy SortPolicy
+
+	// limits is only set on roots with their own limits; see SetLimits.
+	limits *Limits
+}
+
+// noRootState is the state of roots without one. It must not be changed.
+var noRootState rootState
+
+// rootState returns the state of the node, which should be a root, or an
+// empty one if it has none; the result must not be changed. See
+// ensureRootState.
+func (node *Node) rootState() *rootState {
+	if node == nil {
+		return &noRootState
+	}
+	if state := (*rootState)(atomic.LoadPointer(&node.state)); state != nil {
+		return state
+	}
+	return &noRootState
+}
+
+// ensureRootState returns the state of the node, which should be a root,
+// allocating it if necessary.
+func (node *Node) ensureRootState() *rootState {
+	if state := (*rootState)(atomic.LoadPointer(&node.state)); state != nil {
+		return state
+	}
+	atomic.CompareAndSwapPointer(&node.state, nil, unsafe.Pointer(&rootState{}))
+	return (*rootState)(atomic.LoadPointer(&node.state))
 }
 
 // NewNode returns the pointer to a new, empty node.
@@ -56,10 +136,11 @@ func NewRoot() *Node {
 	return root
 }
 
-// MustLoad is a convenient method to load
+// MustLoad is a convenient method to load a file into a new root; if there's
+// an error, panic. See Load.
 func MustLoad(filename string) *Node {
-	root := NewRoot()
-	if err := root.MergeFile(filename); err != nil {
+	root, err := Load(filename)
+	if err != nil {
 		panic(fmt.Errorf("Could not load configuration from %s: %v", filename, err))
 	}
 	return root
@@ -73,6 +154,16 @@ func (node *Node) GetRoot() *Node {
 	return p
 }
 
+// baseRoot returns the root the node's scope was created from, through any
+// number of calls to With, or the node's root if it's not on a scope.
+func (node *Node) baseRoot() *Node {
+	root := node.GetRoot()
+	for root != nil && root.Parent != nil {
+		root = root.Parent.GetRoot()
+	}
+	return root
+}
+
 // Depth returns the depth of the node, that is, the number of parents it has.
 // The minimum (root node) depth is 0.
 func (node *Node) Depth() int {
@@ -96,12 +187,74 @@ func (node *Node) Path() []string {
 	return path
 }
 
+// PathString returns the node's path joined by dots. Dots and backslashes
+// within keys are escaped with a backslash, so that the path is unambiguous.
+// See GetByPathString.
+func (node *Node) PathString() string {
+	return joinPath(node.Path())
+}
+
+// GetByPathString returns the first node that matches the path string, as
+// returned by PathString, or nil if none does. Unlike the keys passed to
+// GetNode, escaped dots are not treated as separators, and "*" only matches
+// a "*" key. Parent scopes are also searched.
+func (node *Node) GetByPathString(s string) *Node {
+	if node == nil {
+		return nil
+	}
+	keys := splitPath(s)
+	if found := internalGetExact(node, keys); found != nil {
+		return found
+	}
+
+	// try the parent scopes, using the absolute path
+	keys = append(node.Path(), keys...)
+	for root := node.GetRoot().Parent; root != nil; root = root.Parent {
+		if found := internalGetExact(root, keys); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Ancestors returns the node's parents, nearest first, up to (and including)
+// the scope's root.
+func (node *Node) Ancestors() NodeList {
+	ancestors := NodeList{}
+	for n := node; n != nil && n.Parent != nil && n.Flags&IsRoot == 0; n = n.Parent {
+		ancestors = append(ancestors, n.Parent)
+	}
+	return ancestors
+}
+
+// HasAncestor returns whether the other node is one of the node's Ancestors.
+func (node *Node) HasAncestor(other *Node) bool {
+	for n := node; other != nil && n != nil && n.Parent != nil && n.Flags&IsRoot == 0; n = n.Parent {
+		if n.Parent == other {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosestWith returns the node or the nearest of its Ancestors that has a
+// child with the specified key, or nil if there is none.
+func (node *Node) ClosestWith(key string) *Node {
+	for n := node; n != nil; n = n.Parent {
+		if _, found := n.Children[key]; found {
+			return n
+		} else if n.Flags&IsRoot != 0 {
+			break
+		}
+	}
+	return nil
+}
+
 // With returns a new child root tree with the specified arguments,
 // that also inherits all values from the original one.
 func (node *Node) With(args ...Args) *Node {
 	root := node.GetRoot()
-	newRoot := NewRoot()
-	newRoot.Parent = root
+	newRoot := newScope(root)
 
 	// if this is not called from the root, a new node should be created
 	// to contain the arguments
@@ -120,6 +273,22 @@ func (node *Node) With(args ...Args) *Node {
 	return newRoot
 }
 
+// newScope returns a new, empty root stacked on top of root, which may be nil,
+// with the settings new scopes inherit from it.
+func newScope(root *Node) *Node {
+	newRoot := NewRoot()
+	newRoot.Parent = root
+	if state := root.rootState(); state != &noRootState {
+		newState := newRoot.ensureRootState()
+		if state.origins != nil {
+			newState.origins = &originTracker{}
+		}
+		newState.sortPolicy = state.sortPolicy
+		newState.limits = state.limits
+	}
+	return newRoot
+}
+
 // FromArgs returns a new root node from an args structure.
 func FromArgs(args Args) *Node {
 	root := NewRoot()
@@ -129,14 +298,30 @@ func FromArgs(args Args) *Node {
 	return root
 }
 
-// Rename changes the node's key. It does ensure the parent node is kept sorted.
+// Rename changes the node's key, keeping its position within the parent's
+// children, unless the parent has the KeepSorted flag. A sibling with the same
+// key is replaced.
 func (node *Node) Rename(newKey string) *Node {
-	if node != nil {
-		if parent := node.Parent; parent != nil {
-			parent.Unset(node.Key)
-			node.Key = newKey
-			parent.Adopt(node)
-		}
+	if node == nil || node.Key == newKey {
+		return node
+	}
+	parent := node.Parent
+	if parent == nil || node.Flags&IsRoot != 0 {
+		node.Key = newKey
+		return node
+	}
+
+	if other, found := parent.Children[newKey]; found {
+		internalDetach(other)
+	}
+	index := node.Index()
+	delete(parent.Children, node.Key)
+	node.Key = newKey
+	parent.Children[newKey] = node
+	parent.ChildKeys[index] = newKey
+	if parent.Flags&KeepSorted != 0 {
+		parent.ChildKeys = append(parent.ChildKeys[:index], parent.ChildKeys[index+1:]...)
+		internalInsertKey(parent, newKey)
 	}
 	return node
 }
@@ -149,69 +334,186 @@ func (node *Node) IsLeaf() bool {
 // Adopt the new child into the node's children, removing it from the previous
 // parent if necessary.
 func (node *Node) Adopt(child *Node) {
-	// sever link with former parent
+	// sever link with former parent; keys are used as they are, even if
+	// they have dots
 	if p := child.Parent; p != nil {
-		p.Unset(child.Key)
+		internalUnset(p, []string{child.Key})
 	}
 
 	if other, found := node.Children[child.Key]; found {
 		// there's another child with the same key; remove it
-		node.Unset(other.Key)
+		internalUnset(node, []string{other.Key})
 	}
 
 	// add the child, update its parent and depth
 	node.Children[child.Key] = child
-	node.ChildKeys = append(node.ChildKeys, child.Key)
+	internalInsertKey(node, child.Key)
 	child.Parent = node
 }
 
-// Merge a new subnode into the current one. Recursively create clones of each
-// node as necessary. Any existing nodes that aren't overwritten are kept.
-// Return the either newly-created or existing node.
-func (node *Node) Merge(original *Node) *Node {
-	if original == nil {
-		return nil
+// Index returns the position of the node within its parent's children, or -1
+// if it's a root or has no parent.
+func (node *Node) Index() int {
+	if node == nil || node.Parent == nil || node.Flags&IsRoot != 0 {
+		return -1
+	}
+	for index, key := range node.Parent.ChildKeys {
+		if key == node.Key {
+			return index
+		}
 	}
+	return -1
+}
 
-	// ensure the node exists
-	old := node.GetNode(original.Key)
-	if old == nil {
-		old = NewNode(original.Key)
-		old.Parent = node
-		node.Adopt(old)
-		node.Sort()
+// sibling returns the node's sibling at the specified offset, or nil.
+func (node *Node) sibling(offset int) *Node {
+	index := node.Index()
+	if index < 0 {
+		return nil
 	}
+	index += offset
+	if keys := node.Parent.ChildKeys; index >= 0 && index < len(keys) {
+		return node.Parent.Children[keys[index]]
+	}
+	return nil
+}
 
-	// overwrite the value
-	old.Value = original.Value
+// NextSibling returns the node's next sibling, or nil if it's the last one.
+func (node *Node) NextSibling() *Node {
+	return node.sibling(1)
+}
 
-	// merge children
-	for _, key := range original.ChildKeys {
-		old.Merge(original.Children[key])
+// PrevSibling returns the node's previous sibling, or nil if it's the first one.
+func (node *Node) PrevSibling() *Node {
+	return node.sibling(-1)
+}
+
+// Siblings returns the other children of the node's parent, in order.
+func (node *Node) Siblings() NodeList {
+	siblings := NodeList{}
+	if node.Index() < 0 {
+		return siblings
+	}
+	for _, key := range node.Parent.ChildKeys {
+		if key != node.Key {
+			siblings = append(siblings, node.Parent.Children[key])
+		}
 	}
+	return siblings
+}
+
+// InsertBefore adds the child to the node's parent, right before the node,
+// removing it from the previous parent if necessary. An existing sibling with
+// the same key is replaced. Return the child.
+func (node *Node) InsertBefore(child *Node) *Node {
+	return internalInsert(node, child, 0)
+}
+
+// InsertAfter is like InsertBefore, but adds the child right after the node.
+func (node *Node) InsertAfter(child *Node) *Node {
+	return internalInsert(node, child, 1)
+}
 
-	return old
+// Merge a new subnode into the current one. Recursively create clones of each
+// node as necessary. Any existing nodes that aren't overwritten are kept, and
+// new ones are added after them, in the original's order. Existing children
+// are matched by their exact keys, without splitting them on dots, and only in
+// the node itself, so merging into a scope never changes a parent scope, and
+// doesn't count as a read; see TrackAccess. The flags of each node, other than
+// IsRoot, are added to its clone, so that existing nodes keep theirs, unless
+// the original has ForceMap or ForceArray, which replace each other. Metadata
+// is also copied, other than the origin (see SetMeta). Return the either
+// newly-created or existing node.
+func (node *Node) Merge(original *Node) *Node {
+	return internalMerge(node, original)
 }
 
-// hasOnlyNumericKeys returns whether the node only has numeric keys
+// MergeReply adds the reply's keys as children of the node at the specified
+// path (created if necessary), replacing existing children with the same keys.
+// See Reply.ToNode for how values are stored. Return the original node, so
+// that calls can be chained, e.g. `conf.With(args).MergeReply(r, "step1")`.
+func (node *Node) MergeReply(r Reply, keys ...interface{}) *Node {
+	target := node
+	if len(keys) > 0 {
+		target = node.AddNode(keys...)
+	}
+	replyNode := r.ToNode("")
+	for _, key := range append([]string{}, replyNode.ChildKeys...) {
+		target.Adopt(replyNode.Children[key])
+	}
+	return node
+}
+
+// hasOnlyNumericKeys returns whether the node only has numeric keys, of any
+// size.
 func (node *Node) hasOnlyNumericKeys() bool {
 	for _, key := range node.ChildKeys {
-		if _, err := strconv.Atoi(key); err != nil {
+		if !isIntString(key) {
 			return false
 		}
 	}
 	return true
 }
 
-// Sort sorts a node's children by their keys.
-// Nodes with only integer keys are sorted numerically,
-// while others are sorted alphabetically.
+// SortPolicy defines how children are sorted by their keys.
+type SortPolicy byte
+
+const (
+	// SortDefault sorts the keys numerically if they're all integers, and
+	// alphabetically otherwise.
+	SortDefault SortPolicy = iota
+
+	// SortNumericFirst sorts the integer keys numerically, followed by the
+	// others, sorted alphabetically.
+	SortNumericFirst
+
+	// SortNumericLast is like SortNumericFirst, but the integer keys are
+	// sorted after the others.
+	SortNumericLast
+)
+
+// SetSortPolicy sets the policy used by Sort, SortRecursively and KeepSorted
+// on the node's root, and new scopes created from it. Return the original
+// node.
+func (node *Node) SetSortPolicy(policy SortPolicy) *Node {
+	if root := node.GetRoot(); root != nil {
+		root.ensureRootState().sortPolicy = policy
+	}
+	return node
+}
+
+// Sort sorts a node's children by their keys, using the root's policy; see
+// SetSortPolicy. By default, nodes with only integer keys are sorted
+// numerically, while others are sorted alphabetically. See KeepSorted.
 func (node *Node) Sort() {
-	if node.hasOnlyNumericKeys() {
-		NumericStringSlice(node.ChildKeys).Sort()
-	} else {
-		sort.StringSlice(node.ChildKeys).Sort()
+	node.SortWith(node.GetRoot().rootState().sortPolicy)
+}
+
+// SortWith sorts a node's children by their keys, using the policy.
+// Integer keys with the same value, like "1" and "01", are sorted
+// alphabetically.
+func (node *Node) SortWith(policy SortPolicy) {
+	if policy == SortDefault {
+		if node.hasOnlyNumericKeys() {
+			NumericStringSlice(node.ChildKeys).Sort()
+		} else {
+			sort.Strings(node.ChildKeys)
+		}
+		return
 	}
+
+	sort.SliceStable(node.ChildKeys, func(i, j int) bool {
+		a, b := node.ChildKeys[i], node.ChildKeys[j]
+		numA, numB := isIntString(a), isIntString(b)
+		switch {
+		case numA && numB:
+			return NumericStringSlice{a, b}.Less(0, 1)
+		case numA || numB:
+			// only one is numeric
+			return numA == (policy == SortNumericFirst)
+		}
+		return a < b
+	})
 }
 
 // SortRecursively will recursively sorts a node's children by their keys.
@@ -241,53 +543,135 @@ func (node *Node) Set(keys []interface{}, value Value) *Node {
 	return internalSet(node, ParseKeys(keys), value)
 }
 
-// SetKey sets a child node with the specified value.
+// SetKey sets a child node with the specified value. Empty elements in the
+// key, from leading, trailing or doubled dots, are ignored, like GetNode does,
+// so "a..b." sets "a.b"; if no elements are left, nothing is set and nil is
+// returned. See TrySetKey.
 func (node *Node) SetKey(key string, value Value) *Node {
 	return internalSet(node, ParseKeys([]interface{}{key}), value)
 }
 
+// TrySetKey is like SetKey, but returns an error, and doesn't set anything,
+// if the key has empty elements, or would exceed the root's limits; see
+// SetLimits.
+func (node *Node) TrySetKey(key string, value Value) (*Node, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	return internalTrySet(node, ParseKeys([]interface{}{key}), value)
+}
+
 // FillKey will, on the first call, set the node's value. On subsequent calls
 // it will convert the node from a list to a node, and add additional items.
-// more than one value
+// The keys are split on dots, like SetKey does, creating intermediate nodes
+// as necessary; if the node already has children, items are added using
+// Push, and its value, if any, is kept. Slice values (other than []byte and
+// json.RawMessage) are added as one item per element, both when converting
+// the node's value and when adding a new one. Return the last item added, or
+// nil if the key has no elements, like SetKey.
 func (node *Node) FillKey(keys string, value Value) *Node {
 	childNode := internalSet(node, ParseKeys([]interface{}{keys}), nil) // get/create the child node
-	var newNode *Node
-	if len(childNode.ChildKeys) == 0 {
+	if childNode == nil {
+		return nil
+	} else if len(childNode.ChildKeys) == 0 {
 		if childNode.Value == nil {
 			// the node has just been created; set its value
-			newNode = childNode
-		} else {
-			// node has a value; convert original value to a child, and push the second one
-			childNode.Push().Value = childNode.Value
-			childNode.Value = nil
+			childNode.Value = value
+			return childNode
 		}
+
+		// node has a value; convert original value to children, and push the new one
+		existing := childNode.Value
+		childNode.Value = nil
+		pushExpanded(childNode, existing)
+	}
+	return pushExpanded(childNode, value)
+}
+
+// pushExpanded pushes the value as a new child of the node, or, if it's a
+// slice, each of its elements. Return the last child pushed, or the node if
+// the slice is empty.
+func pushExpanded(node *Node, value Value) *Node {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		child := node.Push()
+		child.Value = value
+		return child
 	}
-	if newNode == nil {
-		newNode = childNode.Push()
+
+	last := node
+	for i := 0; i < v.Len(); i++ {
+		last = node.Push()
+		last.Value = v.Index(i).Interface()
 	}
-	newNode.Value = value
-	return newNode
+	return last
 }
 
-// AddNode adds a child node.
+// AddNode adds a child node. Like Set, return nil if the keys have no
+// elements.
 func (node *Node) AddNode(keys ...interface{}) *Node {
 	return node.Set(keys, nil)
 }
 
-// Push adds a new child node, usinf a unique number as the next ID.
-// This is usefull for fillin-in arrays.
-// Return the newly-created node.
+// Push adds a new child node, using the number returned by NextIndex as
+// its key. This is useful for filling-in arrays.
+// Return the newly-created node, or nil if the node is nil, e.g. when it
+// comes from AddNode with a key that has no elements, or if the child would
+// exceed the root's limits.
 func (node *Node) Push() *Node {
-	id := len(node.ChildKeys)
-	for {
-		id++
-		sid := fmt.Sprint(id)
-		if _, found := node.Children[sid]; found {
-			// index already used
-			continue
+	if node == nil {
+		return nil
+	}
+	child, _ := node.PushAt(node.NextIndex())
+	return child
+}
+
+// NextIndex returns the number Push uses as the key of the next child: one
+// more than the largest numeric key (ignoring non-numeric ones), so that keys
+// removed from the middle aren't reused, or 1 if there are none. If the node
+// has the FillGaps flag, it's the smallest unused positive number instead.
+func (node *Node) NextIndex() int {
+	if node.Flags&FillGaps != 0 {
+		for id := 1; ; id++ {
+			if _, found := node.Children[strconv.Itoa(id)]; !found {
+				return id
+			}
+		}
+	}
+
+	max := 0
+	for _, key := range node.ChildKeys {
+		if id, err := strconv.Atoi(key); err == nil && id > max {
+			max = id
+		}
+	}
+	return max + 1
+}
+
+// PushAt adds a new child node, using the index (which must be positive) as
+// its key. The child is added before the first child with a larger numeric
+// key, if any, so that numeric keys stay in order, unless the node has the
+// KeepSorted flag. If the index is already used, or the child would exceed the
+// root's limits, an error is returned. Return the newly-created node.
+func (node *Node) PushAt(index int) (*Node, error) {
+	if index < 1 {
+		return nil, fmt.Errorf("Invalid index: %d", index)
+	}
+	key := strconv.Itoa(index)
+	if _, found := node.Children[key]; found {
+		return nil, fmt.Errorf("Index %d is already used", index)
+	} else if err := newLimiter(node, false).check(node, []string{key}); err != nil {
+		return nil, err
+	}
+
+	child := NewNode(key)
+	for _, other := range node.ChildKeys {
+		if id, err := strconv.Atoi(other); err == nil && id > index && node.Flags&KeepSorted == 0 {
+			return node.Children[other].InsertBefore(child), nil
 		}
-		return node.SetKey(sid, nil)
 	}
+	node.Adopt(child)
+	return child, nil
 }
 
 // PushValues adds all specified values as subnodes, using unique number as IDs.
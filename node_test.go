@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -17,7 +18,62 @@ func TestChangeKey(t *testing.T) {
 
 	testEqualString(t, root, `{main={1=one,2=two,3=three}}`)
 	root.GetNode("main.2").Rename("two")
-	testEqualString(t, root, `{main={1=one,3=three,two=two}}`)
+	testEqualString(t, root, `{main={1=one,two=two,3=three}}`)
+	root.GetNode("main.1").Rename("3")
+	testEqualString(t, root, `{main={3=one,two=two}}`)
+	testConsistent(t, root)
+}
+
+func TestKeepSorted(t *testing.T) {
+	jsonOf := func(node *Node) string {
+		b, err := node.MarshalJSON()
+		testError(t, err, "")
+		return string(b)
+	}
+
+	// insertion order
+	root := NewRoot()
+	root.SetKey("b", 1)
+	root.SetKey("c.10", "ten")
+	root.SetKey("c.2", "two")
+	root.SetKey("a", 2)
+	testEqualString(t, root, `{b=1,c={10=ten,2=two},a=2}`)
+	testDeepEqual(t, jsonOf(root), `{"b":1,"c":["ten","two"],"a":2}`)
+	root.Adopt(NewNode("0"))
+	root.GetNode("b").Rename("z")
+	testEqualString(t, root, `{z=1,c={10=ten,2=two},a=2,0=}`)
+	other := NewRoot()
+	other.SetKey("y", 3)
+	other.SetKey("c.1", "one")
+	root.Merge(other.GetNode("c"))
+	root.Merge(other.GetNode("y"))
+	testEqualString(t, root, `{z=1,c={10=ten,2=two,1=one},a=2,0=,y=3}`)
+
+	// sorted
+	root = NewRoot()
+	root.Flags |= KeepSorted
+	root.AddNode("c").Flags |= KeepSorted
+	root.SetKey("b", 1)
+	root.SetKey("c.10", "ten")
+	root.SetKey("c.2", "two")
+	root.SetKey("a", 2)
+	testEqualString(t, root, `{a=2,b=1,c={2=two,10=ten}}`)
+	testDeepEqual(t, jsonOf(root), `{"a":2,"b":1,"c":["two","ten"]}`)
+	root.Adopt(NewNode("0"))
+	root.GetNode("b").Rename("z")
+	testEqualString(t, root, `{0=,a=2,c={2=two,10=ten},z=1}`)
+	root.Merge(other.GetNode("c"))
+	root.Merge(other.GetNode("y"))
+	testEqualString(t, root, `{0=,a=2,c={1=one,2=two,10=ten},y=3,z=1}`)
+	root.GetNode("c").Rename("b")
+	root.GetNode("a").InsertAfter(NewNode("x"))
+	testEqualString(t, root, `{0=,a=2,b={1=one,2=two,10=ten},x=,y=3,z=1}`)
+
+	// adding a non-numeric key to numeric ones
+	c := root.GetNode("b")
+	c.SetKey("x", "x")
+	testDeepEqual(t, c.ChildKeys, []string{"1", "10", "2", "x"})
+	testConsistent(t, root)
 }
 
 func TestParseKeys(t *testing.T) {
@@ -33,6 +89,41 @@ func TestParseKeys(t *testing.T) {
 		"3",
 		"5",
 	})
+
+	// wrapped keys are single elements
+	testDeepEqual(t, ParseKeys([]interface{}{
+		"a.b",
+		Key(3.5),
+		Key("1.2.3"),
+		2.5,
+		Key(""),
+		Key(true),
+	}), []string{"a", "b", "3.5", "1.2.3", "2", "5", "", "true"})
+}
+
+func TestKey(t *testing.T) {
+	root := NewRoot()
+	root.Set([]interface{}{"versions", Key(1.5), "name"}, "one and a half")
+	root.Set([]interface{}{"versions", Key("2.0.1"), "name"}, "two")
+	root.Set([]interface{}{"versions", 1.5, "name"}, "split")
+	testEqualString(t, root, "{versions={1.5={name=one and a half},2.0.1={name=two},1={5={name=split}}}}")
+
+	testDeepEqual(t, root.GetString("versions", Key(1.5), "name"), "one and a half")
+	testDeepEqual(t, root.GetString("versions", Key("2.0.1"), "name"), "two")
+	testDeepEqual(t, root.GetString("versions", 1.5, "name"), "split")
+	testDeepEqual(t, root.GetString("versions.1.5.name"), "split")
+	testDeepEqual(t, root.GetNode("versions", Key("2.0.1")).PathString(), `versions.2\.0\.1`)
+	testDeepEqual(t, root.GetStringValues("versions", "*", "name"), []string{"one and a half", "two"})
+	testDeepEqual(t, root.GetMap("versions.*.name"), Args{"1.5": "one and a half", "2.0.1": "two"})
+	testDeepEqual(t, root.GetMap("versions", Key("2.0.1")), Args{"name": "two"})
+	testDeepEqual(t, root.AddNode("versions", Key("3.0"), "x").Path(), []string{"versions", "3.0", "x"})
+	testTrue(t, root.Unset("versions", Key("3.0")) != nil)
+	testTrue(t, root.GetNode("versions", Key("3.0")) == nil)
+
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "versions.9\.9": node not found`)
+	}()
+	root.MustGetString("versions", Key("9.9"))
 }
 
 func TestDepth(t *testing.T) {
@@ -77,6 +168,85 @@ func TestMerge(t *testing.T) {
 	testEqualString(t, root3, "{point=value}")
 }
 
+func TestAdopt(t *testing.T) {
+	// children are detached from their former parent, even roots
+	parent := NewRoot()
+	child := parent.AddNode("x")
+	child.Flags |= IsRoot
+	dest := NewRoot()
+	dest.Adopt(child)
+	testTrue(t, parent.Children["x"] == nil)
+	testDeepEqual(t, parent.ChildKeys, []string{})
+	testTrue(t, dest.Children["x"] == child && child.Parent == dest)
+
+	// keys with dots are used as they are
+	parent.SetKey("a.b", 1)
+	parent.Set([]interface{}{Key("a.b")}, 2)
+	dest.Adopt(parent.GetNode(Key("a.b")))
+	testEqualString(t, parent, "{a={b=1}}")
+	dest.Adopt(NewNode("a.b"))
+	testEqualString(t, dest, "{x=,a.b=}")
+	testConsistent(t, dest)
+}
+
+func TestMerge_ExactKeys(t *testing.T) {
+	// merging into a scope doesn't change the parent scope
+	root := NewRoot()
+	root.SetKey("db.host", "a")
+	other := NewRoot()
+	other.SetKey("db.host", "b")
+	scope := root.With()
+	scope.Merge(other.GetNode("db"))
+	testDeepEqual(t, root.GetString("db.host"), "a")
+	testDeepEqual(t, scope.GetString("db.host"), "b")
+	testEqualString(t, scope, "{db={host=b}}")
+
+	// keys with dots are kept as a single element
+	dest := NewRoot()
+	dest.SetKey("v1.2", "old")
+	other.Set([]interface{}{Key("v1.2")}, "new")
+	dest.Merge(other.GetNode(Key("v1.2")))
+	testDeepEqual(t, dest.GetString("v1.2"), "old")
+	testDeepEqual(t, dest.GetString(Key("v1.2")), "new")
+}
+
+func TestMerge_Flags(t *testing.T) {
+	marshal := func(node *Node) string {
+		byt, err := node.MarshalJSON()
+		testError(t, err, "")
+		return string(byt)
+	}
+
+	source := NewRoot()
+	source.SetKey("api.items.a", "x")
+	source.SetKey("api.items.b", "y")
+	source.SetKey("api.ids.1", 10)
+	source.SetKey("api.sorted.b", 2)
+	source.GetNode("api.items").Flags |= ForceArray
+	source.GetNode("api.ids").Flags |= ForceMap
+	source.GetNode("api.sorted").Flags |= KeepSorted
+	before := marshal(source.GetNode("api"))
+	testDeepEqual(t, before, `{"items":["x","y"],"ids":{"1":10},"sorted":{"b":2}}`)
+
+	// new nodes get the flags
+	dest := NewRoot()
+	dest.Merge(source.GetNode("api"))
+	testDeepEqual(t, marshal(dest.GetNode("api")), before)
+	testDeepEqual(t, dest.Flags, IsRoot)
+
+	// existing nodes keep theirs, other than ForceMap/ForceArray
+	dest = NewRoot()
+	dest.SetKey("api.items.c", "z")
+	dest.SetKey("api.sorted.c", 3)
+	dest.SetKey("api.sorted.a", 1)
+	dest.GetNode("api.items").Flags |= ForceMap | FillGaps
+	dest.GetNode("api").Flags |= ForceMap
+	dest.Merge(source.GetNode("api"))
+	testDeepEqual(t, marshal(dest.GetNode("api")), `{"items":["z","x","y"],"sorted":{"a":1,"b":2,"c":3},"ids":{"1":10}}`)
+	testDeepEqual(t, dest.GetNode("api.items").Flags, ForceArray|FillGaps)
+	testDeepEqual(t, dest.GetNode("api").Flags, ForceMap)
+}
+
 func TestPush(t *testing.T) {
 	root1 := NewRoot()
 	root1.SetKey("settings.1.default", "label:Zip code")
@@ -104,12 +274,100 @@ func TestPush(t *testing.T) {
 	testEqualString(t, root1, root2)
 }
 
+func TestPushAt(t *testing.T) {
+	marshal := func(node *Node) string {
+		byt, err := node.MarshalJSON()
+		testError(t, err, "")
+		return string(byt)
+	}
+
+	list := NewRoot().AddNode("list")
+	testDeepEqual(t, list.NextIndex(), 1)
+	list.PushValues("a", "b", "c", "d")
+	testDeepEqual(t, marshal(list), `["a","b","c","d"]`)
+
+	// gaps aren't reused by default
+	list.Unset("2")
+	list.Unset("4")
+	testDeepEqual(t, list.NextIndex(), 4)
+	list.PushValues("e")
+	testDeepEqual(t, list.ChildKeys, []string{"1", "3", "4"})
+	testDeepEqual(t, marshal(list), `["a","c","e"]`)
+
+	// claiming a specific index
+	child, err := list.PushAt(2)
+	testError(t, err, "")
+	child.Value = "b2"
+	testDeepEqual(t, list.ChildKeys, []string{"1", "2", "3", "4"})
+	testDeepEqual(t, marshal(list), `["a","b2","c","e"]`)
+	_, err = list.PushAt(3)
+	testError(t, err, "Index 3 is already used")
+	_, err = list.PushAt(0)
+	testError(t, err, "Invalid index: 0")
+	child, _ = list.PushAt(10)
+	child.Value = "j"
+	testDeepEqual(t, list.NextIndex(), 11)
+
+	// filling gaps
+	list.Flags |= FillGaps
+	list.Unset("3")
+	testDeepEqual(t, list.NextIndex(), 3)
+	list.PushValues("c2", "f")
+	testDeepEqual(t, list.ChildKeys, []string{"1", "2", "3", "4", "5", "10"})
+	testDeepEqual(t, marshal(list), `["a","b2","c2","e","f","j"]`)
+
+	// non-numeric siblings are ignored, and keep their position
+	mixed := NewRoot().AddNode("mixed")
+	mixed.SetKey("name", "x")
+	mixed.SetKey("2", "two")
+	mixed.Push().Value = "three"
+	child, _ = mixed.PushAt(1)
+	child.Value = "one"
+	testDeepEqual(t, mixed.ChildKeys, []string{"name", "1", "2", "3"})
+	testDeepEqual(t, marshal(mixed), `{"name":"x","1":"one","2":"two","3":"three"}`)
+	mixed.Flags |= ForceArray
+	testDeepEqual(t, marshal(mixed), `["x","one","two","three"]`)
+}
+
 func TestPath(t *testing.T) {
 	root := NewRoot()
 	k := root.SetKey("settings.2.3041.s.value", "suffix:(of house)")
 	testDeepEqual(t, k.Path(), []string{"settings", "2", "3041", "s", "value"})
 }
 
+func TestPathString(t *testing.T) {
+	root := NewRoot()
+	k := root.SetKey("settings.2.value", "suffix")
+	testDeepEqual(t, k.PathString(), "settings.2.value")
+	testDeepEqual(t, root.PathString(), "")
+	testTrue(t, root.GetByPathString("") == root)
+	testTrue(t, root.GetByPathString(k.PathString()) == k)
+
+	hosts := root.AddNode("hosts")
+	for _, key := range []string{"api.example.com", `c:\temp`, `back\.slash`, "a..b.", "*"} {
+		child := NewNode(key)
+		hosts.Adopt(child)
+		testTrue(t, root.GetByPathString(child.PathString()) == child)
+	}
+	testDeepEqual(t, hosts.Children["*"].PathString(), "hosts.*")
+	testDeepEqual(t, hosts.Children["api.example.com"].PathString(), `hosts.api\.example\.com`)
+	testDeepEqual(t, hosts.Children[`c:\temp`].PathString(), `hosts.c:\\temp`)
+	testDeepEqual(t, hosts.Children[`back\.slash`].PathString(), `hosts.back\\\.slash`)
+	testTrue(t, root.GetByPathString("hosts.api.example.com") == nil)
+	testTrue(t, root.GetByPathString("missing") == nil)
+	hosts.Unset("*")
+	testTrue(t, root.GetByPathString("hosts.*") == nil)
+
+	// scopes
+	scope := root.With()
+	testTrue(t, scope.GetByPathString(`hosts.a\.\.b\.`) == hosts.Children["a..b."])
+
+	defer func() {
+		testDeepEqual(t, recover(), `Required conf key "hosts.c:\\temp.x": node not found`)
+	}()
+	root.MustGetString("hosts", `c:\temp`, "x")
+}
+
 func TestSort(t *testing.T) {
 	items := [][]string{
 		{"many.levels.deep.key", "value"},
@@ -185,6 +443,106 @@ func TestInherit(t *testing.T) {
 	testDeepEqual(t, rootC.GetStringValues("main.*.*"), []string{"three", "5", "3", "4", "1", "2"})
 }
 
+func TestScoped(t *testing.T) {
+	rootA := NewRoot()
+	rootA.SetKey("main.string.one", 1)
+	rootA.SetKey("other.string.one", "other")
+
+	rootB := rootA.With()
+	rootB.SetKey("main.string.three", 3)
+	rootB.SetKey("main.string.four", 4)
+
+	rootC := rootB.With()
+	rootC.SetKey("main.string.three", "three")
+	mainC := rootC.Scoped("main")
+	mainC.SetKey("string.five", 5) // writes under the branch
+	testDeepEqual(t, rootC.Get("main.string.five"), 5)
+
+	testDeepEqual(t, mainC.Get("string.one"), 1)         // inherited from A
+	testDeepEqual(t, mainC.Get("string.four"), 4)        // inherited from B
+	testDeepEqual(t, mainC.Get("string.three"), "three") // overwritten
+
+	// adding values to parent trees should make them available to children
+	testTrue(t, mainC.Get("string.two") == nil)
+	rootA.SetKey("main.string.two", 2)
+	testDeepEqual(t, mainC.Get("string.two"), 2)
+
+	// we should get results from all contexts, but only within the branch
+	testDeepEqual(t, mainC.GetStringValues("*.*"), []string{"three", "5", "3", "4", "1", "2"})
+	testDeepEqual(t, mainC.GetStringValues("main.*.*"), []string{})
+	testTrue(t, mainC.Get("other.string.one") == nil)
+	testTrue(t, mainC.Get("..other.string.one") == nil)
+
+	// nodes returned by the view are views of the branch too, so they still
+	// see the values of every scope, and can't go above it
+	str := mainC.GetNode("string")
+	testDeepEqual(t, str.Path(), []string{"main", "string"})
+	testDeepEqual(t, str.GetInt("one"), 1)
+	testDeepEqual(t, str.Get("three"), "three")
+	testDeepEqual(t, str.Parent().Key(), "main")
+	testTrue(t, str.Parent().Parent() == nil)
+	testDeepEqual(t, mainC.GetNodes("*")[0].GetInt("four"), 4)
+	testDeepEqual(t, len(str.Children()), 2) // only rootC's
+	testTrue(t, mainC.GetNode("missing") == nil)
+
+	// looking up a branch doesn't create it, on the node's scope or on parent
+	// ones, so values set later on any of them are found
+	rootD := rootC.With()
+	otherD := rootD.Scoped("other")
+	testDeepEqual(t, otherD.Get("string.one"), "other")
+	testTrue(t, rootD.GetNode("other").GetRoot() == rootA)
+	rootD.SetKey("other.string.one", "late")
+	testDeepEqual(t, otherD.Get("string.one"), "late")
+	testDeepEqual(t, rootD.With().Scoped("other", "string").Get("one"), "late")
+
+	comp := rootD.Scoped("comp", "sub")
+	testTrue(t, comp.Get("x") == nil)
+	testTrue(t, comp.Value() == nil)
+	testDeepEqual(t, comp.Key(), "sub")
+	testDeepEqual(t, comp.String(), "")
+	testTrue(t, rootD.GetNode("comp") == nil)
+	rootB.SetKey("comp.sub.x", "b")
+	testDeepEqual(t, comp.Get("x"), "b")
+	testTrue(t, rootD.GetNode("comp").GetRoot() == rootB)
+	comp.SetKey("y", "d")
+	testDeepEqual(t, rootD.Get("comp.sub.y"), "d")
+	testTrue(t, rootD.Scoped().Get("comp.sub.y") == "d")
+	testTrue(t, (*Node)(nil).Scoped("a") == nil)
+}
+
+func TestInherit_Nested(t *testing.T) {
+	rootA := NewRoot()
+	rootA.SetKey("main.string.one", 1)
+	rootA.SetKey("main.other.x", "a")
+	rootB := rootA.With(Args{"main.string.two": 2})
+	rootC := rootB.With(Args{"main.string.three": 3})
+	rootD := rootC.With(Args{"main.string.four": 4})
+
+	// GetNodes from a nested node, through every scope
+	for _, root := range []*Node{rootB, rootC, rootD} {
+		node := root.GetNode("main")
+		testDeepEqual(t, node.GetInt("string.one"), 1)
+		testDeepEqual(t, node.GetValues("other.*"), []Value{"a"})
+	}
+	testDeepEqual(t, rootD.GetNode("main").GetValues("string.*"), []Value{4, 3, 2, 1})
+	testDeepEqual(t, rootD.GetNode("main.string").GetValues("*"), []Value{4, 3, 2, 1})
+	testDeepEqual(t, rootD.GetNode("main.string").GetInt("one"), 1)
+
+	// scopes are followed even if their flags were replaced
+	rootC.Flags = KeepSorted
+	testDeepEqual(t, rootD.GetNode("main.string").GetValues("*"), []Value{4, 3, 2, 1})
+	rootC.Flags = IsRoot
+
+	// settings evaluated on nested nodes too
+	rootA.SetKey("main.settings.timeout.1.keys.1", "env")
+	rootA.SetKey("main.settings.timeout.1.prod.value", "10")
+	rootA.SetKey("main.settings.timeout.2.default", "5")
+	main := rootD.GetNode("main")
+	testDeepEqual(t, main.GetSettings("settings.timeout"), Reply{"value": {"5"}})
+	main = rootD.With(Args{"main.env": "prod"}).GetNode("main")
+	testDeepEqual(t, main.GetSettings("settings.timeout"), Reply{"value": {"10"}})
+}
+
 func TestInheritGetters(t *testing.T) {
 	par := NewRoot()
 	par.SetKey("number.3", "three")
@@ -285,6 +643,131 @@ func TestRoot_ForEach(t *testing.T) {
 	})
 }
 
+func TestNode_Ancestors(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("item.1.price", "10")
+	root.SetKey("item.1.name", "Socks")
+	root.SetKey("item.1.currency", "USD")
+	root.SetKey("item.2.price", "25")
+	root.SetKey("item.2.name", "Cool shirt")
+	root.SetKey("item.3.price", "17")
+	root.SetKey("item.3.name", "Coffee mug")
+	root.SetKey("currency", "EUR")
+
+	describe := func(node *Node) Value {
+		price := node.GetNode("price")
+		return fmt.Sprintf("%s (%s %s)",
+			node.Get("name"),
+			price.Value,
+			price.ClosestWith("currency").Get("currency"),
+		)
+	}
+	items := root.GetNodes("item.*").ForEach(describe)
+	testDeepEqual(t, items, []Value{
+		"Socks (10 USD)",
+		"Cool shirt (25 EUR)",
+		"Coffee mug (17 EUR)",
+	})
+
+	price := root.GetNode("item.2.price")
+	ancestors := price.Ancestors()
+	testDeepEqual(t, len(ancestors), 3)
+	testTrue(t, ancestors[0] == root.GetNode("item.2"))
+	testTrue(t, ancestors[2] == root)
+	testTrue(t, price.HasAncestor(root))
+	testTrue(t, price.HasAncestor(root.GetNode("item")))
+	testTrue(t, !price.HasAncestor(root.GetNode("item.1")))
+	testTrue(t, !price.HasAncestor(price))
+	testTrue(t, !price.HasAncestor(nil))
+	testTrue(t, price.ClosestWith("missing") == nil)
+	testTrue(t, root.GetNode("item.1").ClosestWith("currency") == root.GetNode("item.1"))
+
+	// scopes stop at the root
+	scope := root.With(Args{"item.2.price": "30"})
+	price = scope.GetNode("item.2.price")
+	testDeepEqual(t, len(price.Ancestors()), 3)
+	testTrue(t, price.Ancestors()[2] == scope)
+	testTrue(t, !price.HasAncestor(root))
+	testTrue(t, price.ClosestWith("currency") == nil)
+	testDeepEqual(t, len(scope.Ancestors()), 0)
+
+	var nilNode *Node
+	testDeepEqual(t, len(nilNode.Ancestors()), 0)
+	testTrue(t, !nilNode.HasAncestor(root))
+	testTrue(t, nilNode.ClosestWith("currency") == nil)
+}
+
+func TestNode_Siblings(t *testing.T) {
+	root := NewRoot()
+	steps := root.AddNode("steps")
+	steps.PushValues("start", "details", "confirm")
+	keys := func(nodes NodeList) []string {
+		result := []string{}
+		for _, node := range nodes {
+			result = append(result, node.Key)
+		}
+		return result
+	}
+
+	first := steps.GetNode("1")
+	testDeepEqual(t, first.Index(), 0)
+	testTrue(t, first.PrevSibling() == nil)
+	testDeepEqual(t, first.NextSibling().Value, "details")
+	testDeepEqual(t, first.NextSibling().NextSibling().Value, "confirm")
+	testTrue(t, first.NextSibling().NextSibling().NextSibling() == nil)
+	testDeepEqual(t, keys(steps.GetNode("2").Siblings()), []string{"1", "3"})
+
+	// insert
+	payment := first.NextSibling().InsertAfter(NewNode("payment"))
+	payment.Value = "payment"
+	testDeepEqual(t, steps.ChildKeys, []string{"1", "2", "payment", "3"})
+	testDeepEqual(t, payment.Index(), 2)
+	testDeepEqual(t, payment.PrevSibling().Value, "details")
+	testDeepEqual(t, payment.NextSibling().Value, "confirm")
+	welcome := first.InsertBefore(NewNode("welcome"))
+	testDeepEqual(t, steps.ChildKeys, []string{"welcome", "1", "2", "payment", "3"})
+	testDeepEqual(t, first.Index(), 1)
+	testTrue(t, first.PrevSibling() == welcome)
+
+	// moving an existing node, and replacing one with the same key
+	first.InsertAfter(welcome)
+	testDeepEqual(t, steps.ChildKeys, []string{"1", "welcome", "2", "payment", "3"})
+	replacement := payment.InsertBefore(NewNode("3"))
+	testDeepEqual(t, steps.ChildKeys, []string{"1", "welcome", "2", "3", "payment"})
+	testTrue(t, payment.PrevSibling() == replacement)
+	testTrue(t, payment.NextSibling() == nil)
+	other := NewNode("payment")
+	payment.InsertAfter(other)
+	testDeepEqual(t, steps.ChildKeys, []string{"1", "welcome", "2", "3", "payment"})
+	testTrue(t, steps.GetNode("payment") == other)
+	testDeepEqual(t, other.Index(), 4)
+	testDeepEqual(t, payment.Index(), -1)
+	testConsistent(t, root)
+
+	// sort and rename
+	steps.Sort()
+	testDeepEqual(t, steps.ChildKeys, []string{"1", "2", "3", "payment", "welcome"})
+	testDeepEqual(t, welcome.Index(), 4)
+	testDeepEqual(t, welcome.PrevSibling().Key, "payment")
+	welcome.Rename("0")
+	testDeepEqual(t, welcome.Index(), 4)
+	steps.Sort()
+	testDeepEqual(t, welcome.Index(), 0)
+	testDeepEqual(t, welcome.NextSibling().Key, "1")
+	testConsistent(t, root)
+
+	// roots, detached and nil nodes
+	testDeepEqual(t, root.Index(), -1)
+	testTrue(t, root.NextSibling() == nil)
+	testDeepEqual(t, len(root.Siblings()), 0)
+	testDeepEqual(t, NewNode("x").Index(), -1)
+	testTrue(t, root.InsertBefore(NewNode("x")).Parent == nil)
+	var nilNode *Node
+	testDeepEqual(t, nilNode.Index(), -1)
+	testTrue(t, nilNode.PrevSibling() == nil)
+	testDeepEqual(t, len(nilNode.Siblings()), 0)
+}
+
 func TestFillKey(t *testing.T) {
 	root := NewRoot()
 	testEqualString(t, root, `{}`)
@@ -298,4 +781,114 @@ func TestFillKey(t *testing.T) {
 	root.FillKey("c", 3.14)
 	root.FillKey("c", "pi")
 	testDeepEqual(t, root.Get("c.1"), 3.14)
+
+	// slices are expanded
+	root.FillKey("tags", []string{"a", "b"})
+	testDeepEqual(t, root.Get("tags"), []string{"a", "b"})
+	last := root.FillKey("tags", []string{"c", "d"})
+	testEqualString(t, root.GetNode("tags"), `{1=a,2=b,3=c,4=d}`)
+	testDeepEqual(t, last.Key, "4")
+	root.FillKey("tags", "e")
+	root.FillKey("tags", []int{})
+	testEqualString(t, root.GetNode("tags"), `{1=a,2=b,3=c,4=d,5=e}`)
+	root.FillKey("ports", 80)
+	root.FillKey("ports", []int{443, 8080})
+	testDeepEqual(t, root.Get("ports.3"), 8080)
+	root.FillKey("raw", []byte("x"))
+	root.FillKey("raw", []byte("y"))
+	testDeepEqual(t, root.Get("raw.2"), []byte("y"))
+
+	// dotted keys; existing children are kept
+	root = NewRoot()
+	root.FillKey("a.b", 1)
+	root.FillKey("a.b", 2)
+	testEqualString(t, root, `{a={b={1=1,2=2}}}`)
+	root.SetKey("x.name", "list")
+	root.SetKey("x", "value")
+	root.FillKey("x", 1)
+	root.FillKey("x", 2)
+	testEqualString(t, root, `{a={b={1=1,2=2}},x=value{name=list,1=1,2=2}}`)
+}
+
+func TestEmptyKeyElements(t *testing.T) {
+	root := NewRoot()
+	for _, key := range []string{".a.b", "a.b.", "a..b", "..a...b.."} {
+		root.SetKey(key, key)
+		testEqualString(t, root, fmt.Sprintf("{a={b=%s}}", key))
+		testDeepEqual(t, root.GetString(key), key)
+		testTrue(t, root.GetNode(key) == root.GetNode("a.b"))
+
+		_, err := root.TrySetKey(key, "strict")
+		testError(t, err, fmt.Sprintf(`Bad key "%s": empty element`, key))
+		testDeepEqual(t, root.GetString("a.b"), key)
+	}
+	testTrue(t, root.SetKey("..", "x") == nil)
+	testTrue(t, root.FillKey("", 1) == nil)
+	testTrue(t, root.FillKey("..", 1) == nil)
+	testTrue(t, root.AddNode("..").Push() == nil)
+	testTrue(t, root.AddNode("").Push() == nil)
+	testTrue(t, root.GetNode("a", "", "b") == root.GetNode("a.b"))
+	testEqualString(t, root, "{a={b=..a...b..}}")
+
+	node, err := root.TrySetKey("a.c", 1)
+	testError(t, err, "")
+	testDeepEqual(t, node.PathString(), "a.c")
+	_, err = root.TrySetKey("", 1)
+	testError(t, err, `Bad key "": empty element`)
+
+	// files and readers
+	testError(t, root.MergeReader(strings.NewReader("a.d=1\na..e=2\n"), true), `line 2: Bad key "a..e": empty element`)
+	testError(t, root.MergeReader(strings.NewReader("a.f=1\na..g=2\n"), false), "")
+	testEqualString(t, root, "{a={b=..a...b..,c=1,d=1,f=1}}")
+	_, err = LoadWith(LoadOptions{Filename: "main.conf", FS: fstest.MapFS{
+		"main.conf": {Data: []byte("a=1\nb.=2\n")},
+	}})
+	testError(t, err, `main.conf:2: Bad key "b.": empty element`)
+}
+
+func TestSortWith(t *testing.T) {
+	keys := []string{"20", "default", "100", "*", "3", "03", "b"}
+	node := func() *Node {
+		root := NewRoot()
+		for _, key := range keys {
+			root.SetKey("ids."+key, key)
+		}
+		return root.GetNode("ids")
+	}
+
+	ids := node()
+	ids.Sort()
+	testDeepEqual(t, ids.ChildKeys, []string{"*", "03", "100", "20", "3", "b", "default"})
+	ids.SortWith(SortNumericFirst)
+	testDeepEqual(t, ids.ChildKeys, []string{"03", "3", "20", "100", "*", "b", "default"})
+	ids.SortWith(SortNumericLast)
+	testDeepEqual(t, ids.ChildKeys, []string{"*", "b", "default", "03", "3", "20", "100"})
+	ids.SortWith(SortDefault)
+	testDeepEqual(t, ids.ChildKeys, []string{"*", "03", "100", "20", "3", "b", "default"})
+
+	// the root's default, used by Sort, scopes and KeepSorted
+	ids = node()
+	ids.SetSortPolicy(SortNumericFirst)
+	ids.GetRoot().SortRecursively()
+	testDeepEqual(t, ids.ChildKeys, []string{"03", "3", "20", "100", "*", "b", "default"})
+	ids.Flags |= KeepSorted
+	ids.SetKey("4", "4")
+	ids.SetKey("a", "a")
+	testDeepEqual(t, ids.ChildKeys, []string{"03", "3", "4", "20", "100", "*", "a", "b", "default"})
+	scope := ids.GetRoot().With(Args{"ids.5": "5"})
+	scope.GetNode("ids").Flags |= KeepSorted
+	scope.SetKey("ids.1", "1")
+	testDeepEqual(t, scope.GetNode("ids").ChildKeys, []string{"1", "5"})
+	scope.SetKey("ids.x", "x")
+	testDeepEqual(t, scope.GetNode("ids").ChildKeys, []string{"1", "5", "x"})
+
+	// only numeric keys
+	ids = node()
+	ids.Unset("default")
+	ids.Unset("*")
+	ids.Unset("b")
+	for _, policy := range []SortPolicy{SortDefault, SortNumericFirst, SortNumericLast} {
+		ids.SortWith(policy)
+		testDeepEqual(t, ids.ChildKeys, []string{"03", "3", "20", "100"})
+	}
 }
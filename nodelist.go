@@ -1,11 +1,19 @@
 package trix
 
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
 // NodeList represents a list of pointers to nodes
 type NodeList []*Node
 
 // ConvertValues applies the conversion function to each of the NodeList's
 // nodes that match specified keys, and replaces its value with the one
-// returned.
+// returned. Encrypted values are kept as they are, so that their plaintext
+// isn't stored in the tree.
 func (nodes NodeList) ConvertValues(conv func(*Node) Value, keys ...string) NodeList {
 	if nodes == nil {
 		return nodes
@@ -18,7 +26,7 @@ func (nodes NodeList) ConvertValues(conv func(*Node) Value, keys ...string) Node
 				break
 			}
 		}
-		if matches {
+		if _, encrypted := node.Value.(*Encrypted); matches && !encrypted {
 			node.Value = conv(node)
 		}
 	}
@@ -76,6 +84,30 @@ func (nodes NodeList) ForEach(cb func(node *Node) Value) []Value {
 	return result
 }
 
+// Each runs the specified callback on each node, stopping at the first error.
+// The error is returned wrapped with the failing node's path.
+func (nodes NodeList) Each(fn func(node *Node) error) error {
+	for _, node := range nodes {
+		if err := fn(node); err != nil {
+			return fmt.Errorf("%s: %w", node.PathString(), err)
+		}
+	}
+	return nil
+}
+
+// EachAll runs the specified callback on each node, and returns all errors
+// found, each wrapped with the failing node's path. If there are no errors,
+// nil is returned.
+func (nodes NodeList) EachAll(fn func(node *Node) error) []error {
+	var errs []error
+	for _, node := range nodes {
+		if err := fn(node); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", node.PathString(), err))
+		}
+	}
+	return errs
+}
+
 // Filter runs the specified callback on each resulting node, and returns the
 // nodes where the callback returns true.
 func (nodes NodeList) Filter(cb func(node *Node) bool) NodeList {
@@ -89,13 +121,104 @@ func (nodes NodeList) Filter(cb func(node *Node) bool) NodeList {
 }
 
 // FilterByValue returns the subset of the NodeList where the value equals
-// the specified one.
+// the specified one. Values are compared deeply, so that slices can be used.
 func (nodes NodeList) FilterByValue(value Value) NodeList {
 	return nodes.Filter(func(node *Node) bool {
-		return node.Value == value
+		return reflect.DeepEqual(node.Value, value)
 	})
 }
 
+// FilterByStringValue returns the subset of the NodeList where the value,
+// converted to a string, equals the specified one.
+func (nodes NodeList) FilterByStringValue(s string) NodeList {
+	return nodes.Filter(func(node *Node) bool {
+		return node.internalStringValue() == s
+	})
+}
+
+// MergeInto merges every node in the list into dest with dest.Merge, in list
+// order, so that nodes with the same key, like the "http" nodes of
+// "defaults.http" and "overrides.http", are merged into the same child of
+// dest, and values from later nodes overwrite those from earlier ones, even
+// if they have none. Note that when the list comes from a stacked-scope
+// GetNodes call, the top-most scope comes first, so the bottom-most one wins;
+// use Reverse to give the top-most one precedence, as the getters do.
+// Children are not sorted; call dest.SortRecursively if needed. Return dest.
+func (nodes NodeList) MergeInto(dest *Node) *Node {
+	for _, node := range nodes {
+		dest.Merge(node)
+	}
+	return dest
+}
+
+// Merged merges all nodes in the list into a new root, and returns it.
+// See MergeInto.
+func (nodes NodeList) Merged() *Node {
+	return nodes.MergeInto(NewRoot())
+}
+
+// Detach removes each node in the list from its parent, and returns the same
+// list. Nodes that have no parent (including roots) are skipped.
+func (nodes NodeList) Detach() NodeList {
+	for _, node := range nodes {
+		if node != nil {
+			internalDetach(node)
+		}
+	}
+	return nodes
+}
+
+// SortByKey returns a new NodeList, sorted by the nodes' keys. If all keys
+// are numeric they are sorted numerically, otherwise alphabetically.
+func (nodes NodeList) SortByKey() NodeList {
+	numeric := true
+	for _, node := range nodes {
+		if !isIntString(node.Key) {
+			numeric = false
+			break
+		}
+	}
+
+	result := append(NodeList{}, nodes...)
+	sort.SliceStable(result, func(i, j int) bool {
+		if numeric {
+			return NumericStringSlice{result[i].Key, result[j].Key}.Less(0, 1)
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// SortByValue returns a new NodeList, sorted by the value of the child
+// matching the spec (or by the node's own value if no keys are specified).
+// Values that can be converted to numbers are compared numerically, and
+// sorted before the remaining ones, which are compared as strings.
+func (nodes NodeList) SortByValue(keys ...interface{}) NodeList {
+	result := append(NodeList{}, nodes...)
+	sort.SliceStable(result, func(i, j int) bool {
+		si, sj := result[i].GetString(keys...), result[j].GetString(keys...)
+		fi, erri := strconv.ParseFloat(si, 64)
+		fj, errj := strconv.ParseFloat(sj, 64)
+		switch {
+		case erri == nil && errj == nil:
+			return fi < fj
+		case erri == nil || errj == nil:
+			return erri == nil
+		}
+		return si < sj
+	})
+	return result
+}
+
+// Reverse returns a new NodeList with the nodes in reverse order.
+func (nodes NodeList) Reverse() NodeList {
+	result := make(NodeList, len(nodes))
+	for i, node := range nodes {
+		result[len(nodes)-1-i] = node
+	}
+	return result
+}
+
 // First returns the first node from the list, or nil if the list is empty.
 func (nodes NodeList) First() *Node {
 	if len(nodes) == 0 {
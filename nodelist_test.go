@@ -0,0 +1,184 @@
+package trix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNodeList_Each(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("item.1.price", "10")
+	root.SetKey("item.2.price", "lots")
+	root.SetKey("item.3.price", "free")
+
+	errBadPrice := errors.New("bad price")
+	validate := func(node *Node) error {
+		if _, err := node.TryGetInt("price"); err != nil {
+			return errBadPrice
+		}
+		return nil
+	}
+
+	// stop at the first error, include the path
+	seen := 0
+	err := root.GetNodes("item.*").Each(func(node *Node) error {
+		seen++
+		return validate(node)
+	})
+	testError(t, err, "item.2: bad price")
+	testTrue(t, errors.Is(err, errBadPrice))
+	testDeepEqual(t, seen, 2)
+
+	// collect all errors
+	errs := root.GetNodes("item.*").EachAll(validate)
+	testDeepEqual(t, len(errs), 2)
+	testError(t, errs[0], "item.2: bad price")
+	testError(t, errs[1], "item.3: bad price")
+
+	// empty lists
+	testError(t, NodeList(nil).Each(validate), "")
+	testTrue(t, NodeList(nil).EachAll(validate) == nil)
+	testTrue(t, root.GetNodes("item.1").EachAll(validate) == nil)
+}
+
+func TestNodeList_MergeInto(t *testing.T) {
+	base := NewRoot()
+	base.SetKey("http.port", 80)
+	base.SetKey("http.host", "localhost")
+	base.SetKey("http.tls.enabled", false)
+
+	top := base.With()
+	top.SetKey("http.port", 8080)
+	top.SetKey("http.tls.cert", "cert.pem")
+
+	// GetNodes returns the top-most scope first, so later (lower) scopes win
+	nodes := top.GetNodes("http")
+	testDeepEqual(t, len(nodes), 2)
+	testEqualString(t, nodes.Merged(), `{http={port=80,tls={cert=cert.pem,enabled=false},host=localhost}}`)
+
+	// reversing gives the top-most scope precedence, as the getters do
+	merged := nodes.Reverse().Merged()
+	testEqualString(t, merged, `{http={port=8080,host=localhost,tls={enabled=false,cert=cert.pem}}}`)
+	testDeepEqual(t, merged.GetInt("http.port"), top.GetInt("http.port"))
+	merged.SortRecursively()
+	testEqualString(t, merged, `{http={host=localhost,port=8080,tls={cert=cert.pem,enabled=false}}}`)
+
+	// branches with the same key are merged together, and others are kept
+	// apart; merging into an existing node keeps its other children
+	conf := NewRoot()
+	conf.SetKey("defaults.http.port", 80)
+	conf.SetKey("defaults.http.host", "localhost")
+	conf.SetKey("overrides.http.port", 8080)
+	conf.SetKey("overrides.db.host", "db")
+	dest := NewRoot()
+	dest.SetKey("timeout", "10s")
+	testTrue(t, conf.GetNodes("*.*").MergeInto(dest) == dest)
+	testEqualString(t, dest, `{timeout=10s,http={port=8080,host=localhost},db={host=db}}`)
+
+	// flags and metadata of the nodes themselves are carried too, and their
+	// values overwrite the earlier ones, even if they have none
+	defaults := NewRoot()
+	defaults.SetKey("list.value", "x")
+	defaults.GetNode("list").PushValues(1)
+	defaults.GetNode("list").Flags |= ForceMap
+	defaults.GetNode("list").SetMeta("doc", "a list")
+	defaults.GetNode("list").Value = "old"
+	overrides := NewRoot()
+	overrides.SetKey("list.2", 2)
+	overrides.GetNode("list").Flags |= ForceArray | KeepSorted
+	merged = NodeList{defaults.GetNode("list"), nil, overrides.GetNode("list")}.Merged()
+	list := merged.GetNode("list")
+	testDeepEqual(t, list.Flags, ForceArray|KeepSorted)
+	testEqualString(t, list, `{1=1,2=2,value=x}`)
+	testTrue(t, list.Value == nil)
+	doc, _ := list.Meta("doc")
+	testDeepEqual(t, doc, "a list")
+
+	// sources are unchanged
+	testEqualString(t, base, `{http={port=80,host=localhost,tls={enabled=false}}}`)
+	testEqualString(t, NodeList(nil).Merged(), `{}`)
+}
+
+func TestNodeList_Detach(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("a.deprecated", 1)
+	root.SetKey("a.keep", 2)
+	root.SetKey("b.deprecated.deprecated", 3)
+	root.SetKey("b.keep", 4)
+
+	nodes := root.GetNodes("*.deprecated")
+	nodes = append(nodes, root.GetNode("b.deprecated.deprecated")) // descendant of another
+	nodes = append(nodes, root)                                    // roots are skipped
+	testTrue(t, len(nodes.Detach()) == 4)
+	testEqualString(t, root, `{a={keep=2},b={keep=4}}`)
+	testConsistent(t, root)
+	for _, node := range nodes[:3] {
+		testTrue(t, node.Parent == nil)
+		testConsistent(t, node)
+	}
+
+	// detaching again is a no-op
+	nodes.Detach()
+	testEqualString(t, root, `{a={keep=2},b={keep=4}}`)
+
+	// combined with Filter
+	root.SetKey("c.x", "old")
+	root.SetKey("c.y", "new")
+	root.GetNodes("c.*").Filter(func(node *Node) bool {
+		return node.Value == "old"
+	}).Detach()
+	testEqualString(t, root, `{a={keep=2},b={keep=4},c={y=new}}`)
+	testConsistent(t, root)
+}
+
+func TestNodeList_FilterByValue(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("v.1", "a")
+	root.SetKey("v.2", []string{"a", "b"})
+	root.SetKey("v.3", []int{1, 2})
+	root.SetKey("v.4", 1)
+	root.SetKey("v.5", "1")
+	nodes := root.GetNodes("v.*")
+
+	keys := func(nodes NodeList) []string {
+		result := []string{}
+		for _, node := range nodes {
+			result = append(result, node.Key)
+		}
+		return result
+	}
+
+	testDeepEqual(t, keys(nodes.FilterByValue("a")), []string{"1"})
+	testDeepEqual(t, keys(nodes.FilterByValue(1)), []string{"4"})
+	testDeepEqual(t, keys(nodes.FilterByValue([]string{"a", "b"})), []string{"2"})
+	testDeepEqual(t, keys(nodes.FilterByValue([]int{1, 2})), []string{"3"})
+	testDeepEqual(t, keys(nodes.FilterByValue([]int{1})), []string{})
+	testDeepEqual(t, keys(nodes.FilterByStringValue("1")), []string{"4", "5"})
+	testDeepEqual(t, keys(nodes.FilterByStringValue("[a b]")), []string{"2"})
+}
+
+func TestNodeList_Sort(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("item.10.price", "10")
+	root.SetKey("item.2.price", "2.5")
+	root.SetKey("item.b.price", "free")
+	root.SetKey("item.a.price", "100")
+	root.SetKey("item.1", "z")
+
+	keys := func(nodes NodeList) []string {
+		result := []string{}
+		for _, node := range nodes {
+			result = append(result, node.Key)
+		}
+		return result
+	}
+
+	nodes := root.GetNodes("item.*")
+	testDeepEqual(t, keys(nodes.SortByKey()), []string{"1", "10", "2", "a", "b"})
+	testDeepEqual(t, keys(NodeList{nodes[0], nodes[1], nodes[4]}.SortByKey()), []string{"1", "2", "10"})
+	testDeepEqual(t, keys(nodes.SortByValue("price")), []string{"2", "10", "a", "1", "b"})
+	testDeepEqual(t, keys(nodes), []string{"10", "2", "b", "a", "1"}) // unchanged
+	testDeepEqual(t, root.GetKeys("item.*"), []string{"10", "2", "b", "a", "1"})
+	testDeepEqual(t, root.GetKeys("item.*.price"), []string{"price"})
+	testDeepEqual(t, root.GetKeys("missing.*"), []string{})
+}
@@ -0,0 +1,107 @@
+package trix
+
+import "fmt"
+
+// Origin describes where a node's value was set.
+type Origin struct {
+	// Kind is how the value was set: "set" (SetKey and similar), "merge"
+	// (Merge), "file" (MergeFile, Load), "reader" (MergeReader) or "env"
+	// (MergeEnv).
+	Kind string
+
+	// Source is the filename for "file" origins, and the environment
+	// variable's name for "env" ones.
+	Source string
+
+	// Line is the line number for "file" and "reader" origins.
+	Line int
+
+	// Scope is the number of parent scopes of the root where the value was
+	// set, that is, 0 for the bottom-most scope.
+	Scope int
+}
+
+// String returns a short description of the origin.
+func (origin Origin) String() string {
+	var s string
+	switch {
+	case origin.Kind == "file":
+		s = fmt.Sprintf("%s:%d", origin.Source, origin.Line)
+	case origin.Kind == "reader":
+		s = fmt.Sprintf("reader:%d", origin.Line)
+	case origin.Kind == "env":
+		s = "$" + origin.Source
+	default:
+		s = origin.Kind
+	}
+	if origin.Scope > 0 {
+		s += fmt.Sprintf(" (scope %d)", origin.Scope)
+	}
+	return s
+}
+
+// originTracker is set on roots tracking origins; the origins themselves are
+// kept as the nodes' metadata (see MetaOrigin). If current is set, it's used
+// as the origin of the values being set.
+type originTracker struct {
+	current *Origin
+}
+
+// TrackOrigins enables recording the Origin of values set under the node's
+// root from now on, as well as on new scopes created from it. Return the
+// original node.
+func (node *Node) TrackOrigins() *Node {
+	if root := node.GetRoot(); root != nil && root.rootState().origins == nil {
+		root.ensureRootState().origins = &originTracker{}
+	}
+	return node
+}
+
+// Origin returns where the value of the first node that matches the spec was
+// set, and whether it's known; it isn't if origins were not being tracked at
+// the time. See TrackOrigins.
+func (node *Node) Origin(keys ...interface{}) (Origin, bool) {
+	found := node.GetNode(keys...)
+	if found == nil {
+		return Origin{}, false
+	}
+	return originOf(found)
+}
+
+// internalRecordOrigin records the origin of the node's value, if its root is
+// tracking origins.
+func internalRecordOrigin(node *Node, kind string) {
+	root := node.GetRoot()
+	tracker := root.rootState().origins
+	if tracker == nil {
+		return
+	}
+	origin := Origin{Kind: kind}
+	if tracker.current != nil {
+		origin = *tracker.current
+	}
+	for scope := root.Parent; scope != nil; scope = scope.Parent {
+		origin.Scope++
+	}
+	node.SetMeta(MetaOrigin, origin)
+}
+
+// internalSetFrom is like internalSet, but records the value as coming from
+// the specified origin, if the node's root is tracking origins, and checks
+// the limits of the input, if limit isn't nil.
+func internalSetFrom(node *Node, keys []string, value Value, origin Origin, limit *limiter) (*Node, error) {
+	if err := limit.check(node, keys); err != nil {
+		return nil, err
+	}
+	if tracker := node.GetRoot().rootState().origins; tracker != nil {
+		tracker.current = &origin
+		defer func() { tracker.current = nil }()
+	}
+	return internalTrySet(node, keys, value)
+}
+
+// originOf returns the node's origin, if known.
+func originOf(node *Node) (Origin, bool) {
+	origin, ok := node.meta[MetaOrigin].(Origin)
+	return origin, ok
+}
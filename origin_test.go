@@ -0,0 +1,79 @@
+package trix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOrigin(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/main.conf":  {Data: []byte("db.host=localhost\ndb.port=5432\ninclude local.conf\n")},
+		"etc/local.conf": {Data: []byte("# overrides\ndb.host=db.internal\n")},
+	}
+
+	// disabled by default
+	root, err := LoadWith(LoadOptions{Filename: "etc/main.conf", FS: fsys})
+	testError(t, err, "")
+	testTrue(t, root.rootState().origins == nil)
+	_, ok := root.Origin("db.host")
+	testTrue(t, !ok)
+
+	root = NewRoot().TrackOrigins()
+	_, err = LoadWith(LoadOptions{Filename: "etc/main.conf", FS: fsys, Into: root})
+	testError(t, err, "")
+	origin, ok := root.Origin("db.host")
+	testTrue(t, ok)
+	testDeepEqual(t, origin, Origin{Kind: "file", Source: "etc/local.conf", Line: 2})
+	origin, _ = root.Origin("db.port")
+	testDeepEqual(t, origin.String(), "etc/main.conf:2")
+	_, ok = root.Origin("db")
+	testTrue(t, !ok)
+	_, ok = root.Origin("missing")
+	testTrue(t, !ok)
+
+	// other sources
+	root.SetKey("db.port", 5433)
+	origin, _ = root.Origin("db.port")
+	testDeepEqual(t, origin, Origin{Kind: "set"})
+	testError(t, root.MergeReader(strings.NewReader("\ndb.user=app\n"), true), "")
+	origin, _ = root.Origin("db.user")
+	testDeepEqual(t, origin.String(), "reader:2")
+	root.MergeEnv("app", []string{"APP_DB_NAME=payments"})
+	origin, _ = root.Origin("db.name")
+	testDeepEqual(t, origin.String(), "$APP_DB_NAME")
+	root.AddNode("copy").Merge(root.GetNode("db"))
+	origin, _ = root.Origin("copy.db.name")
+	testDeepEqual(t, origin.String(), "merge")
+
+	// scopes
+	scope := root.With(Args{"db.host": "override"})
+	origin, _ = scope.Origin("db.host")
+	testDeepEqual(t, origin.String(), "set (scope 1)")
+	origin, _ = scope.Origin("db.port")
+	testDeepEqual(t, origin.String(), "set")
+
+	buf := bytes.Buffer{}
+	root.GetNode("db").DumpWith(&buf, DumpOptions{Origins: true})
+	testDeepEqual(t, buf.String(), strings.Join([]string{
+		"db.host=db.internal # etc/local.conf:2",
+		"db.port=5433 # set",
+		"db.user=app # reader:2",
+		"db.name=payments # $APP_DB_NAME",
+		"",
+	}, "\n"))
+
+	// with other options
+	root.TrackAccess(true)
+	root.GetString("db.port")
+	buf.Reset()
+	root.GetNode("db").DumpWith(&buf, DumpOptions{Origins: true, MarkUnread: true})
+	testDeepEqual(t, strings.SplitN(buf.String(), "\n", 3)[:2], []string{
+		"db.host=db.internal # etc/local.conf:2, unread",
+		"db.port=5433 # set",
+	})
+	buf.Reset()
+	root.GetNode("db").DumpWith(&buf, DumpOptions{Origins: true, Short: true})
+	testDeepEqual(t, buf.String(), "{host=db.internal,port=5433,user=app,name=payments}")
+}
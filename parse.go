@@ -2,10 +2,13 @@ package trix
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
@@ -13,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 var (
@@ -31,8 +35,11 @@ var (
 	reParseIgnore  = regexp.MustCompile(`^\s*(#.*)?$`)              // ignore comments and empty lines
 	reParseInclude = regexp.MustCompile(`^\s*include ([^\s]+)\s*$`) // include other files
 
+	// trailing comments, when keeping comments
+	reParseTrailingComment = regexp.MustCompile(`\s+#`)
+
 	// regular key/value, optionally typed
-	reParseEntry = regexp.MustCompile(`^\s*([^=\s][^=]*?)(?:[:]((?:\[\])?(?:string|int|float|bool|duration|date|time)))?\s*=\s*(.*?)\s*$`)
+	reParseEntry = regexp.MustCompile(`^\s*([^=\s][^=]*?)(?:[:]((?:\[\])?(?:string|int|float|bool|duration|date|time)|enc|quoted))?\s*=\s*(.*?)\s*$`)
 
 	knownTimeLayouts = []string{
 		time.RFC3339Nano,
@@ -89,35 +96,95 @@ func parseDuration(v interface{}) (time.Duration, error) {
 		return time.Duration(0), ErrParseDuration
 	}
 
+	var units []time.Duration
 	matches := durationRegexHMS.FindStringSubmatch(s)
 	if matches != nil {
-		hours, _ := strconv.ParseInt(matches[1], 10, 64)
-		minutes, _ := strconv.ParseInt(matches[2], 10, 64)
-		seconds, _ := strconv.ParseInt(matches[3], 10, 64)
-		return time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes) + time.Second*time.Duration(seconds), nil
+		units = []time.Duration{time.Hour, time.Minute, time.Second}
+	} else if matches = durationRegex.FindStringSubmatch(s); matches != nil {
+		units = []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	} else {
+		return time.Duration(0), ErrParseDuration
 	}
 
-	if matches = durationRegex.FindStringSubmatch(s); matches == nil {
-		return time.Duration(0), ErrParseDuration
+	// add up the parts, failing instead of overflowing
+	var total time.Duration
+	for i, unit := range units {
+		if matches[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(matches[i+1], 10, 64)
+		if err != nil || n > int64(math.MaxInt64/unit) {
+			return time.Duration(0), ErrParseDuration
+		}
+		part := time.Duration(n) * unit
+		if total > math.MaxInt64-part {
+			return time.Duration(0), ErrParseDuration
+		}
+		total += part
 	}
+	return total, nil
+}
 
-	prs := func(s string) int64 {
-		i, err := strconv.ParseInt(s, 10, 64)
-		if err != nil {
-			return 0
+// FormatDuration formats a duration in the format accepted by duration
+// getters, e.g. "2d1h20m": days (of 24 hours), hours, minutes and seconds,
+// omitting zero components. Negative durations and durations with a fraction
+// of a second are formatted like time.Duration.String does.
+func FormatDuration(d time.Duration) string {
+	if d < 0 || d%time.Second != 0 {
+		return d.String()
+	} else if d == 0 {
+		return "0s"
+	}
+
+	s := ""
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := d / unit.size; n > 0 {
+			s += strconv.FormatInt(int64(n), 10) + unit.suffix
+			d -= n * unit.size
 		}
-		return i
 	}
+	return s
+}
 
-	days := prs(matches[1])
-	hours := prs(matches[2])
-	minutes := prs(matches[3])
-	seconds := prs(matches[4])
+// sizeUnits are the multipliers of the units accepted by ParseSize.
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// ParseSize parses a size in bytes, like "512MB" or "1.5GiB": a number,
+// possibly fractional, followed by an optional unit, case-insensitively:
+// "B", decimal units ("KB", "MB", "GB" and "TB") or binary ones ("KiB",
+// "MiB", "GiB" and "TiB"). Sizes with a fraction of a byte are rounded.
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	number := strings.TrimRightFunc(trimmed, unicode.IsLetter)
+	unit := strings.ToLower(trimmed[len(number):])
+	number = strings.TrimSpace(number)
+	multiplier, found := sizeUnits[unit]
+	if !found {
+		return 0, fmt.Errorf(`Bad size "%s": unknown unit "%s"`, s, trimmed[len(trimmed)-len(unit):])
+	}
 
-	hour := int64(time.Hour)
-	minute := int64(time.Minute)
-	second := int64(time.Second)
-	return time.Duration((days*24+hours)*hour + minutes*minute + seconds*second), nil
+	if n, err := strconv.ParseInt(number, 10, 64); err == nil {
+		if n < 0 || n > math.MaxInt64/multiplier {
+			return 0, fmt.Errorf(`Bad size "%s": out of range`, s)
+		}
+		return n * multiplier, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, fmt.Errorf(`Bad size "%s"`, s)
+	}
+	size := math.Round(f * float64(multiplier))
+	if size < 0 || size >= math.MaxInt64 {
+		return 0, fmt.Errorf(`Bad size "%s": out of range`, s)
+	}
+	return int64(size), nil
 }
 
 // parseTime parse timestamps in various formats.
@@ -134,57 +201,201 @@ func parseTime(v interface{}) (time.Time, error) {
 }
 
 // UnmarshalJSON will parse the JSON data into the node, creating child nodes
-// as necessary.
+// as necessary. Nodes with the RawJSON flag, including the node itself, get
+// their JSON as is, as a json.RawMessage value. Besides objects, arrays are
+// stored as children with numeric keys, starting at 1 (with the ForceArray
+// flag on the node itself, for top-level ones), and other values as the
+// node's value; numbers are float64 values, or json.Number ones if the node
+// has the UseNumber flag.
 func (node *Node) UnmarshalJSON(b []byte) error {
-	var values map[string]interface{}
-	if err := json.Unmarshal(b, &values); err != nil {
-		return err
+	if node.Flags&RawJSON != 0 {
+		node.Value = append(json.RawMessage{}, b...)
+		return nil
 	}
 
-	var set func([]string, interface{})
-	set = func(keys []string, value interface{}) {
-		if asMap, ok := value.(map[string]interface{}); ok {
-			for key, value := range asMap {
-				set(append(keys, key), value)
+	limit := newLimiter(node, false)
+	depth := node.Depth()
+	var set func([]string, json.RawMessage) error
+	set = func(keys []string, raw json.RawMessage) error {
+		key := strings.Join(keys, ".")
+		if len(keys) > 0 {
+			if existing := internalGetExact(node, strings.Split(key, ".")); existing != nil && existing.Flags&RawJSON != 0 {
+				existing.Value = append(json.RawMessage{}, raw...)
+				return nil
+			}
+		}
+
+		var value interface{}
+		switch trimmed := bytes.TrimLeft(raw, " \t\r\n"); {
+		case len(trimmed) > 0 && trimmed[0] == '{':
+			if err := limit.checkDepth(depth + len(keys)); err != nil {
+				return err
+			}
+			objectKeys, values, err := decodeJSONObject(raw)
+			if err != nil {
+				return err
+			}
+			for i, key := range objectKeys {
+				if err := set(append(keys, key), values[i]); err != nil {
+					return err
+				}
 			}
-		} else if asArray, ok := value.([]interface{}); ok {
-			for i, value := range asArray {
-				set(append(keys, fmt.Sprint(i+1)), value)
+			return nil
+		case len(trimmed) > 0 && trimmed[0] == '[':
+			if err := limit.checkDepth(depth + len(keys)); err != nil {
+				return err
+			}
+			var asArray []json.RawMessage
+			if err := json.Unmarshal(raw, &asArray); err != nil {
+				return err
+			}
+			if len(keys) == 0 {
+				node.Flags |= ForceArray
+			}
+			for i, raw := range asArray {
+				if err := set(append(keys, fmt.Sprint(i+1)), raw); err != nil {
+					return err
+				}
 			}
+			return nil
+		}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if _, isNumber := value.(float64); isNumber && node.Flags&UseNumber != 0 {
+			value = json.Number(bytes.TrimSpace(raw))
+		}
+		if len(keys) == 0 {
+			node.Value = value
+		} else if err := limit.check(node, ParseKeys([]interface{}{key})); err != nil {
+			return err
 		} else {
-			node.SetKey(strings.Join(keys, "."), value)
+			node.SetKey(key, value)
+		}
+		return nil
+	}
+	return set(nil, b)
+}
+
+// decodeJSONObject returns the keys of the JSON object, in order, and their
+// values.
+func decodeJSONObject(raw json.RawMessage) ([]string, []json.RawMessage, error) {
+	// check the syntax first, so that errors are the usual ones
+	if !json.Valid(raw) {
+		var v interface{}
+		return nil, nil, json.Unmarshal(raw, &v)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil { // the opening brace
+		return nil, nil, err
+	}
+	keys := []string{}
+	values := []json.RawMessage{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
 		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, token.(string))
+		values = append(values, value)
 	}
+	return keys, values, nil
+}
 
-	set([]string{}, values)
-	return nil
+// LineError is an error found in a line read by MergeReader.
+type LineError struct {
+	Line int    // line number, starting at 1
+	Text string // the line's text
+	Err  error
 }
 
+func (e LineError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+
+// Unwrap returns the underlying error.
+func (e LineError) Unwrap() error { return e.Err }
+
 // MergeReader will read lines entries from the reader, parse them and merge
 // entries under the current node. If stopOnErrors is true, whevener a line is
 // found that isn't recognized as whitespace (empty lines, comments) or
-// a key-value, the parsing stops and an error is returned. If it is false,
-// bad lines are simply ignored.
+// a key-value, or whose value can't be parsed as its type, the parsing stops
+// and a LineError is returned. If it is false, bad lines are simply ignored;
+// see MergeReaderLenient to get them. Comments are kept as with MergeFile.
 func (node *Node) MergeReader(reader io.Reader, stopOnErrors bool) error {
+	errs := internalMergeReader(node, reader, stopOnErrors)
+	if stopOnErrors && len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// MergeReaderLenient works like MergeReader with stopOnErrors false, but
+// returns the lines that were skipped, in order.
+func (node *Node) MergeReaderLenient(reader io.Reader) []LineError {
+	return internalMergeReader(node, reader, false)
+}
+
+// internalMergeReader merges the entries read from the reader into the node,
+// and returns the bad lines found; if stopOnErrors is true, it stops at the
+// first one.
+func internalMergeReader(node *Node, reader io.Reader, stopOnErrors bool) []LineError {
+	tracker := node.GetRoot().rootState().comments
+	limit := newLimiter(node, false)
 	scanner := bufio.NewScanner(reader)
 	lineNumber := 0
+	var (
+		pending []string
+		errs    []LineError
+	)
 	for scanner.Scan() {
 		lineNumber++
-		if line := scanner.Text(); reParseIgnore.MatchString(line) {
+		line := scanner.Text()
+		var err error
+		if reParseIgnore.MatchString(line) {
+			if tracker != nil {
+				pending = append(pending, line)
+			}
 			continue
 		} else if matches := reParseEntry.FindStringSubmatch(line); matches != nil && len(matches) == 4 {
 			// regular entry
-			value, err := parseValueType(matches[2], matches[3])
-			if err != nil {
-				return err
+			var keys []string
+			if keys, err = parseConfKey(matches[1]); err == nil {
+				rawValue, comment := matches[3], ""
+				if tracker != nil {
+					rawValue, comment = splitTrailingComment(matches[2], rawValue)
+				}
+				var value Value
+				if value, err = parseValueType(matches[2], rawValue); err == nil {
+					var n *Node
+					origin := Origin{Kind: "reader", Line: lineNumber}
+					if n, err = internalSetFrom(node, keys, value, origin, limit); err == nil {
+						tracker.set(n, pending, comment)
+						pending = nil
+						continue
+					}
+				}
 			}
-			node.SetKey(matches[1], value)
-		} else if stopOnErrors {
+		} else {
 			// unknown/syntax error
-			return fmt.Errorf(`line %d: bad format: "%s"`, lineNumber, line)
+			err = fmt.Errorf(`bad format: "%s"`, line)
+		}
+
+		errs = append(errs, LineError{Line: lineNumber, Text: line, Err: err})
+		if stopOnErrors {
+			return errs
 		}
 	}
-	return nil
+	if err := scanner.Err(); err != nil {
+		// e.g. a line too long
+		errs = append(errs, LineError{Line: lineNumber + 1, Err: err})
+		return errs
+	}
+	tracker.setFooter(node, pending)
+	return errs
 }
 
 // MergeArgs merge the arguments with the node.
@@ -201,13 +412,15 @@ type tRegularFS struct{}
 type tFile interface {
 	io.Closer
 	io.Reader
-	io.ReaderAt
-	io.Seeker
-	Stat() (os.FileInfo, error)
 }
 
 func (tRegularFS) Open(name string) (tFile, error) { return os.Open(name) }
 
+// tFS implements tfileSystem using an fs.FS.
+type tFS struct{ fsys fs.FS }
+
+func (f tFS) Open(name string) (tFile, error) { return f.fsys.Open(fsPath(name)) }
+
 var regularFS tfileSystem = tRegularFS{}
 
 type tfileSystem interface {
@@ -220,6 +433,15 @@ func parseValueType(valueType, value string) (Value, error) {
 		return value, nil
 	case "[]string":
 		return splitEsc(value, ",", `\`), nil
+	case "enc":
+		return &Encrypted{Ciphertext: value}, nil
+	case "quoted":
+		// a trailing comment may follow the closing quote
+		quoted, err := strconv.QuotedPrefix(value)
+		if rest := strings.TrimSpace(value[len(quoted):]); err != nil || rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("Bad quoted string: %s", value)
+		}
+		return strconv.Unquote(quoted)
 
 	case "int":
 		return parseInt(value)
@@ -287,12 +509,79 @@ func parseValueType(valueType, value string) (Value, error) {
 		return slice, nil
 
 	default:
-		return fmt.Errorf(`Bad type: "%s"`, valueType), nil
+		return nil, fmt.Errorf(`Bad type: "%s"`, valueType)
 	}
 }
 
-func internalMergeFile(os tfileSystem, node *Node, filename string) error {
+// parsedEntry is a key/value found by internalParseFile.
+type parsedEntry struct {
+	key        string
+	keys       []string // the key's elements; see parseConfKey
+	value      Value
+	filename   string
+	lineNumber int
+
+	// comments are the comment (and empty) lines preceding the entry, and
+	// comment the trailing comment (with its leading whitespace), if any;
+	// they're only set when parsing comments.
+	comments []string
+	comment  string
+}
+
+// parseConfKey splits a key read from a file into its elements, on dots not
+// escaped with a backslash; a backslash only escapes a dot or a backslash,
+// and is kept otherwise, so that keys written with joinPath are read back.
+// Empty elements are errors.
+func parseConfKey(key string) ([]string, error) {
+	keys := []string{}
+	var k strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '\\' && i+1 < len(key) && (key[i+1] == '.' || key[i+1] == '\\'):
+			i++
+			k.WriteByte(key[i])
+		case c == '.':
+			keys = append(keys, k.String())
+			k.Reset()
+		default:
+			k.WriteByte(c)
+		}
+	}
+	keys = append(keys, k.String())
+	for _, k := range keys {
+		if k == "" {
+			return nil, fmt.Errorf(`Bad key "%s": empty element`, key)
+		}
+	}
+	return keys, nil
+}
+
+// splitTrailingComment splits the raw value of an entry with the type into
+// the value and its trailing comment, if any; with the "quoted" type, the
+// comment is looked for after the closing quote.
+func splitTrailingComment(valueType, value string) (string, string) {
+	offset := 0
+	if valueType == "quoted" {
+		if quoted, err := strconv.QuotedPrefix(value); err == nil {
+			offset = len(quoted)
+		}
+	}
+	if index := reParseTrailingComment.FindStringIndex(value[offset:]); index != nil {
+		return value[:offset+index[0]], value[offset+index[0]:]
+	}
+	return value, ""
+}
+
+// internalParseFile parses the specified file, following includes, and calls
+// entry for each key/value found. If comments is true, comments are attached
+// to the entries, and a whitespace followed by "#" starts a trailing comment;
+// otherwise, it's part of the value. Comments after the last entry of the
+// file are returned. Errors returned by entry are reported with the filename
+// and line number.
+func internalParseFile(os tfileSystem, filename string, expand func(string) (string, error), comments bool, entry func(e parsedEntry) error) ([]string, error) {
 	numFiles := 0
+	depth := 0
+	var footer []string
 
 	// load initial file, handle includes
 	seenFiles := map[string]bool{}
@@ -316,47 +605,134 @@ func internalMergeFile(os tfileSystem, node *Node, filename string) error {
 
 		// parse the file, add entries to a queue
 		numFiles++
+		depth++
+		defer func() { depth-- }()
 		lineNumber := 0
+		var pending []string
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
 			lineNumber++
 			if line := scanner.Text(); reParseIgnore.MatchString(line) {
 				// comment/empty lines?
+				if comments {
+					pending = append(pending, line)
+				}
 			} else if matches := reParseInclude.FindStringSubmatch(line); matches != nil && len(matches) == 2 {
 				// include?
-				includeFilename := path.Join(path.Dir(filename), matches[1])
+				includeFilename, err := expand(matches[1])
+				if err != nil {
+					return fmt.Errorf(`%s:%d: expanding include "%s": %v`, filename, lineNumber, matches[1], err)
+				}
+				if !path.IsAbs(includeFilename) {
+					includeFilename = path.Join(path.Dir(filename), includeFilename)
+				}
 				if err := loadFile(includeFilename); err != nil {
 					return fmt.Errorf(`%s:%d: including "%s": %v`, filename, lineNumber, includeFilename, err)
 				}
 			} else if matches := reParseEntry.FindStringSubmatch(line); matches != nil && len(matches) == 4 {
 				// regular entry
-				value, err := parseValueType(matches[2], matches[3])
+				keys, err := parseConfKey(matches[1])
 				if err != nil {
+					return fmt.Errorf(`%s:%d: %v`, filename, lineNumber, err)
+				}
+				e := parsedEntry{key: matches[1], keys: keys, filename: filename, lineNumber: lineNumber}
+				rawValue := matches[3]
+				if comments {
+					rawValue, e.comment = splitTrailingComment(matches[2], rawValue)
+					e.comments, pending = pending, nil
+				}
+				if e.value, err = parseValueType(matches[2], rawValue); err != nil {
 					return err
 				}
 
-				node.SetKey(matches[1], value)
+				if err := entry(e); err != nil {
+					return fmt.Errorf(`%s:%d: %v`, filename, lineNumber, err)
+				}
 			} else {
 				// unknown/syntax error
 				return fmt.Errorf(`%s:%d: bad format: "%s"`, filename, lineNumber, line)
 			}
 		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf(`%s:%d: %v`, filename, lineNumber+1, err)
+		}
+		if depth == 1 {
+			// only keep the main file's footer
+			footer = pending
+		}
 		return nil
 	}
 	if err := loadFile(filename); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return footer, nil
+}
+
+// expandPath expands a leading "~" to the user's home directory, and $VAR or
+// ${VAR} references to the value of the environment variables, using the
+// specified functions. Use "$$" for a literal dollar sign. Unset variables
+// are errors.
+func expandPath(s string, lookupEnv func(string) (string, bool), homeDir func() (string, error)) (string, error) {
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		home, err := homeDir()
+		if err != nil {
+			return "", err
+		}
+		s = home + s[1:]
+	}
+
+	var missing []string
+	s = os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		value, found := lookupEnv(name)
+		if !found {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable not set: %s", strings.Join(missing, ", "))
+	}
+	return s, nil
+}
+
+// expandIncludePath expands include paths using the actual environment.
+func expandIncludePath(s string) (string, error) {
+	return expandPath(s, os.LookupEnv, os.UserHomeDir)
+}
+
+func internalMergeFile(os tfileSystem, node *Node, filename string) error {
+	tracker := node.GetRoot().rootState().comments
+	limit := newLimiter(node, false)
+	footer, err := internalParseFile(os, filename, expandIncludePath, tracker != nil, func(e parsedEntry) error {
+		n, err := internalSetFrom(node, e.keys, e.value, Origin{Kind: "file", Source: e.filename, Line: e.lineNumber}, limit)
+		if err != nil {
+			return err
+		}
+		tracker.set(n, e.comments, e.comment)
+		return nil
+	})
+	tracker.setFooter(node, footer)
+	return err
 }
 
 // MergeFile will load/parsethe specified filename, following these rules:
 // - lines started with "#" and lines containing only whitespace are ignored.
 // - lines with the format "include filename" will recursively parsethe
-//   specified filename; relative paths can be used.
-// - lines that have at least one "=" are split into a "key=value" pair.
-// - leading and trailing spaces are trimmed from keys and values.
+//   specified filename; relative paths can be used, as well as a leading "~"
+//   for the home directory and $VAR or ${VAR} for environment variables
+//   ("$$" is a literal dollar sign).
+// - lines that have at least one "=" are split into a "key=value" pair;
+//   within keys, a backslash escapes a dot or a backslash, so "a\.b" is
+//   a single key.
+// - leading and trailing spaces are trimmed from keys and values; values
+//   with the type "quoted", e.g. `motd:quoted=" hi "`, are read as Go
+//   quoted strings instead, keeping them.
 // - remaining lines are considered syntax errors.
+// Comments are ignored, unless the root is keeping them; see KeepComments.
 // All entries found are added under the current node. This operation is not
 // atomic, that is, if an error occurs in the middle of the process the
 // original node will be partially updated.
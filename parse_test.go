@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -76,6 +79,109 @@ func TestParseInt(t *testing.T) {
 	ck(math.Pi, 0, `strconv.ParseInt: parsing "3.141592653589793": invalid syntax`)
 }
 
+func TestParseSize(t *testing.T) {
+	ck := func(s string, expected int64, expectedError string) {
+		t.Helper()
+		actual, err := ParseSize(s)
+		testError(t, err, expectedError)
+		testDeepEqual(t, actual, expected)
+	}
+
+	ck("0", 0, "")
+	ck("512", 512, "")
+	ck(" 512 B ", 512, "")
+	ck("1KB", 1000, "")
+	ck("1kb", 1000, "")
+	ck("10 MB", 10e6, "")
+	ck("2GB", 2e9, "")
+	ck("3TB", 3e12, "")
+	ck("1KiB", 1024, "")
+	ck("1kib", 1024, "")
+	ck("4MiB", 4<<20, "")
+	ck("2GIB", 2<<30, "")
+	ck("1TiB", 1<<40, "")
+	ck("1.5GB", 1.5e9, "")
+	ck("1.5GiB", 3<<29, "")
+	ck("0.5KB", 500, "")
+	ck(".5", 1, "")
+	ck("1e3KB", 1e6, "")
+	ck("9223372036854775807", math.MaxInt64, "")
+
+	ck("", 0, `Bad size ""`)
+	ck("KB", 0, `Bad size "KB"`)
+	ck("x", 0, `Bad size "x": unknown unit "x"`)
+	ck("1PB", 0, `Bad size "1PB": unknown unit "PB"`)
+	ck("1 Kilobyte", 0, `Bad size "1 Kilobyte": unknown unit "Kilobyte"`)
+	ck("1.2.3MB", 0, `Bad size "1.2.3MB"`)
+	ck("NaN", 0, `Bad size "NaN": unknown unit "NaN"`)
+	ck("-1", 0, `Bad size "-1": out of range`)
+	ck("-1.5KB", 0, `Bad size "-1.5KB": out of range`)
+	ck("9223372036854775808", 0, `Bad size "9223372036854775808": out of range`)
+	ck("10000000TB", 0, `Bad size "10000000TB": out of range`)
+	ck("1e30", 0, `Bad size "1e30": out of range`)
+}
+
+func TestFormatDuration(t *testing.T) {
+	testDeepEqual(t, FormatDuration(0), "0s")
+	testDeepEqual(t, FormatDuration(49*time.Hour+20*time.Minute), "2d1h20m")
+	testDeepEqual(t, FormatDuration(time.Minute+5*time.Second), "1m5s")
+	testDeepEqual(t, FormatDuration(1500*time.Millisecond), "1.5s")
+	testDeepEqual(t, FormatDuration(-time.Hour), "-1h0m0s")
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		d := time.Duration(random.Int63n(int64(1000 * 24 * time.Hour))).Truncate(time.Second)
+		if i%10 == 0 {
+			d = d.Truncate(time.Hour)
+		}
+		parsed, err := parseDuration(FormatDuration(d))
+		testError(t, err, "")
+		if parsed != d {
+			t.Fatalf("%v was formatted as %s and parsed back as %v", d, FormatDuration(d), parsed)
+		}
+	}
+}
+
+func TestParseDuration_Overflow(t *testing.T) {
+	d, err := parseDuration("106751d23h47m16s")
+	testError(t, err, "")
+	testDeepEqual(t, d, time.Duration(math.MaxInt64).Truncate(time.Second))
+	for _, s := range []string{"106751d23h47m17s", "106752d", "99999999999999999999s", "9999999999:00"} {
+		_, err := parseDuration(s)
+		testError(t, err, "bad duration")
+	}
+}
+
+func FuzzParseDuration(f *testing.F) {
+	for _, s := range []string{"2d1h20m", "1 day 3 hours", "12:30", "12:30:15", "999999999999d", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := parseDuration(s)
+		if err != nil {
+			return
+		}
+		testTrue(t, d >= 0)
+		parsed, err := parseDuration(FormatDuration(d))
+		testError(t, err, "")
+		testDeepEqual(t, parsed, d)
+	})
+}
+
+func FuzzParseTime(f *testing.F) {
+	for _, s := range []string{"2020-01-02", "2020-01-02T03:04:05Z", "2020-01-02T03:04:05.999+02:00", "today"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		parsed, err := parseTime(s)
+		if err != nil {
+			return
+		}
+		testDeepEqual(t, parsed.Location(), time.UTC)
+		testDeepEqual(t, parsed, parsed.Truncate(time.Second))
+	})
+}
+
 // bunch of classes to mock the filesystem
 type tMockFS map[string]*bytes.Buffer
 type tMockFile struct{ r io.Reader }
@@ -190,6 +296,78 @@ func TestInternalMergeFile(t *testing.T) {
 	testEqualString(t, node, `{a=8,b={c=3,d=4}}`)
 }
 
+func TestMergeReader_Errors(t *testing.T) {
+	const data = "a=1\nretries:int=many\nbad syntax\nb.c..d=2\nb:float=2.5\nc:duration=soon\nd=4\n"
+
+	// strict mode stops at the first bad line
+	node := NewRoot()
+	err := node.MergeReader(strings.NewReader(data), true)
+	testError(t, err, `line 2: strconv.ParseInt: parsing "many": invalid syntax`)
+	var lineErr LineError
+	testTrue(t, errors.As(err, &lineErr))
+	testDeepEqual(t, lineErr.Line, 2)
+	testDeepEqual(t, lineErr.Text, "retries:int=many")
+	testEqualString(t, node, `{a=1}`)
+
+	// lenient mode skips them
+	node = NewRoot()
+	testError(t, node.MergeReader(strings.NewReader(data), false), "")
+	testEqualString(t, node, `{a=1,b=2.5,d=4}`)
+
+	node = NewRoot()
+	errs := node.MergeReaderLenient(strings.NewReader(data))
+	testEqualString(t, node, `{a=1,b=2.5,d=4}`)
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	testDeepEqual(t, messages, []string{
+		`line 2: strconv.ParseInt: parsing "many": invalid syntax`,
+		`line 3: bad format: "bad syntax"`,
+		`line 4: Bad key "b.c..d": empty element`,
+		`line 6: bad duration`,
+	})
+	testDeepEqual(t, errs[1].Text, "bad syntax")
+
+	testDeepEqual(t, NewRoot().MergeReaderLenient(strings.NewReader("a=1\n")), []LineError(nil))
+}
+
+func TestMergeReader_LongLine(t *testing.T) {
+	data := "a=1\nb=" + strings.Repeat("x", bufio.MaxScanTokenSize) + "\nc=3\n"
+	node := NewRoot()
+	errs := node.MergeReaderLenient(strings.NewReader(data))
+	testDeepEqual(t, len(errs), 1)
+	testError(t, errs[0], "line 2: bufio.Scanner: token too long")
+	testEqualString(t, node, "{a=1}")
+	testError(t, NewRoot().MergeReader(strings.NewReader(data), true), "line 2: bufio.Scanner: token too long")
+}
+
+func FuzzMergeReader(f *testing.F) {
+	f.Add("a=1\nb.c:int=2\n# comment\nd:[]duration=1h,2m\n")
+	f.Add("a:time=2020-01-02\nbad\n=x\n.a=1\na..b=2\n")
+	f.Add("a:[]string=x\\,y,z\\\\  # trailing\n")
+	f.Fuzz(func(t *testing.T, data string) {
+		lenient := NewRoot()
+		errs := lenient.MergeReaderLenient(strings.NewReader(data))
+		for _, e := range errs {
+			testTrue(t, e.Line > 0)
+		}
+
+		// strict mode fails at the first bad line, if any
+		err := NewRoot().MergeReader(strings.NewReader(data), true)
+		if len(errs) == 0 {
+			testError(t, err, "")
+		} else {
+			testError(t, err, errs[0].Error())
+		}
+
+		// every node can be found again by its path
+		for path, n := range lenient.All() {
+			testTrue(t, internalGetExact(lenient, path) == n)
+		}
+	})
+}
+
 func TestParseJSON(t *testing.T) {
 	data := []byte(`
 		{"a":1,"b":"lolcats","c":{"d":3.1415},"d":[1,2,3],"e":[1,"two",3.0,true]}
@@ -203,3 +381,93 @@ func TestParseJSON(t *testing.T) {
 	testDeepEqual(t, node.Get("c.d"), 3.1415)
 	testDeepEqual(t, node.Get("e.4"), true)
 }
+
+func TestParseJSON_Order(t *testing.T) {
+	data := `{"z":1,"b":{"y":2,"c":[{"x":1,"a":2},3]},"a":"last","m":{}}`
+	for i := 0; i < 10; i++ {
+		node := NewRoot()
+		testError(t, json.Unmarshal([]byte(data), node), "")
+		testDeepEqual(t, node.ChildKeys, []string{"z", "b", "a"})
+		testDeepEqual(t, node.GetNode("b").ChildKeys, []string{"y", "c"})
+		testDeepEqual(t, node.GetNode("b.c").ChildKeys, []string{"1", "2"})
+		testDeepEqual(t, node.GetNode("b.c.1").ChildKeys, []string{"x", "a"})
+
+		byt, err := json.Marshal(node)
+		testError(t, err, "")
+		testDeepEqual(t, string(byt), `{"z":1,"b":{"y":2,"c":[{"x":1,"a":2},3]},"a":"last"}`)
+	}
+
+	// existing children keep their position
+	node := NewRoot()
+	node.SetKey("a", "first")
+	testError(t, json.Unmarshal([]byte(`{"c":1,"a":2,"b":3}`), node), "")
+	testDeepEqual(t, node.ChildKeys, []string{"a", "c", "b"})
+}
+
+func TestParseJSON_Raw(t *testing.T) {
+	blob := `{"z":1,"a":[12345678901234567890,1.000000000000000000001],"z2":{"b":null,"a":"x"}}`
+	data := []byte(`{"id":7,"webhook":{"template":` + blob + `,"url":"http://example.com"}}`)
+
+	node := NewRoot()
+	node.AddNode("webhook.template").Flags |= RawJSON
+	testError(t, json.Unmarshal(data, node), "")
+	testDeepEqual(t, node.Get("webhook.template"), json.RawMessage(blob))
+	testDeepEqual(t, node.GetString("webhook.template"), blob)
+	testDeepEqual(t, node.Get("webhook.url"), "http://example.com")
+	testTrue(t, node.GetNode("webhook.template").IsLeaf())
+
+	// written back byte by byte
+	byt, err := json.Marshal(node.GetNode("webhook.template"))
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), blob)
+	node.SortRecursively()
+	byt, err = json.Marshal(node)
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), string(data))
+
+	// on the node itself
+	raw := NewNode("raw")
+	raw.Flags |= RawJSON
+	testError(t, json.Unmarshal([]byte(blob), raw), "")
+	testDeepEqual(t, raw.GetString(), blob)
+	byt, _ = raw.MarshalJSON()
+	testDeepEqual(t, string(byt), blob)
+
+	// invalid JSON
+	testError(t, json.Unmarshal([]byte(`{"webhook":{"template":{"a":}}}`), NewRoot()), "invalid character '}' looking for beginning of value")
+}
+
+func TestParseJSON_UseNumber(t *testing.T) {
+	data := []byte(`{"id":12345678901234567,"big":99999999999999999999,"ratio":0.25,"list":[9007199254740993],"name":"x"}`)
+
+	// by default, numbers are float64, and big IDs lose precision
+	lossy := NewRoot()
+	testError(t, json.Unmarshal(data, lossy), "")
+	testDeepEqual(t, lossy.Get("id"), float64(12345678901234568))
+	_, err := GetAs[int64](lossy, "id")
+	testError(t, err, `key "id": strconv.ParseInt: parsing "1.2345678901234568e+16": invalid syntax`)
+
+	root := NewRoot()
+	root.Flags |= UseNumber
+	testError(t, json.Unmarshal(data, root), "")
+	testDeepEqual(t, root.Get("id"), json.Number("12345678901234567"))
+	testDeepEqual(t, root.Get("name"), "x")
+	testDeepEqual(t, root.GetInt("id"), 12345678901234567)
+	testDeepEqual(t, MustGetAs[int64](root, "id"), int64(12345678901234567))
+	testDeepEqual(t, MustGetAs[int64](root, "list.1"), int64(9007199254740993))
+	testDeepEqual(t, root.GetFloat("ratio"), 0.25)
+	testDeepEqual(t, root.GetFloat("id"), 12345678901234567.0)
+
+	// integers that don't fit, or aren't integers, fail
+	_, err = root.TryGetInt("big")
+	testError(t, err, `key "big": strconv.ParseInt: parsing "99999999999999999999": value out of range`)
+	_, err = GetAs[int64](root, "big")
+	testError(t, err, `key "big": strconv.ParseInt: parsing "99999999999999999999": value out of range`)
+	_, err = root.TryGetInt("ratio")
+	testError(t, err, `key "ratio": strconv.ParseInt: parsing "0.25": invalid syntax`)
+
+	// and they're written back as is
+	byt, err := json.Marshal(root)
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), string(data))
+}
@@ -0,0 +1,68 @@
+package trix
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ReloadOnSignal reloads the file every time the process receives one of the
+// signals, or SIGHUP if none is specified: the file is loaded into a new
+// version of the tree which, if successful, replaces the current one, so that
+// other goroutines can keep reading it; see Current. Load errors are sent to
+// the returned channel, keeping the current version; the channel should be
+// read to keep reloading. Calling stop stops handling the signals and closes
+// the channel.
+func (current *Current) ReloadOnSignal(filename string, sig ...os.Signal) (stop func(), errs <-chan error) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig...)
+	return internalReloadOn(current, signals, func() { signal.Stop(signals) }, func() (*Node, error) {
+		return LoadWith(LoadOptions{Filename: filename, Into: current.next()})
+	})
+}
+
+// internalReloadOn replaces the current version with the tree returned by
+// load every time something is received from signals. The returned stop
+// function calls release, so that no more signals are sent.
+func internalReloadOn(current *Current, signals <-chan os.Signal, release func(), load func() (*Node, error)) (func(), <-chan error) {
+	errs := make(chan error)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		defer close(errs)
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+			}
+
+			loaded, err := load()
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-done:
+					return
+				}
+				continue
+			}
+			current.replace(loaded)
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			release()
+			close(done)
+			<-finished
+		})
+	}
+	return stop, errs
+}
@@ -0,0 +1,104 @@
+package trix
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "localhost")
+	current := NewCurrent(root)
+
+	signals := make(chan os.Signal)
+	released := false
+	loads := make(chan func() (*Node, error))
+	stop, errs := internalReloadOn(current, signals, func() { released = true }, func() (*Node, error) {
+		return (<-loads)()
+	})
+	reload := func(load func() (*Node, error)) {
+		signals <- syscall.SIGHUP
+		loads <- load
+	}
+	fail := func() (*Node, error) { return nil, errors.New("line 1: bad format") }
+
+	// a failed reload keeps the tree
+	reload(fail)
+	testError(t, <-errs, "line 1: bad format")
+	testTrue(t, current.Load() == root)
+	testEqualString(t, root, "{db={host=localhost}}")
+
+	// a successful one replaces it, leaving the previous version untouched,
+	// but keeping its state
+	var missed []string
+	root.OnMiss(func(path string) { missed = append(missed, path) })
+	reload(func() (*Node, error) {
+		node := current.next()
+		node.SetKey("db.host", "db.internal")
+		node.SetKey("db.port", 5432)
+		return node, nil
+	})
+	reload(fail) // wait for the previous reload to be applied
+	testError(t, <-errs, "line 1: bad format")
+	testEqualString(t, current.Load(), "{db={host=db.internal,port=5432}}")
+	testEqualString(t, root, "{db={host=localhost}}")
+	current.Load().GetString("db.user")
+	testDeepEqual(t, missed, []string{"db.user"})
+
+	stop()
+	testTrue(t, released)
+	_, open := <-errs
+	testTrue(t, !open)
+	stop()
+
+	// real signals
+	stop, errs = current.ReloadOnSignal("testdata/missing.conf")
+	stop()
+	_, open = <-errs
+	testTrue(t, !open)
+}
+
+func TestReloadOnSignal_ConcurrentReads(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.port", 1)
+	current := NewCurrent(root)
+	signals := make(chan os.Signal)
+	port := 1
+	stop, errs := internalReloadOn(current, signals, func() {}, func() (*Node, error) {
+		port++
+		node := current.next()
+		node.SetKey("db.port", port)
+		return node, nil
+	})
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				conf := current.Load().With(Args{"request.id": 1})
+				testTrue(t, conf.GetInt("db.port") > 0)
+				testTrue(t, len(conf.GetNodes("db.*")) == 1)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		signals <- syscall.SIGHUP
+	}
+	stop()
+	close(done)
+	wg.Wait()
+	_, open := <-errs
+	testTrue(t, !open)
+	testTrue(t, current.Load().GetInt("db.port") >= 100)
+}
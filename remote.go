@@ -0,0 +1,154 @@
+package trix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteSource periodically fetches a configuration document over HTTP, and
+// makes its entries the current version of a tree when it changes; see
+// Current.
+// Documents are parsed as JSON if their content type says so, and in the
+// format read by MergeReader otherwise. See NewRemoteSource.
+type RemoteSource struct {
+	// URL is the document's URL.
+	URL string
+
+	// Interval is the time between fetches.
+	Interval time.Duration
+
+	// Client is used to make requests.
+	Client *http.Client
+
+	// OnChange, if not nil, is called with the changes from the previous
+	// version of the tree every time a new version of the document is applied.
+	OnChange func(changes []Change)
+
+	// OnError, if not nil, is called with the errors fetching or parsing the
+	// document; the last version applied is kept.
+	OnError func(err error)
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	cancel       context.CancelFunc
+	done         chan struct{}
+}
+
+// NewRemoteSource returns a source that fetches url every interval, using
+// client, or http.DefaultClient if it's nil. Set OnChange and OnError before
+// calling Start.
+func NewRemoteSource(url string, interval time.Duration, client *http.Client) *RemoteSource {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteSource{URL: url, Interval: interval, Client: client}
+}
+
+// Start fetches the document into target immediately, and then every
+// interval, until ctx is done or Stop is called. Starting a source that's
+// already running restarts it.
+func (source *RemoteSource) Start(ctx context.Context, target *Current) {
+	source.Stop()
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	ctx, source.cancel = context.WithCancel(ctx)
+	done := make(chan struct{})
+	source.done = done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(source.Interval)
+		defer ticker.Stop()
+		for {
+			changes, err := source.Fetch(ctx, target)
+			if err != nil && ctx.Err() == nil && source.OnError != nil {
+				source.OnError(err)
+			} else if len(changes) > 0 && source.OnChange != nil {
+				source.OnChange(changes)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops fetching, and waits for the current fetch, if any, to finish.
+func (source *RemoteSource) Stop() {
+	source.mu.Lock()
+	cancel, done := source.cancel, source.done
+	source.cancel, source.done = nil, nil
+	source.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Fetch fetches the document once, and, if it changed, makes its entries the
+// target's current version. It sends the ETag and Last-Modified values of the
+// last response, so that unchanged documents aren't sent again. Return the
+// changes from the previous version, as returned by Diff; if the document
+// didn't change, or there's an error, the current version is kept.
+func (source *RemoteSource) Fetch(ctx context.Context, target *Current) ([]Change, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	source.mu.Lock()
+	etag, lastModified := source.etag, source.lastModified
+	source.mu.Unlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := source.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching %s: unexpected status: %s", source.URL, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := target.next()
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		err = parsed.UnmarshalJSON(body)
+	} else {
+		err = parsed.MergeReader(bytes.NewReader(body), true)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Parsing %s: %v", source.URL, err)
+	}
+
+	changes := target.Load().Diff(parsed)
+	if len(changes) > 0 {
+		target.replace(parsed)
+	}
+	source.mu.Lock()
+	source.etag = resp.Header.Get("ETag")
+	source.lastModified = resp.Header.Get("Last-Modified")
+	source.mu.Unlock()
+	return changes, nil
+}
@@ -0,0 +1,152 @@
+package trix
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteSource(t *testing.T) {
+	var mu sync.Mutex
+	status, etag, body := http.StatusOK, `"v1"`, "db.host=localhost\ndb.port:int=5432\n"
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Header.Get("If-None-Match"))
+		if status == http.StatusOK && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if strings.HasPrefix(body, "{") {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	describe := func(changes []Change) []string {
+		result := []string{}
+		for _, change := range changes {
+			result = append(result, fmt.Sprintf("%v %s", change.Kind, joinPath(change.Path)))
+		}
+		return result
+	}
+	set := func(s int, e, b string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, etag, body = s, e, b
+	}
+
+	root := NewRoot()
+	root.SetKey("old", "removed")
+	root.Alias("database", "db")
+	target := NewCurrent(root)
+	source := NewRemoteSource(server.URL, time.Hour, nil)
+	ctx := context.Background()
+
+	// 200; the previous version is untouched, and its state is kept
+	changes, err := source.Fetch(ctx, target)
+	testError(t, err, "")
+	testDeepEqual(t, describe(changes), []string{"removed old", "added db"})
+	testEqualString(t, target.Load(), "{db={host=localhost,port=5432}}")
+	testEqualString(t, root, "{old=removed}")
+	testDeepEqual(t, target.Load().GetString("database.host"), "localhost")
+
+	// 304
+	changes, err = source.Fetch(ctx, target)
+	testError(t, err, "")
+	testTrue(t, changes == nil)
+	testDeepEqual(t, requests, []string{"", `"v1"`})
+
+	// same document, new etag
+	set(http.StatusOK, `"v2"`, "db.host=localhost\ndb.port:int=5432\n")
+	changes, err = source.Fetch(ctx, target)
+	testError(t, err, "")
+	testDeepEqual(t, changes, []Change{})
+
+	// 500, and parse errors, keep the last version
+	set(http.StatusInternalServerError, `"v3"`, "oops")
+	_, err = source.Fetch(ctx, target)
+	testError(t, err, "Fetching "+server.URL+": unexpected status: 500 Internal Server Error")
+	set(http.StatusOK, `"v4"`, "db.host=db.internal\nbad line\n")
+	_, err = source.Fetch(ctx, target)
+	testError(t, err, "Parsing "+server.URL+`: line 2: bad format: "bad line"`)
+	testEqualString(t, target.Load(), "{db={host=localhost,port=5432}}")
+
+	// JSON
+	set(http.StatusOK, `"v5"`, `{"db":{"port":5432}}`)
+	changes, err = source.Fetch(ctx, target)
+	testError(t, err, "")
+	testDeepEqual(t, describe(changes), []string{"removed db.host", "changed db.port"})
+	testDeepEqual(t, target.Load().Get("db.port"), 5432.0)
+
+	// periodic refresh
+	set(http.StatusOK, `"v6"`, `{"db":{"port":5433}}`)
+	source = NewRemoteSource(server.URL, 10*time.Millisecond, server.Client())
+	updates := make(chan []string, 10)
+	send := func(update []string) {
+		select {
+		case updates <- update:
+		default:
+		}
+	}
+	source.OnChange = func(changes []Change) { send(describe(changes)) }
+	source.OnError = func(err error) { send([]string{err.Error()}) }
+	source.Start(ctx, target)
+	testDeepEqual(t, <-updates, []string{"changed db.port"})
+	set(http.StatusInternalServerError, `"v7"`, "")
+	testDeepEqual(t, <-updates, []string{"Fetching " + server.URL + ": unexpected status: 500 Internal Server Error"})
+	source.Stop()
+	testDeepEqual(t, target.Load().Get("db.port"), 5433.0)
+
+	// stopping with the context
+	set(http.StatusOK, `"v8"`, `{"db":{"port":1}}`)
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	source.Start(ctx, target)
+	source.Stop()
+}
+
+func TestRemoteSource_ConcurrentReads(t *testing.T) {
+	var version atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"db":{"port":%d,"hosts":["a","b"]}}`, version.Add(1))
+	}))
+	defer server.Close()
+
+	target := NewCurrent(nil)
+	source := NewRemoteSource(server.URL, time.Millisecond, server.Client())
+	changed := make(chan struct{}, 1)
+	source.OnChange = func([]Change) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	source.Start(context.Background(), target)
+	<-changed
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				conf := target.Load().With(Args{"request.id": j})
+				testTrue(t, conf.GetInt("db.port") > 0)
+				testDeepEqual(t, conf.GetStringSlice("db.hosts"), []string{"a", "b"})
+			}
+		}()
+	}
+	wg.Wait()
+	source.Stop()
+}
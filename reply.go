@@ -1,29 +1,42 @@
 package trix
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Reply represents a map with multiple values for each key
 type Reply map[string][]string
 
 // Set the specifued value(s) for the key
-func (reply *Reply) Set(key string, value ...string) {
-	(*reply)[key] = value
+func (reply Reply) Set(key string, value ...string) {
+	reply[key] = value
 }
 
 // Add the specifued value(s) to the key
-func (reply *Reply) Add(key string, value ...string) {
-	(*reply)[key] = append((*reply)[key], value...)
+func (reply Reply) Add(key string, value ...string) {
+	reply[key] = append(reply[key], value...)
 }
 
-// Errors returns a list of all key/values that look like an error
-func (reply *Reply) Errors() Reply {
+// isErrorValue returns whether the value looks like an error.
+func isErrorValue(value string) bool {
+	return strings.HasPrefix(value, "ERROR_")
+}
+
+// Errors returns a list of all key/values that look like an error; the values
+// for each key are kept in their original order.
+func (reply Reply) Errors() Reply {
 	errors := Reply{}
-	for key, values := range *reply {
+	for key, values := range reply {
 		for _, value := range values {
-			if value[:6] == "ERROR_" {
+			if isErrorValue(value) {
 				errors[key] = append(errors[key], value)
 			}
 		}
@@ -32,22 +45,38 @@ func (reply *Reply) Errors() Reply {
 }
 
 // ErrorReason returns a simple string with the reason a transaction failed.
-func (reply *Reply) ErrorReason() string {
-	if (*reply)["status"][0] == "TRANS_OK" {
+// If the status is "TRANS_OK", an empty string is returned; otherwise the
+// first value that looks like an error (from the first key, in alphabetical
+// order) is returned. If there are none,
+// "TRANS_ERROR" is returned, unless the reply has no status at all, in which
+// case the transaction isn't considered failed, and an empty string is
+// returned.
+func (reply Reply) ErrorReason() string {
+	if reply.OK() {
 		// no error
 		return ""
 	}
 
-	for _, values := range reply.Errors() {
-		for _, value := range values {
-			if value[:6] == "ERROR_" {
-				return value
-			}
-		}
+	errors := reply.Errors()
+	if keys := errors.Keys(); len(keys) > 0 {
+		return errors[keys[0]][0]
+	}
+	if reply.Status() == "" {
+		return ""
 	}
 	return "TRANS_ERROR"
 }
 
+// Status returns the reply's status, or an empty string if it has none.
+func (reply Reply) Status() string {
+	return reply.Get("status")
+}
+
+// OK returns whether the reply's status is "TRANS_OK".
+func (reply Reply) OK() bool {
+	return reply.Status() == "TRANS_OK"
+}
+
 // Get returns the first value of a the reply's key
 func (reply Reply) Get(key string) string {
 	if values := reply[key]; len(values) > 0 {
@@ -56,19 +85,304 @@ func (reply Reply) Get(key string) string {
 	return ""
 }
 
+// TryGet returns the first value of a the reply's key; if the key has no
+// values, an error is returned.
+func (reply Reply) TryGet(key string) (string, error) {
+	if values := reply[key]; len(values) > 0 {
+		return values[0], nil
+	}
+	return "", errorKeyNotFound
+}
+
+// TryGetInt returns the first value of a the reply's key, as an int; if the
+// key has no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetInt(key string) (int, error) {
+	s, err := reply.TryGet(key)
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(s)
+}
+
+// TryGetFloat returns the first value of a the reply's key, as a float64; if
+// the key has no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetFloat(key string) (float64, error) {
+	s, err := reply.TryGet(key)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// TryGetBool returns the first value of a the reply's key, as a bool; if the
+// key has no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetBool(key string) (bool, error) {
+	s, err := reply.TryGet(key)
+	if err != nil {
+		return false, err
+	}
+	return parseBool(s)
+}
+
+// TryGetDuration returns the first value of a the reply's key, as a duration;
+// if the key has no values or there's a conversion error, an error is
+// returned.
+func (reply Reply) TryGetDuration(key string) (time.Duration, error) {
+	s, err := reply.TryGet(key)
+	if err != nil {
+		return 0, err
+	}
+	return parseDuration(s)
+}
+
 // GetInt returns the first value of a the reply's key, as an int
 func (reply Reply) GetInt(key string) int {
-	if i, err := strconv.Atoi(reply.Get(key)); err == nil {
-		return i
-	}
-	return 0
+	i, _ := reply.TryGetInt(key)
+	return i
+}
+
+// GetFloat returns the first value of a the reply's key, as a float64
+func (reply Reply) GetFloat(key string) float64 {
+	f, _ := reply.TryGetFloat(key)
+	return f
 }
 
 // GetBool returns the first value of a the reply's key, as a bool
 func (reply Reply) GetBool(key string) bool {
-	switch strings.ToLower(reply.Get(key)) {
-	case "1", "t", "true", "on":
-		return true
+	b, _ := reply.TryGetBool(key)
+	return b
+}
+
+// GetDuration returns the first value of a the reply's key, as a duration
+func (reply Reply) GetDuration(key string) time.Duration {
+	d, _ := reply.TryGetDuration(key)
+	return d
+}
+
+// tryEach calls conv for each value of the reply's key, stopping at the first
+// error, which is returned with the index and the value that caused it.
+// If the key has no values, an error is returned.
+func (reply Reply) tryEach(key string, conv func(index int, value string) error) error {
+	values := reply[key]
+	if len(values) == 0 {
+		return errorKeyNotFound
+	}
+	for index, value := range values {
+		if err := conv(index, value); err != nil {
+			return fmt.Errorf("value %d (%q): %w", index, value, err)
+		}
+	}
+	return nil
+}
+
+// TryGetInts returns all values of the reply's key, as ints; if the key has
+// no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetInts(key string) ([]int, error) {
+	result := make([]int, len(reply[key]))
+	err := reply.tryEach(key, func(index int, value string) (err error) {
+		result[index], err = parseInt(value)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TryGetFloats returns all values of the reply's key, as float64s; if the key
+// has no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetFloats(key string) ([]float64, error) {
+	result := make([]float64, len(reply[key]))
+	err := reply.tryEach(key, func(index int, value string) (err error) {
+		result[index], err = strconv.ParseFloat(value, 64)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TryGetBools returns all values of the reply's key, as bools; if the key has
+// no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetBools(key string) ([]bool, error) {
+	result := make([]bool, len(reply[key]))
+	err := reply.tryEach(key, func(index int, value string) (err error) {
+		result[index], err = parseBool(value)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TryGetDurations returns all values of the reply's key, as durations; if the
+// key has no values or there's a conversion error, an error is returned.
+func (reply Reply) TryGetDurations(key string) ([]time.Duration, error) {
+	result := make([]time.Duration, len(reply[key]))
+	err := reply.tryEach(key, func(index int, value string) (err error) {
+		result[index], err = parseDuration(value)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetInts returns all values of the reply's key, as ints, or nil if any of
+// them can't be converted.
+func (reply Reply) GetInts(key string) []int {
+	result, _ := reply.TryGetInts(key)
+	return result
+}
+
+// GetFloats returns all values of the reply's key, as float64s, or nil if any
+// of them can't be converted.
+func (reply Reply) GetFloats(key string) []float64 {
+	result, _ := reply.TryGetFloats(key)
+	return result
+}
+
+// GetBools returns all values of the reply's key, as bools, or nil if any of
+// them can't be converted.
+func (reply Reply) GetBools(key string) []bool {
+	result, _ := reply.TryGetBools(key)
+	return result
+}
+
+// GetDurations returns all values of the reply's key, as durations, or nil if
+// any of them can't be converted.
+func (reply Reply) GetDurations(key string) []time.Duration {
+	result, _ := reply.TryGetDurations(key)
+	return result
+}
+
+// GetAll returns a copy of all values of the reply's key
+func (reply Reply) GetAll(key string) []string {
+	values := reply[key]
+	if values == nil {
+		return nil
+	}
+	return append([]string{}, values...)
+}
+
+// Has returns whether the reply has the key, even if it has no values
+func (reply Reply) Has(key string) bool {
+	_, found := reply[key]
+	return found
+}
+
+// Merge the reply with another, appending the other's values after the
+// existing ones for each key. Return the original reply.
+func (reply Reply) Merge(other Reply) Reply {
+	for key, values := range other {
+		reply[key] = append(reply[key], values...)
+	}
+	return reply
+}
+
+// MergeUnique the reply with another, like Merge, but skipping values that
+// the key already has. Return the original reply.
+func (reply Reply) MergeUnique(other Reply) Reply {
+	for key, values := range other {
+		existing := reply[key]
+		if existing == nil {
+			existing = []string{}
+		}
+		for _, value := range values {
+			found := false
+			for _, e := range existing {
+				if e == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				existing = append(existing, value)
+			}
+		}
+		reply[key] = existing
+	}
+	return reply
+}
+
+// Clone returns a deep copy of the reply.
+func (reply Reply) Clone() Reply {
+	n := Reply{}
+	for key, values := range reply {
+		n[key] = append([]string{}, values...)
+	}
+	return n
+}
+
+// Keys returns the reply's keys, sorted.
+func (reply Reply) Keys() []string {
+	keys := make([]string, 0, len(reply))
+	for key := range reply {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReplyFromValues returns a new reply with a copy of the values.
+func ReplyFromValues(v url.Values) Reply {
+	return Reply(v).Clone()
+}
+
+// Values returns a copy of the reply, as url.Values.
+func (reply Reply) Values() url.Values {
+	return url.Values(reply.Clone())
+}
+
+// Encode encodes the reply in URL-encoded form, sorted by key.
+func (reply Reply) Encode() string {
+	return url.Values(reply).Encode()
+}
+
+// replyLineEscaper escapes values so that each one fits in a single line.
+var replyLineEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// WriteTo writes the reply as "key: value" lines, sorted by key; values of
+// the same key are kept in their original order. Backslashes and line breaks
+// in values are escaped, so each value takes a single line. If w is an
+// http.ResponseWriter without a Content-Type, a plain text one is set.
+func (reply Reply) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.Buffer{}
+	for _, key := range reply.Keys() {
+		for _, value := range reply[key] {
+			buf.WriteString(key)
+			buf.WriteString(": ")
+			replyLineEscaper.WriteString(&buf, value)
+			buf.WriteByte('\n')
+		}
+	}
+
+	if rw, ok := w.(http.ResponseWriter); ok && rw.Header().Get("Content-Type") == "" {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	return buf.WriteTo(w)
+}
+
+// ToNode returns a new node with the specified key, where each of the reply's
+// keys is a child node. Keys with a single value store it directly as the
+// child's value, while keys with multiple values have them stored in
+// numbered children (1, 2, ...), like Push does. Keys without values produce
+// empty children.
+func (reply Reply) ToNode(key string) *Node {
+	node := NewNode(key)
+	for _, replyKey := range reply.Keys() {
+		child := NewNode(replyKey)
+		if values := reply[replyKey]; len(values) == 1 {
+			child.Value = values[0]
+		} else {
+			for _, value := range values {
+				child.Push().Value = value
+			}
+		}
+		node.Adopt(child)
 	}
-	return false
+	return node
 }
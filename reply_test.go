@@ -0,0 +1,168 @@
+package trix
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestReply_Getters(t *testing.T) {
+	reply := Reply{}
+	reply.Set("int", "17", "18")
+	reply.Set("float", "3.14")
+	reply.Set("bool", "on")
+	reply.Set("duration", "1h30m")
+	reply.Set("bad", "many")
+	reply.Set("empty")
+	reply.Add("empty")
+
+	testDeepEqual(t, reply.Get("int"), "17")
+	testDeepEqual(t, reply.GetInt("int"), 17)
+	testDeepEqual(t, reply.GetFloat("float"), 3.14)
+	testDeepEqual(t, reply.GetBool("bool"), true)
+	testDeepEqual(t, reply.GetDuration("duration"), time.Hour+time.Minute*30)
+	testDeepEqual(t, reply.GetInt("bad"), 0)
+	testDeepEqual(t, reply.GetDuration("missing"), time.Duration(0))
+
+	_, err := reply.TryGetInt("bad")
+	testError(t, err, `strconv.ParseInt: parsing "many": invalid syntax`)
+	_, err = reply.TryGetFloat("bad")
+	testError(t, err, `strconv.ParseFloat: parsing "many": invalid syntax`)
+	_, err = reply.TryGetBool("bad")
+	testError(t, err, `bad value`)
+	_, err = reply.TryGetDuration("bad")
+	testError(t, err, `bad duration`)
+	_, err = reply.TryGetDuration("empty")
+	testError(t, err, `key not found`)
+	_, err = reply.TryGet("missing")
+	testError(t, err, `key not found`)
+
+	// absent vs empty
+	testTrue(t, reply.Has("empty"))
+	testTrue(t, !reply.Has("missing"))
+
+	// GetAll returns a copy
+	all := reply.GetAll("int")
+	testDeepEqual(t, all, []string{"17", "18"})
+	all[0] = "changed"
+	testDeepEqual(t, reply.Get("int"), "17")
+	testTrue(t, reply.GetAll("missing") == nil)
+
+	// pointers work too
+	p := &reply
+	p.Add("int", "19")
+	testDeepEqual(t, p.GetAll("int"), []string{"17", "18", "19"})
+}
+
+func TestReply_Status(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("settings.tx.1.keys.1", "result")
+	root.SetKey("settings.tx.1.ok.value", "status:TRANS_OK")
+	root.SetKey("settings.tx.1.failed.value", "status:TRANS_FAILED")
+	root.SetKey("settings.tx.1.invalid.value", "status:TRANS_FAILED,email:ERROR_EMAIL_INVALID")
+	root.SetKey("settings.tx.1.nostatus.value", "email:ERROR_EMAIL_MISSING")
+	root.SetKey("settings.tx.2.default", "label:Transaction")
+
+	ck := func(result string, status string, ok bool, reason string) {
+		t.Helper()
+		reply := root.With(Args{"result": result}).GetSettings("settings.tx")
+		testDeepEqual(t, reply.Status(), status)
+		testDeepEqual(t, reply.OK(), ok)
+		testDeepEqual(t, reply.ErrorReason(), reason)
+	}
+
+	ck("ok", "TRANS_OK", true, "")
+	ck("failed", "TRANS_FAILED", false, "TRANS_ERROR")
+	ck("invalid", "TRANS_FAILED", false, "ERROR_EMAIL_INVALID")
+	ck("nostatus", "", false, "ERROR_EMAIL_MISSING") // errors are consulted without status
+	ck("unknown", "", false, "")                     // only the default label
+
+	for _, reply := range []Reply{{}, nil, {"status": {}}} {
+		testDeepEqual(t, reply.Status(), "")
+		testDeepEqual(t, reply.OK(), false)
+		testDeepEqual(t, reply.ErrorReason(), "")
+	}
+}
+
+func TestReply_Errors(t *testing.T) {
+	reply := Reply{
+		"short":  {"ok", "", "ERROR", "ERROR_"},
+		"long":   {"ERROR_B", "fine", "ERROR_A", "ERROR_C"},
+		"status": {"TRANS_FAILED"},
+		"zzz":    {"ERROR_Z"},
+		"empty":  {},
+		"blank":  {""},
+	}
+	for i := 0; i < 10; i++ {
+		testDeepEqual(t, reply.Errors(), Reply{
+			"short": {"ERROR_"},
+			"long":  {"ERROR_B", "ERROR_A", "ERROR_C"},
+			"zzz":   {"ERROR_Z"},
+		})
+		testDeepEqual(t, reply.ErrorReason(), "ERROR_B")
+	}
+
+	reply = Reply{"status": {"ok"}, "value": {"", "a", "abcdef"}}
+	testDeepEqual(t, reply.Errors(), Reply{})
+	testDeepEqual(t, reply.ErrorReason(), "TRANS_ERROR")
+	testDeepEqual(t, Reply{"status": {""}}.ErrorReason(), "")
+	testDeepEqual(t, Reply{"status": {"ERROR_"}}.ErrorReason(), "ERROR_")
+	testDeepEqual(t, Reply(nil).Errors(), Reply{})
+}
+
+func TestReply_Values(t *testing.T) {
+	reply := Reply{"b": {"2", "two"}, "a": {"1 & 1"}, "empty": {}}
+
+	values := reply.Values()
+	testDeepEqual(t, values, url.Values{"b": {"2", "two"}, "a": {"1 & 1"}, "empty": {}})
+	values["b"][0] = "changed"
+	testDeepEqual(t, reply.Get("b"), "2") // a copy
+
+	back := ReplyFromValues(reply.Values())
+	testDeepEqual(t, back, reply)
+	back["a"][0] = "changed"
+	testDeepEqual(t, reply.Get("a"), "1 & 1")
+
+	testDeepEqual(t, reply.Encode(), "a=1+%26+1&b=2&b=two")
+	parsed, err := url.ParseQuery(reply.Encode())
+	testError(t, err, "")
+	testDeepEqual(t, ReplyFromValues(parsed), Reply{"b": {"2", "two"}, "a": {"1 & 1"}})
+}
+
+func TestReply_WriteTo(t *testing.T) {
+	reply := Reply{
+		"b":    {"2", "multi\nline\r\n"},
+		"a":    {`back\slash`},
+		"none": {},
+	}
+	buf := bytes.Buffer{}
+	n, err := reply.WriteTo(&buf)
+	testError(t, err, "")
+	expected := "a: back\\\\slash\nb: 2\nb: multi\\nline\\r\\n\n"
+	testDeepEqual(t, buf.String(), expected)
+	testDeepEqual(t, n, int64(len(expected)))
+}
+
+func TestReply_Merge(t *testing.T) {
+	global := Reply{"max": {"8"}, "tags": {"a", "b"}}
+	tenant := Reply{"tags": {"b", "c", "c"}, "extra": {"4"}}
+
+	clone := global.Clone()
+	merged := global.Merge(tenant)
+	testDeepEqual(t, merged, Reply{"max": {"8"}, "tags": {"a", "b", "b", "c", "c"}, "extra": {"4"}})
+	testDeepEqual(t, global, merged) // changed in place
+	testDeepEqual(t, clone, Reply{"max": {"8"}, "tags": {"a", "b"}})
+	testDeepEqual(t, tenant, Reply{"tags": {"b", "c", "c"}, "extra": {"4"}})
+
+	// the clone is a deep copy
+	clone["tags"][0] = "z"
+	testDeepEqual(t, global.Get("tags"), "a")
+
+	unique := Reply{"max": {"8"}, "tags": {"a", "b"}}.MergeUnique(tenant)
+	testDeepEqual(t, unique, Reply{"max": {"8"}, "tags": {"a", "b", "c"}, "extra": {"4"}})
+	testDeepEqual(t, Reply{}.MergeUnique(Reply{"x": {}}), Reply{"x": {}})
+
+	testDeepEqual(t, merged.Keys(), []string{"extra", "max", "tags"})
+	testDeepEqual(t, Reply{}.Keys(), []string{})
+}
@@ -0,0 +1,65 @@
+package trix
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestArgsOptions changes how ArgsFromRequestWith builds arguments.
+type RequestArgsOptions struct {
+	// Headers lists the headers to include; their names are converted to
+	// lowercase dotted keys, e.g. "X-Device-Type" becomes "x.device.type".
+	Headers []string
+
+	// Prefix, if not empty, is added as the first element of every key,
+	// e.g. "req" turns "category" into "req.category".
+	Prefix string
+
+	// MultiValues keeps all values of parameters that have more than one,
+	// as a []string, instead of only the first one.
+	MultiValues bool
+}
+
+// ArgsFromRequest returns the request's URL query parameters and form values,
+// using the first value of each parameter. These are suitable for creating
+// a per-request scope using With.
+func ArgsFromRequest(r *http.Request) Args {
+	return ArgsFromRequestWith(r, RequestArgsOptions{})
+}
+
+// ArgsFromRequestWith is like ArgsFromRequest, but accepts options to also
+// include headers, prefix keys and keep multiple values.
+func ArgsFromRequestWith(r *http.Request, opts RequestArgsOptions) Args {
+	key := func(k string) string {
+		if opts.Prefix == "" {
+			return k
+		}
+		return opts.Prefix + "." + k
+	}
+
+	args := Args{}
+	add := func(k string, values []string) {
+		if len(values) == 0 {
+			return
+		} else if opts.MultiValues && len(values) > 1 {
+			args[key(k)] = append([]string{}, values...)
+		} else {
+			args[key(k)] = values[0]
+		}
+	}
+
+	values := r.URL.Query()
+	if err := r.ParseForm(); err == nil {
+		// includes both the body and the URL query parameters
+		values = r.Form
+	}
+	for k, v := range values {
+		add(k, v)
+	}
+
+	for _, name := range opts.Headers {
+		k := strings.ToLower(strings.Replace(name, "-", ".", -1))
+		add(k, r.Header.Values(name))
+	}
+	return args
+}
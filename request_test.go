@@ -0,0 +1,55 @@
+package trix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestArgsFromRequest(t *testing.T) {
+	form := url.Values{"type": {"s"}, "tag": {"a", "b"}}
+	r := httptest.NewRequest("POST", "/ad?category=3041&tag=c", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Device-Type", "mobile")
+
+	testDeepEqual(t, ArgsFromRequest(r), Args{"category": "3041", "type": "s", "tag": "a"})
+	testDeepEqual(t, ArgsFromRequestWith(r, RequestArgsOptions{
+		Headers:     []string{"X-Device-Type", "X-Missing"},
+		Prefix:      "req",
+		MultiValues: true,
+	}), Args{
+		"req.category":      "3041",
+		"req.type":          "s",
+		"req.tag":           []string{"a", "b", "c"},
+		"req.x.device.type": "mobile",
+	})
+}
+
+func TestArgsFromRequest_Settings(t *testing.T) {
+	conf := NewRoot()
+	conf.SetKey("settings.1.default", "label:Zip code")
+	conf.SetKey("settings.1.continue", "1")
+	conf.SetKey("settings.2.keys.1", "category")
+	conf.SetKey("settings.2.keys.2", "type")
+	conf.SetKey("settings.2.3041.s.value", "suffix:(of house)")
+	conf.SetKey("settings.3.keys.1", "?pickup_location")
+	conf.SetKey("settings.3.true.value", "suffix:(of pick-up location)")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf.With(ArgsFromRequest(r)).GetSettings("settings").WriteTo(w)
+	})
+
+	c := func(query string, expected string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/?"+query, nil))
+		testDeepEqual(t, w.Body.String(), expected)
+	}
+
+	c("", "label: Zip code\n")
+	c("category=3041&type=s", "label: Zip code\nsuffix: (of house)\n")
+	c("category=3041&type=u", "label: Zip code\n")
+	c("pickup_location=", "label: Zip code\nsuffix: (of pick-up location)\n")
+}
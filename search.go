@@ -0,0 +1,81 @@
+package trix
+
+import (
+	"reflect"
+	"strings"
+)
+
+// internalFind walks the node and its descendants depth-first, in ChildKeys
+// order, appending to result the nodes for which pred returns true.
+func internalFind(node *Node, pred func(*Node) bool, result NodeList) NodeList {
+	if node == nil {
+		return result
+	}
+	if pred(node) {
+		result = append(result, node)
+	}
+	for _, key := range node.ChildKeys {
+		result = internalFind(node.Children[key], pred, result)
+	}
+	return result
+}
+
+// FindFunc returns the node and all its descendants for which pred returns
+// true, in depth-first order.
+func (node *Node) FindFunc(pred func(*Node) bool) NodeList {
+	return internalFind(node, pred, NodeList{})
+}
+
+// FindFuncAllScopes is like FindFunc, but also searches the node with the
+// same path on the parent scopes, if any. Results from the top-most scope
+// are first.
+func (node *Node) FindFuncAllScopes(pred func(*Node) bool) NodeList {
+	result := NodeList{}
+	if node == nil {
+		return result
+	}
+	result = internalFind(node, pred, result)
+
+	// look for the node with the same path on each parent scope
+	path := node.Path()
+	for root := node.GetRoot().Parent; root != nil; root = root.Parent {
+		scope := root
+		for _, key := range path {
+			if scope = scope.Children[key]; scope == nil {
+				break
+			}
+		}
+		result = internalFind(scope, pred, result)
+	}
+	return result
+}
+
+// FindValue returns the node and all its descendants whose value is deeply
+// equal to v, in depth-first order.
+func (node *Node) FindValue(v Value) NodeList {
+	return node.FindFunc(func(n *Node) bool { return reflect.DeepEqual(n.Value, v) })
+}
+
+// FindValueAllScopes is like FindValue, but also searches the parent scopes.
+func (node *Node) FindValueAllScopes(v Value) NodeList {
+	return node.FindFuncAllScopes(func(n *Node) bool { return reflect.DeepEqual(n.Value, v) })
+}
+
+// findString returns a predicate that matches nodes with a value whose string
+// representation contains substr.
+func findString(substr string) func(*Node) bool {
+	return func(n *Node) bool {
+		return n.Value != nil && strings.Contains(n.internalStringValue(), substr)
+	}
+}
+
+// FindString returns the node and all its descendants with a value whose
+// string representation contains substr, in depth-first order.
+func (node *Node) FindString(substr string) NodeList {
+	return node.FindFunc(findString(substr))
+}
+
+// FindStringAllScopes is like FindString, but also searches the parent scopes.
+func (node *Node) FindStringAllScopes(substr string) NodeList {
+	return node.FindFuncAllScopes(findString(substr))
+}
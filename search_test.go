@@ -0,0 +1,42 @@
+package trix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("db.host", "payments-old.internal")
+	root.SetKey("db.port", 5432)
+	root.SetKey("cache", "payments-old.internal")
+	root.GetNode("cache").SetKey("port", 5432)
+	root.SetKey("api.hosts", []string{"api.internal", "payments-old.internal"})
+
+	paths := func(nodes NodeList) string {
+		result := []string{}
+		for _, node := range nodes {
+			result = append(result, strings.Join(node.Path(), "."))
+		}
+		return strings.Join(result, ",")
+	}
+
+	testDeepEqual(t, paths(root.FindValue("payments-old.internal")), "db.host,cache")
+	testDeepEqual(t, paths(root.FindValue(5432)), "db.port,cache.port")
+	testDeepEqual(t, paths(root.FindString("payments-old")), "db.host,cache,api.hosts")
+	testDeepEqual(t, paths(root.GetNode("db").FindString("")), "db.host,db.port")
+	testDeepEqual(t, paths(root.FindFunc(func(n *Node) bool { return n.Key == "port" })), "db.port,cache.port")
+	testDeepEqual(t, paths(root.FindString("missing")), "")
+
+	var nilNode *Node
+	testDeepEqual(t, len(nilNode.FindValue(1)), 0)
+	testDeepEqual(t, len(nilNode.FindFuncAllScopes(func(*Node) bool { return true })), 0)
+
+	// scopes
+	scope := root.With(Args{"db.host": "payments.internal", "other": 5432})
+	testDeepEqual(t, paths(scope.FindValue(5432)), "other")
+	testDeepEqual(t, paths(scope.FindValueAllScopes(5432)), "other,db.port,cache.port")
+	testDeepEqual(t, paths(scope.GetNode("db").FindStringAllScopes("payments")), "db.host,db.host")
+	testDeepEqual(t, scope.GetNode("db").FindStringAllScopes("payments").ForEach(func(n *Node) Value { return n.Value }), []Value{"payments.internal", "payments-old.internal"})
+	testDeepEqual(t, paths(scope.With().FindStringAllScopes(".internal")), "db.host,db.host,cache,api.hosts")
+}
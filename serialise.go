@@ -10,69 +10,234 @@ import (
 )
 
 // MarshalJSON returns the node node's and its descendants' representation
-// in JSON.
+// in JSON. A nil node is represented as null. It fails if the descendants are
+// deeper than the root's MaxDepth; see SetLimits.
 func (node *Node) MarshalJSON() ([]byte, error) {
 	if node == nil {
-		return []byte{}, nil
+		return []byte("null"), nil
+	}
+	buf := bytes.Buffer{}
+	if err := internalWriteJSON(&buf, node, 0, newLimiter(node, false), false); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalJSONMeta works like MarshalJSON, but also includes the metadata of
+// the descendants (see SetMeta): objects get a "__meta" member, after their
+// children, mapping each child's key to its metadata, if any children have
+// it. The metadata of the node itself, and of array elements, isn't included.
+func (node *Node) MarshalJSONMeta() ([]byte, error) {
+	if node == nil {
+		return []byte("null"), nil
+	}
+	buf := bytes.Buffer{}
+	if err := internalWriteJSON(&buf, node, 0, newLimiter(node, false), true); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// internalWriteJSON writes the JSON representation of the node, at the
+// specified depth, relative to the node being serialised, to buf. If meta is
+// true, the children's metadata is included; see MarshalJSONMeta.
+func internalWriteJSON(buf *bytes.Buffer, node *Node, depth int, limit *limiter, meta bool) error {
+	if raw, ok := node.Value.(json.RawMessage); ok && len(node.Children) == 0 {
+		buf.Write(raw)
+		return nil
 	}
 
 	forceArray := node.Flags&ForceArray > 0
 	forceMap := node.Flags&ForceMap > 0
 	if len(node.Children) == 0 && !forceArray && !forceMap {
-		return json.Marshal(node.Value)
+		byt, err := json.Marshal(node.Value)
+		buf.Write(byt)
+		return err
+	}
+	if err := limit.checkDepth(depth + 1); err != nil {
+		return err
 	}
 
 	if forceArray || (!forceMap && node.hasOnlyNumericKeys()) {
 		// return a sorted array
-		children := make([]interface{}, len(node.ChildKeys))
-		for index, key := range node.ChildKeys {
-			children[index] = node.Children[key]
+		buf.WriteByte('[')
+		for i, key := range node.ChildKeys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := internalWriteJSON(buf, node.Children[key], depth+1, limit, meta); err != nil {
+				return err
+			}
 		}
-		return json.Marshal(children)
+		buf.WriteByte(']')
+		return nil
 	}
 
 	// serialise children as a sorted map
-	buf := bytes.Buffer{}
-	enc := json.NewEncoder(&buf)
-	buf.Write([]byte{'{'})
+	buf.WriteByte('{')
 	for i, key := range node.ChildKeys {
 		if i > 0 {
 			buf.WriteByte(',')
 		}
-		enc.Encode(key)
-		buf.Write([]byte{':'})
-		enc.Encode(node.Children[key])
+		byt, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(byt)
+		buf.WriteByte(':')
+		if err := internalWriteJSON(buf, node.Children[key], depth+1, limit, meta); err != nil {
+			return err
+		}
 	}
-	buf.Write([]byte{'}', '\n'})
-	return buf.Bytes(), nil
+	if meta {
+		metas := map[string]map[string]interface{}{}
+		for _, key := range node.ChildKeys {
+			if child := node.Children[key]; len(child.meta) > 0 {
+				metas[key] = child.meta
+			}
+		}
+		if len(metas) > 0 {
+			byt, err := json.Marshal(metas)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(`,"__meta":`)
+			buf.Write(byt)
+		}
+	}
+	buf.WriteByte('}')
+	return nil
 }
 
+// MarshalJSON returns the JSON representation of the list, as an array where
+// each element is serialised as it would be inside a tree. Nil nodes are
+// serialised as null.
+func (nodes NodeList) MarshalJSON() ([]byte, error) {
+	if len(nodes) == 0 {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]*Node(nodes))
+}
+
+// ToJSON returns the JSON representation of the list, indented with the
+// specified string; if it's empty, the result is compact.
+func (nodes NodeList) ToJSON(indent string) ([]byte, error) {
+	if indent == "" {
+		return json.Marshal(nodes)
+	}
+	return json.MarshalIndent(nodes, "", indent)
+}
+
+// DumpOptions changes how DumpWith writes a node.
+type DumpOptions struct {
+	// Short writes the node in a single line, like String does, instead of
+	// one "path=value" line per leaf.
+	Short bool
+
+	// FormatDurations writes durations using FormatDuration, instead of
+	// Go's format.
+	FormatDurations bool
+
+	// MarkUnread adds a " # unread" comment to the leaves that were never
+	// read, if the root is tracking access; see TrackAccess. It's ignored
+	// when Short is true.
+	MarkUnread bool
+
+	// Origins adds a comment with the origin of each value, if known, e.g.
+	// " # etc/app.conf:2"; see TrackOrigins. It's ignored when Short is true.
+	Origins bool
+
+	// SkipNil skips the leaves without a value, like the ones created by
+	// AddNode, instead of writing them as "path=". It's ignored when Short
+	// is true.
+	SkipNil bool
+
+	// Escape escapes backslashes, commas, equal signs and braces in keys
+	// and values with a backslash, when Short is true, so that the output
+	// is unambiguous.
+	Escape bool
+}
+
+// shortEscaper escapes the characters with special meaning in the short form.
+var shortEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, "{", `\{`, "}", `\}`)
+
 // Dump dumps the JSON representation of a node and its descendants.
 func (node *Node) Dump(w io.Writer, short bool) {
+	node.DumpWith(w, DumpOptions{Short: short})
+}
+
+// DumpWith is like Dump, but accepts options. In the long form, one
+// "path=value" line is written for each leaf, as well as for each node with
+// both a value and children, before the children's. In the short form, nodes
+// are written as "key=value", followed by their children within braces, if
+// any, e.g. "{a=1{b=2,c=}}". Nil values are written as empty strings.
+// Descendants deeper than the root's MaxDepth (see SetLimits) are skipped; in
+// the short form, their parents' children are written as "{...}".
+func (node *Node) DumpWith(w io.Writer, opts DumpOptions) {
 	if node == nil {
 		return
 	}
+	limit := newLimiter(node, false)
 
+	short := opts.Short
+	var tracker *accessTracker
+	if opts.MarkUnread {
+		tracker = node.GetRoot().accessTracker()
+	}
 	formatValue := func(v Value) string {
-		if s, ok := v.(string); ok {
+		if v == nil {
+			return ""
+		} else if s, ok := v.(string); ok {
 			return s
+		} else if raw, ok := v.(json.RawMessage); ok {
+			return string(raw)
 		} else if t, ok := v.(time.Time); ok {
 			return t.Format(time.RFC3339Nano)
+		} else if d, ok := v.(time.Duration); ok && opts.FormatDurations {
+			return FormatDuration(d)
 		}
 		return fmt.Sprint(v)
 	}
+	escape := func(s string) string {
+		if opts.Escape {
+			return shortEscaper.Replace(s)
+		}
+		return s
+	}
+	writeLine := func(node *Node) {
+		fmt.Fprintf(w, "%s=%s", strings.Join(node.Path(), "."), formatValue(node.Value))
+		var comments []string
+		if origin, ok := originOf(node); ok && opts.Origins {
+			comments = append(comments, origin.String())
+		}
+		if tracker != nil && tracker.readCount(node) == 0 {
+			comments = append(comments, "unread")
+		}
+		if len(comments) > 0 {
+			fmt.Fprintf(w, " # %s", strings.Join(comments, ", "))
+		}
+		w.Write([]byte("\n"))
+	}
 
 	var toString func(*Node, int)
 	toString = func(node *Node, depth int) {
 		if short && depth > 0 {
-			fmt.Fprintf(w, "%s=", node.Key)
+			fmt.Fprintf(w, "%s=", escape(node.Key))
 		}
 		if short && node.Value != nil && depth > 0 {
-			w.Write([]byte(formatValue(node.Value)))
+			w.Write([]byte(escape(formatValue(node.Value))))
 		}
-		if len(node.ChildKeys) > 0 {
+		if len(node.ChildKeys) > 0 && limit.checkDepth(depth+1) != nil {
+			if short {
+				w.Write([]byte("{...}"))
+			} else if node.Value != nil {
+				writeLine(node)
+			}
+		} else if len(node.ChildKeys) > 0 {
 			if short && depth > 0 {
 				w.Write([]byte("{"))
+			} else if !short && node.Value != nil {
+				writeLine(node)
 			}
 			for i, k := range node.ChildKeys {
 				if short && i > 0 {
@@ -83,8 +248,8 @@ func (node *Node) Dump(w io.Writer, short bool) {
 			if short && depth > 0 {
 				w.Write([]byte("}"))
 			}
-		} else if !short {
-			fmt.Fprintf(w, "%s=%s\n", strings.Join(node.Path(), "."), formatValue(node.Value))
+		} else if !short && (node.Value != nil || !opts.SkipNil) {
+			writeLine(node)
 		}
 	}
 
@@ -1,8 +1,12 @@
 package trix
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"os"
 	"testing"
+	"time"
 )
 
 func TestMarshalJSON(t *testing.T) {
@@ -46,3 +50,131 @@ func TestMarshalJSON(t *testing.T) {
 	root.AddNode("empty.map").Flags = ForceMap
 	check(`{"empty":{"array":[],"map":{}}}`)
 }
+
+func TestNodeList_MarshalJSON(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("item.1.name", "Socks")
+	root.SetKey("item.1.sizes.1", "S")
+	root.SetKey("item.1.sizes.2", "M")
+	root.SetKey("item.2.name", "Cool shirt")
+	root.SetKey("item.2.tags.1", "new")
+	root.SetKey("item.3", 17)
+	root.GetNode("item.2.tags").Flags = ForceMap
+
+	check := func(nodes NodeList, expectedValue string) {
+		t.Helper()
+		byt, err := json.Marshal(nodes)
+		testError(t, err, "")
+		testEqualString(t, string(byt), expectedValue)
+	}
+
+	check(root.GetNodes("item.*"), `[{"name":"Socks","sizes":["S","M"]},{"name":"Cool shirt","tags":{"1":"new"}},17]`)
+	check(NodeList{root.GetNode("item.3"), nil}, `[17,null]`)
+	check(root.GetNodes("missing.*"), `[]`)
+	check(nil, `[]`)
+
+	// the list's own method, and nodes marshalled directly, are compact
+	byt, err := root.GetNodes("item.1").MarshalJSON()
+	testError(t, err, "")
+	testEqualString(t, string(byt), `[{"name":"Socks","sizes":["S","M"]}]`)
+	byt, err = root.GetNode("item.1").MarshalJSON()
+	testError(t, err, "")
+	testEqualString(t, string(byt), `{"name":"Socks","sizes":["S","M"]}`)
+
+	byt, err = root.GetNodes("item.1.sizes").ToJSON("  ")
+	testError(t, err, "")
+	testEqualString(t, string(byt), "[\n  [\n    \"S\",\n    \"M\"\n  ]\n]")
+	byt, err = root.GetNodes("item.*.name").ToJSON("")
+	testError(t, err, "")
+	testEqualString(t, string(byt), `["Socks","Cool shirt"]`)
+}
+
+func TestDumpWith(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("timeout", 49*time.Hour+20*time.Minute)
+	root.SetKey("delay", 250*time.Millisecond)
+
+	buf := bytes.Buffer{}
+	root.DumpWith(&buf, DumpOptions{FormatDurations: true})
+	testDeepEqual(t, buf.String(), "timeout=2d1h20m\ndelay=250ms\n")
+	buf.Reset()
+	root.DumpWith(&buf, DumpOptions{Short: true, FormatDurations: true})
+	testDeepEqual(t, buf.String(), "{timeout=2d1h20m,delay=250ms}")
+	testEqualString(t, root, "{timeout=49h20m0s,delay=250ms}")
+
+	buf.Reset()
+	testError(t, root.WriteConf(&buf), "")
+	testDeepEqual(t, buf.String(), "timeout:duration=2d1h20m\ndelay=250ms\n")
+}
+
+func TestDumpWith_Golden(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server", "main")
+	root.SetKey("server.host", "localhost")
+	root.SetKey("server.tags.1", "a,b")
+	root.AddNode("server.empty")
+	root.AddNode("db.pool")
+	root.SetKey("label", "Zip {code}=x")
+
+	buf := bytes.Buffer{}
+	for _, test := range []struct {
+		title string
+		opts  DumpOptions
+	}{
+		{"long", DumpOptions{}},
+		{"long, skipping nil leaves", DumpOptions{SkipNil: true}},
+		{"short", DumpOptions{Short: true}},
+		{"short, escaped", DumpOptions{Short: true, Escape: true}},
+	} {
+		fmt.Fprintf(&buf, "# %s\n", test.title)
+		root.DumpWith(&buf, test.opts)
+		if test.opts.Short {
+			buf.WriteByte('\n')
+		}
+	}
+	golden, err := os.ReadFile("testdata/dump.golden")
+	testError(t, err, "")
+	testDeepEqual(t, buf.String(), string(golden))
+	testEqualString(t, root, "{server=main{host=localhost,tags={1=a,b},empty=},db={pool=},label=Zip {code}=x}")
+}
+
+func TestMarshalJSON_Shapes(t *testing.T) {
+	// nil nodes
+	var nilNode *Node
+	byt, err := json.Marshal(struct {
+		Conf *Node `json:"conf"`
+	}{nilNode})
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), `{"conf":null}`)
+	byt, err = json.Marshal(NewRoot())
+	testError(t, err, "")
+	testDeepEqual(t, string(byt), `null`)
+
+	// round trips
+	for _, test := range []struct {
+		json, tree string
+	}{
+		{`{"a":1,"b":[true,"x"]}`, "{a=1,b={1=true,2=x}}"},
+		{`[1,"two",{"three":3}]`, "{1=1,2=two,3={three=3}}"},
+		{`[]`, "{}"},
+		{`"just a string"`, "{}"},
+		{`3.5`, "{}"},
+		{`null`, "{}"},
+	} {
+		root := NewRoot()
+		testError(t, json.Unmarshal([]byte(test.json), root), "")
+		root.SortRecursively()
+		testEqualString(t, root, test.tree)
+		byt, err := json.Marshal(root)
+		testError(t, err, "")
+		testDeepEqual(t, string(byt), test.json)
+	}
+
+	root := NewRoot()
+	testError(t, json.Unmarshal([]byte(`"value"`), root), "")
+	testDeepEqual(t, root.Value, "value")
+	testError(t, json.Unmarshal([]byte(`[]`), root), "")
+	testDeepEqual(t, root.Flags, IsRoot|ForceArray)
+	testError(t, root.UnmarshalJSON([]byte(` `)), "unexpected end of JSON input")
+	testError(t, root.UnmarshalJSON([]byte(`[1,`)), "unexpected end of JSON input")
+}
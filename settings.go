@@ -1,5 +1,12 @@
 package trix
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // GetSettings returns the settings values that matches the environment,
 // starting from the matched nodes. It should be called with a spec matching
 // the nodes where settings should be run, and usually a temporary environment
@@ -42,7 +49,62 @@ package trix
 // defined more than once, and all values are returned.
 //
 // If no key is used, "value" is assumed.
+//
+// A case key can also be a comma-separated list of values, e.g.
+// "settings.2.1001,1002.sale.value", that matches any of them; commas can be
+// escaped with a backslash. Exact keys are tried first, then lists,
+// then comparisons, and then "*".
+//
+// A case key can also be a comparison, e.g. ">=10000", "<60" or ">1h": the
+// value is compared as an int, a float or a duration, depending on what both
+// sides parse as. Since keys are split on dots, use exponents for fractions,
+// e.g. ">=25e-2". Multiple comparisons are tried in order, and the first
+// match wins. A leading backslash makes a key literal, e.g. "\>5" only
+// matches ">5".
+//
+// A `keys` entry of "*" ignores that level: whatever the environment has,
+// only the "*" branch is used at that level. A key missing from the
+// environment (other than `?key` checks) also only matches the "*" branch.
+//
+// If the settings node has a "merge=1" key (or GetSettingsMerged is used),
+// the values of the `default` cases are used first, regardless of their
+// position, and each matching case (stopping at the first one, unless it has
+// "continue=1") replaces the values of the keys it defines, keeping the
+// others; the `default` cases themselves never stop the matching. Multiple
+// values for the same key within a case are all kept. When multiple settings
+// are returned, each one is merged separately.
 func (node *Node) GetSettings(keys ...interface{}) Reply {
+	return internalGetSettings(node, func(key string) (Value, error) {
+		return node.TryGet(key)
+	}, false, keys)
+}
+
+// GetSettingsMerged is like GetSettings, but always merges the values of the
+// matching cases with those of the `default` ones, as if all settings nodes
+// had a "merge=1" key.
+func (node *Node) GetSettingsMerged(keys ...interface{}) Reply {
+	return internalGetSettings(node, func(key string) (Value, error) {
+		return node.TryGet(key)
+	}, true, keys)
+}
+
+// GetSettingsWith returns the same as `node.With(env).GetSettings(keys...)`,
+// when called on a root, but without creating a new scope: the values used
+// by the `keys` cases (including `?key` presence checks) are looked up in env
+// first, and only then on the node. Keys are looked up in env as-is, so
+// env keys should not have prefixes of other keys (e.g. "a" and "a.b").
+func (node *Node) GetSettingsWith(env Args, keys ...interface{}) Reply {
+	return internalGetSettings(node, func(key string) (Value, error) {
+		if value, found := env[key]; found {
+			return value, nil
+		}
+		return node.TryGet(key)
+	}, false, keys)
+}
+
+// internalGetSettings implements GetSettings, using lookup to find the values
+// of the case keys. If merge is true, all settings are merged.
+func internalGetSettings(node *Node, lookup func(key string) (Value, error), merge bool, keys []interface{}) Reply {
 	reply := Reply{}
 	if node == nil || len(keys) < 1 {
 		// avoid a segfault
@@ -51,7 +113,18 @@ func (node *Node) GetSettings(keys ...interface{}) Reply {
 
 	usePrefix := false
 	prefix := ""
+	merging := false
 	parsealue := func(value string) {
+		target := reply
+		if merging {
+			// parse into a new reply, and then replace the keys
+			target = Reply{}
+			defer func() {
+				for key, values := range target {
+					reply[key] = values
+				}
+			}()
+		}
 		for _, value := range splitEsc(value, ",", `\`) {
 			var subKey, subValue string
 			if parts := splitNEsc(value, ":", `\`, 2); len(parts) == 2 {
@@ -67,13 +140,13 @@ func (node *Node) GetSettings(keys ...interface{}) Reply {
 					subKey = prefix + "_" + subKey
 				}
 			}
-			reply[subKey] = append(reply[subKey], subValue)
+			target[subKey] = append(target[subKey], subValue)
 		}
 	}
 
 	// if we're returning multiple settings, prefix each one with the parent
 	// settings root node's key, followed by an underscore.
-	if strKeys := ParseKeys(keys); strKeys[len(strKeys)-1] == "*" {
+	if strKeys := ParseKeys(keys); len(strKeys) > 0 && strKeys[len(strKeys)-1] == "*" {
 		usePrefix = true
 	}
 
@@ -85,46 +158,287 @@ func (node *Node) GetSettings(keys ...interface{}) Reply {
 		if usePrefix {
 			prefix = settingNode.Key
 		}
+		merging = merge
+		if mergeNode, found := settingNode.Children["merge"]; found && !merging {
+			merging, _ = parseBool(mergeNode.Value)
+		}
+		cases := settingNode.GetNodes("*").Filter(func(caseNode *Node) bool {
+			return caseNode.Key != "merge"
+		})
+		if merging {
+			// seed the reply with the defaults
+			for _, caseNode := range cases {
+				if defaultNode, found := caseNode.Children["default"]; found {
+					parsealue(defaultNode.internalStringValue())
+				}
+			}
+		}
 
-		for _, caseNode := range settingNode.GetNodes("*") {
+		for _, caseNode := range cases {
 			matched := false
-			if defaultNode := caseNode.GetNode("default"); defaultNode != nil {
+			if defaultNode, found := caseNode.Children["default"]; found {
+				if merging {
+					// already used
+					continue
+				}
 				// the `default` node takes precedence over others;
 				// if it's present, use its value
 				parsealue(defaultNode.internalStringValue())
 				matched = true
 
-			} else if keysNode := caseNode.GetNode("keys"); keysNode != nil {
+			} else if keysNode, found := caseNode.Children["keys"]; found {
 				// next try matching the values for the `keys` node.
 				wantedKeys := keysNode.GetStringValues("*")
-				valueSpec := make([]interface{}, len(wantedKeys)+1)
+				valueSpec := make([]string, len(wantedKeys)+1)
 				for i := 0; i < len(wantedKeys); i++ {
-					if key := wantedKeys[i]; key[0] == '?' {
+					if key := wantedKeys[i]; key == "*" {
+						// a "*" key accepts any value, so only the `*`
+						// branch is used, and nothing is looked up.
+						valueSpec[i] = "*"
+					} else if strings.HasPrefix(key, "?") {
 						// when the key name starts with '?', instead of the
 						// key's value, use "true" if the key is present or
 						// "false" otherwise.
 						key = key[1:]
-						if _, err := node.TryGet(key); err == nil {
+						if _, err := lookup(key); err == nil {
 							valueSpec[i] = "true"
 						} else {
 							valueSpec[i] = "false"
 						}
+					} else if value, err := lookup(key); err == nil {
+						valueSpec[i] = fmt.Sprint(value)
 					} else {
-						valueSpec[i] = node.Get(key)
+						// a missing key only matches the `*` branch
+						valueSpec[i] = "*"
 					}
 				}
 				valueSpec[len(wantedKeys)] = "value"
 
-				if valueNode := caseNode.GetNode(valueSpec...); valueNode != nil {
+				valueNode := internalMatchCase(caseNode, valueSpec)
+				// try the same case on the parent scopes
+				path := caseNode.Path()
+				for root := caseNode.GetRoot().Parent; valueNode == nil && root != nil; root = root.Parent {
+					if scopeNode := internalGetExact(root, path); scopeNode != nil {
+						valueNode = internalMatchCase(scopeNode, valueSpec)
+					}
+				}
+				if valueNode != nil {
 					matched = true
 					parsealue(valueNode.internalStringValue())
 				}
 			}
 
-			if matched && !caseNode.GetBool("continue") {
+			if matched && !caseNode.GetBoolDefault(false, "continue") {
 				break
 			}
 		}
 	}
 	return reply
 }
+
+// internalMatchCase returns the first descendant of the case node matching
+// the values, one per level, or nil. At each level, an exact key is tried
+// first (a value with dots matches one level per part), then keys with a list
+// of comma-separated alternatives (commas can be escaped with a backslash),
+// then comparisons (see matchComparison), in ChildKeys order, and finally "*".
+func internalMatchCase(node *Node, values []string) *Node {
+	if len(values) == 0 {
+		return node
+	}
+	value, rest := values[0], values[1:]
+	if child := internalGetExact(node, strings.Split(value, ".")); child != nil {
+		if match := internalMatchCase(child, rest); match != nil {
+			return match
+		}
+	}
+
+	// escaped keys first, then lists
+	for _, isList := range []bool{false, true} {
+		for _, childKey := range node.ChildKeys {
+			if !strings.ContainsAny(childKey, `,\`) {
+				continue
+			}
+			alternatives := splitEsc(childKey, ",", `\`)
+			if (len(alternatives) > 1) != isList {
+				continue
+			}
+			for _, alternative := range alternatives {
+				if strings.TrimPrefix(alternative, `\`) == value {
+					if match := internalMatchCase(node.Children[childKey], rest); match != nil {
+						return match
+					}
+					break
+				}
+			}
+		}
+	}
+
+	if value != "*" {
+		for _, childKey := range node.ChildKeys {
+			if matchComparison(childKey, value) {
+				if match := internalMatchCase(node.Children[childKey], rest); match != nil {
+					return match
+				}
+			}
+		}
+		if child, found := node.Children["*"]; found {
+			return internalMatchCase(child, rest)
+		}
+	}
+	return nil
+}
+
+// comparisons are the operators accepted by matchComparison, longest first.
+var comparisons = []struct {
+	operator string
+	match    func(cmp int) bool
+}{
+	{">=", func(cmp int) bool { return cmp >= 0 }},
+	{"<=", func(cmp int) bool { return cmp <= 0 }},
+	{">", func(cmp int) bool { return cmp > 0 }},
+	{"<", func(cmp int) bool { return cmp < 0 }},
+}
+
+// matchComparison returns whether the key is a comparison, e.g. ">=10000",
+// and the value satisfies it. Both sides are compared as ints if both parse
+// as ints, otherwise as floats, otherwise as durations; if none applies, the
+// comparison fails.
+func matchComparison(key, value string) bool {
+	for _, comparison := range comparisons {
+		if !strings.HasPrefix(key, comparison.operator) {
+			continue
+		}
+		threshold := key[len(comparison.operator):]
+		if cmp, ok := compareValues(value, threshold); ok {
+			return comparison.match(cmp)
+		}
+		return false
+	}
+	return false
+}
+
+// compareValues compares a and b as ints, floats or durations, whichever both
+// parse as first, returning -1, 0 or 1, and whether they could be compared.
+func compareValues(a, b string) (int, bool) {
+	sign := func(less, greater bool) int {
+		if less {
+			return -1
+		} else if greater {
+			return 1
+		}
+		return 0
+	}
+	if x, err := parseInt(a); err == nil {
+		if y, err := parseInt(b); err == nil {
+			return sign(x < y, x > y), true
+		}
+	}
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return sign(x < y, x > y), true
+		}
+	}
+	parseAnyDuration := func(s string) (time.Duration, error) {
+		if d, err := parseDuration(s); err == nil {
+			return d, nil
+		}
+		return time.ParseDuration(s)
+	}
+	if x, err := parseAnyDuration(a); err == nil {
+		if y, err := parseAnyDuration(b); err == nil {
+			return sign(x < y, x > y), true
+		}
+	}
+	return 0, false
+}
+
+// ValidateSettings checks the settings nodes matching the spec, like the ones
+// passed to GetSettings, and returns an error for each problem found, with
+// the path of the offending node. Each case must have either a `default` or
+// a non-empty `keys` list, with one level of nodes for each key before each
+// `value`, and `continue` and `merge` must be bools. Values are not checked,
+// since any escapes in them are valid: a backslash that doesn't precede
+// another one, or a separator, is kept as is. Return nil if there are no
+// problems.
+func (node *Node) ValidateSettings(keys ...interface{}) []error {
+	var errs []error
+	report := func(n *Node, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", n.PathString(), fmt.Sprintf(format, args...)))
+	}
+
+	for _, settingNode := range node.GetNodes(keys...) {
+		if len(settingNode.ChildKeys) == 0 {
+			report(settingNode, "no cases")
+		}
+		for _, caseKey := range settingNode.ChildKeys {
+			caseNode := settingNode.Children[caseKey]
+			if caseKey == "merge" {
+				if _, err := parseBool(caseNode.Value); err != nil {
+					report(caseNode, "bad bool %q", caseNode.internalStringValue())
+				}
+				continue
+			}
+			if continueNode, found := caseNode.Children["continue"]; found {
+				if _, err := parseBool(continueNode.Value); err != nil {
+					report(continueNode, "bad bool %q", continueNode.internalStringValue())
+				}
+			}
+
+			if _, found := caseNode.Children["default"]; found {
+				continue
+			}
+			keysNode, found := caseNode.Children["keys"]
+			if !found {
+				report(caseNode, "case has neither keys nor default")
+				continue
+			}
+			if len(keysNode.ChildKeys) == 0 {
+				report(keysNode, "empty keys list")
+				continue
+			}
+			for _, key := range keysNode.ChildKeys {
+				if keyNode := keysNode.Children[key]; strings.TrimPrefix(keyNode.internalStringValue(), "?") == "" {
+					report(keyNode, "empty key")
+				}
+			}
+
+			// each branch must have one level per key, and then a value
+			depth := len(keysNode.ChildKeys)
+			var walk func(*Node, int)
+			walk = func(n *Node, level int) {
+				if level == depth {
+					if _, found := n.Children["value"]; !found {
+						report(n, "missing value")
+					}
+					for _, key := range n.ChildKeys {
+						if key != "value" {
+							report(n.Children[key], "unexpected key, after %d keys", depth)
+						}
+					}
+					return
+				}
+				if len(n.ChildKeys) == 0 {
+					report(n, "missing value, after %d of %d keys", level, depth)
+				}
+				for _, key := range n.ChildKeys {
+					if key == "value" {
+						report(n.Children[key], "value after %d of %d keys", level, depth)
+					} else {
+						walk(n.Children[key], level+1)
+					}
+				}
+			}
+			found = false
+			for _, key := range caseNode.ChildKeys {
+				if key != "keys" && key != "continue" {
+					found = true
+					walk(caseNode.Children[key], 1)
+				}
+			}
+			if !found {
+				report(caseNode, "no values")
+			}
+		}
+	}
+	return errs
+}
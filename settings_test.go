@@ -1,7 +1,10 @@
 package trix
 
 import (
+	"bytes"
+	"errors"
 	"testing"
+	"time"
 )
 
 var (
@@ -91,6 +94,7 @@ func TestSettings(t *testing.T) {
 	c := func(lastKey string, added Args, expected Reply) {
 		t.Helper()
 		testDeepEqual(t, root.With(added).GetSettings("settings", lastKey), expected)
+		testDeepEqual(t, root.GetSettingsWith(added, "settings", lastKey), expected)
 	}
 
 	// 1-level keys, default
@@ -117,3 +121,370 @@ func TestSettings(t *testing.T) {
 	c("images", Args{"category": 1001, "type": "whatever"}, Reply{"max": {"12"}, "extra": {"4"}, "extra_price": {"5"}})
 	c("images", Args{"category": 1003, "type": "whatever"}, Reply{"max": {"0"}, "comment": {"Easy as 1,2,3"}})
 }
+
+// sampleRoot returns a new root with the sample entries.
+func sampleRoot(entries []string) *Node {
+	root := NewRoot()
+	for _, entry := range entries {
+		parts := splitNEsc(entry, "=", `\`, 2)
+		root.SetKey(parts[0], parts[1])
+	}
+	root.SortRecursively()
+	return root
+}
+
+func TestSettings_With(t *testing.T) {
+	root := sampleRoot(sampleSett)
+	root.SetKey("category", 9999) // shadowed by the args
+	for _, args := range []Args{
+		nil,
+		{},
+		{"category": 1020},
+		{"category": "8020"},
+		{"category": 2021, "type": "s"},
+		{"category": 2022, "type": "s"},
+		{"category": 2023, "type": "x"},
+		{"category": 2023},
+		{"type": "s"},
+		{"other": 1},
+	} {
+		for _, spec := range []string{"main.settings.*", "main.settings.types", "main.settings.params", "main.settings.missing"} {
+			expected := root.With(args).GetSettings(spec)
+			testDeepEqual(t, root.GetSettingsWith(args, spec), expected)
+		}
+	}
+
+	// values not in the args are looked up in the node, including ?keys
+	root.SetKey("settings.x.1.keys.1", "?flag")
+	root.SetKey("settings.x.1.keys.2", "type")
+	root.SetKey("settings.x.1.true.s.value", "yes")
+	root.SetKey("type", "s")
+	testDeepEqual(t, root.GetSettingsWith(Args{"flag": nil}, "settings.x"), Reply{"value": {"yes"}})
+	testDeepEqual(t, root.GetSettingsWith(Args{}, "settings.x"), Reply{})
+	root.SetKey("flag", true)
+	testDeepEqual(t, root.GetSettingsWith(Args{"type": "u"}, "settings.x"), Reply{})
+	testDeepEqual(t, root.GetSettingsWith(Args{}, "settings.x"), Reply{"value": {"yes"}})
+}
+
+func BenchmarkGetSettings_With(b *testing.B) {
+	root := sampleRoot(sampleSett)
+	args := Args{"category": 2022, "type": "s"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		root.With(args).GetSettings("main.settings.*")
+	}
+}
+
+func BenchmarkGetSettingsWith(b *testing.B) {
+	root := sampleRoot(sampleSett)
+	args := Args{"category": 2022, "type": "s"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		root.GetSettingsWith(args, "main.settings.*")
+	}
+}
+
+func TestSettings_MergeReply(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.images.1.keys.1`, `category`)
+	root.SetKey(`settings.images.1.1001.value`, `max:12,extra:4,extra_price:5`)
+	root.SetKey(`settings.images.1.1002.value`, `max:12`)
+	root.SetKey(`settings.images.2.default`, `max:8`)
+
+	// the second stage depends on the results of the first one
+	root.SetKey(`settings.upload.1.keys.1`, `images.max`)
+	root.SetKey(`settings.upload.1.keys.2`, `?images.extra`)
+	root.SetKey(`settings.upload.1.12.true.value`, `widget:gallery_extra`)
+	root.SetKey(`settings.upload.1.12.false.value`, `widget:gallery`)
+	root.SetKey(`settings.upload.2.default`, `widget:simple`)
+
+	c := func(added Args, expected Reply) {
+		t.Helper()
+		env := root.With(added)
+		step1 := env.GetSettings("settings.images")
+		testDeepEqual(t, env.MergeReply(step1, "images").GetSettings("settings.upload"), expected)
+	}
+
+	c(Args{"category": 1001}, Reply{"widget": {"gallery_extra"}})
+	c(Args{"category": 1002}, Reply{"widget": {"gallery"}})
+	c(Args{"category": 1099}, Reply{"widget": {"simple"}})
+}
+
+func TestReply_ToNode(t *testing.T) {
+	reply := Reply{"max": {"12"}, "type": {"sell", "rent"}, "none": {}}
+	node := reply.ToNode("step1")
+	testEqualString(t, node, `{max=12,none=,type={1=sell,2=rent}}`)
+	testDeepEqual(t, node.Key, "step1")
+
+	root := NewRoot()
+	root.SetKey("step1.max", "1")
+	root.SetKey("step1.keep", "yes")
+	root.SetKey("step1.type.3", "old")
+	testTrue(t, root.MergeReply(reply, "step1") == root)
+	testEqualString(t, root, `{step1={keep=yes,max=12,none=,type={1=sell,2=rent}}}`)
+	testConsistent(t, root)
+
+	root.MergeReply(Reply{"top": {"level"}})
+	testDeepEqual(t, root.Get("top"), "level")
+}
+
+func TestSettings_TypedReply(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.limits.1.keys.1`, `plan`)
+	root.SetKey(`settings.limits.1.pro.value`, `sizes:10,sizes:20,sizes:30,ratio:0.5,ratio:1.5,on:t,on:off,wait:1m,wait:2h`)
+	root.SetKey(`settings.limits.1.bad.value`, `sizes:10,sizes:twenty,ratio:x,on:maybe,wait:1m,wait:soon`)
+
+	pro := root.With(Args{"plan": "pro"}).GetSettings("settings.limits")
+	testDeepEqual(t, pro.GetInts("sizes"), []int{10, 20, 30})
+	testDeepEqual(t, pro.GetFloats("ratio"), []float64{0.5, 1.5})
+	testDeepEqual(t, pro.GetBools("on"), []bool{true, false})
+	testDeepEqual(t, pro.GetDurations("wait"), []time.Duration{time.Minute, time.Hour * 2})
+	testTrue(t, pro.GetInts("missing") == nil)
+
+	bad := root.With(Args{"plan": "bad"}).GetSettings("settings.limits")
+	testTrue(t, bad.GetInts("sizes") == nil)
+	_, err := bad.TryGetInts("sizes")
+	testError(t, err, `value 1 ("twenty"): strconv.ParseInt: parsing "twenty": invalid syntax`)
+	_, err = bad.TryGetFloats("ratio")
+	testError(t, err, `value 0 ("x"): strconv.ParseFloat: parsing "x": invalid syntax`)
+	_, err = bad.TryGetBools("on")
+	testError(t, err, `value 0 ("maybe"): bad value`)
+	_, err = bad.TryGetDurations("wait")
+	testError(t, err, `value 1 ("soon"): bad duration`)
+	testTrue(t, errors.Is(err, ErrParseDuration))
+	_, err = bad.TryGetDurations("missing")
+	testError(t, err, `key not found`)
+}
+
+func TestValidateSettings(t *testing.T) {
+	root := sampleRoot(sampleSett)
+	testDeepEqual(t, len(root.ValidateSettings("main.settings.*")), 0)
+	testTrue(t, root.ValidateSettings("main.settings.*") == nil)
+
+	root = sampleRoot([]string{
+		"settings.a.1.keys.1=category",
+		"settings.a.1.1001.value=x\\",
+		"settings.a.1.1002.value=y\\\\",
+		"settings.a.1.continue=maybe",
+		"settings.a.2.whatever=1",
+		"settings.a.3.keys.1=category",
+		"settings.a.3.keys.2=?",
+		"settings.a.3.1001.s.value=ok",
+		"settings.a.3.1002.value=short",
+		"settings.a.3.1003.s.x.value=long",
+		"settings.a.3.1004.s.other=1",
+		"settings.a.4.default=ok",
+		"settings.a.4.continue=1",
+		"settings.b.1.keys=",
+		"settings.b.2.keys.1=type",
+		"settings.b.2.continue=1",
+	})
+	root.AddNode("settings.c")
+	errs := root.ValidateSettings("settings.*")
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	testDeepEqual(t, messages, []string{
+		`settings.a.1.continue: bad bool "maybe"`,
+		`settings.a.2: case has neither keys nor default`,
+		`settings.a.3.keys.2: empty key`,
+		`settings.a.3.1002.value: value after 1 of 2 keys`,
+		`settings.a.3.1003.s: missing value`,
+		`settings.a.3.1003.s.x: unexpected key, after 2 keys`,
+		`settings.a.3.1004.s: missing value`,
+		`settings.a.3.1004.s.other: unexpected key, after 2 keys`,
+		`settings.b.1.keys: empty keys list`,
+		`settings.b.2: no values`,
+		`settings.c: no cases`,
+	})
+	testDeepEqual(t, root.GetString("settings.a.1.1001.value"), `x\`)
+	testDeepEqual(t, root.GetString("settings.a.1.1002.value"), `y\`)
+}
+
+func TestSettings_Merged(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.images.1.keys.1`, `category`)
+	root.SetKey(`settings.images.1.1001.value`, `max:12,extra:4,extra:5`)
+	root.SetKey(`settings.images.1.1002.value`, `max:12`)
+	root.SetKey(`settings.images.1.continue`, `1`)
+	root.SetKey(`settings.images.2.keys.1`, `type`)
+	root.SetKey(`settings.images.2.buy.value`, `max:0`)
+	root.SetKey(`settings.images.3.default`, `max:8,extra:0,size:big`)
+	root.SetKey(`settings.params.1.keys.1`, `category`)
+	root.SetKey(`settings.params.1.1001.value`, `price`)
+	root.SetKey(`settings.params.2.default`, `color`)
+
+	c := func(args Args, expected, merged Reply) {
+		t.Helper()
+		testDeepEqual(t, root.GetSettingsWith(args, "settings.images"), expected)
+		testDeepEqual(t, root.With(args).GetSettingsMerged("settings.images"), merged)
+	}
+
+	// without merging, the first matching case wins
+	c(Args{}, Reply{"max": {"8"}, "extra": {"0"}, "size": {"big"}}, Reply{"max": {"8"}, "extra": {"0"}, "size": {"big"}})
+	c(Args{"category": 1002}, Reply{"max": {"12", "8"}, "extra": {"0"}, "size": {"big"}}, Reply{"max": {"12"}, "extra": {"0"}, "size": {"big"}})
+	c(Args{"category": 1001}, Reply{"max": {"12", "8"}, "extra": {"4", "5", "0"}, "size": {"big"}}, Reply{"max": {"12"}, "extra": {"4", "5"}, "size": {"big"}})
+	c(Args{"category": 1001, "type": "buy"}, Reply{"max": {"12", "0"}, "extra": {"4", "5"}}, Reply{"max": {"0"}, "extra": {"4", "5"}, "size": {"big"}})
+	c(Args{"type": "buy"}, Reply{"max": {"0"}}, Reply{"max": {"0"}, "extra": {"0"}, "size": {"big"}})
+
+	// merge key on the settings node; star-prefix mode merges each setting
+	root.SetKey(`settings.images.merge`, `1`)
+	testDeepEqual(t, root.GetSettingsWith(Args{"type": "buy"}, "settings.images"), Reply{"max": {"0"}, "extra": {"0"}, "size": {"big"}})
+	testDeepEqual(t, root.GetSettingsWith(Args{"category": 1001}, "settings.*"), Reply{
+		"images_max":   {"12"},
+		"images_extra": {"4", "5"},
+		"images_size":  {"big"},
+		"params":       {"price"},
+	})
+	testDeepEqual(t, root.GetSettingsMerged("settings.*"), Reply{
+		"images_max":   {"8"},
+		"images_extra": {"0"},
+		"images_size":  {"big"},
+		"params":       {"color"},
+	})
+	testTrue(t, root.ValidateSettings("settings.*") == nil)
+	root.SetKey(`settings.images.merge`, `maybe`)
+	testDeepEqual(t, len(root.ValidateSettings("settings.*")), 1)
+}
+
+func TestSettings_ListKeys(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.labels.1.keys.1`, `category`)
+	root.SetKey(`settings.labels.1.keys.2`, `type`)
+	root.SetKey(`settings.labels.1.3041,3042,3050.s.value`, `house`)
+	root.SetKey(`settings.labels.1.3042.s.value`, `exact`)
+	root.SetKey(`settings.labels.1.3050,3060.*.value`, `any type`)
+	root.SetKey(`settings.labels.1.3042,3060.u.value`, `apartment`)
+	root.SetKey(`settings.labels.1.*.u.value`, `star`)
+	root.SetKey(`settings.labels.1.a\,b.s.value`, `literal`)
+	root.SetKey(`settings.labels.1.a\,b,c.u.value`, `list with literal`)
+	root.SetKey(`settings.labels.2.default`, `none`)
+
+	c := func(args Args, expected string) {
+		t.Helper()
+		testDeepEqual(t, root.GetSettingsWith(args, "settings.labels"), Reply{"value": {expected}})
+		testDeepEqual(t, root.With(args).GetSettings("settings.labels"), Reply{"value": {expected}})
+	}
+	c(Args{"category": 3041, "type": "s"}, "house")
+	c(Args{"category": "3050", "type": "s"}, "house")
+	c(Args{"category": 3042, "type": "s"}, "exact")     // exact beats lists
+	c(Args{"category": 3050, "type": "x"}, "any type")  // list, then star
+	c(Args{"category": 3042, "type": "u"}, "apartment") // list beats star
+	c(Args{"category": 3041, "type": "u"}, "star")      // backtracking
+	c(Args{"category": 3060, "type": "s"}, "any type")  // second list
+	c(Args{"category": "a,b", "type": "s"}, "literal")  // escaped comma
+	c(Args{"category": "a,b", "type": "u"}, "list with literal")
+	c(Args{"category": "c", "type": "u"}, "list with literal")
+	c(Args{"category": "a", "type": "s"}, "none")
+	c(Args{"category": 304, "type": "s"}, "none")
+	c(Args{"category": "3041,3042", "type": "s"}, "none")
+	testTrue(t, root.ValidateSettings("settings.labels") == nil)
+
+	// keys are written back escaped
+	buf := bytes.Buffer{}
+	testError(t, root.GetNode("settings.labels.1").WriteConf(&buf), "")
+	written := buf.String()
+	reloaded := NewRoot()
+	testError(t, reloaded.MergeReader(&buf, true), "")
+	buf.Reset()
+	testError(t, reloaded.WriteConf(&buf), "")
+	testEqualString(t, buf.String(), written)
+}
+
+func TestSettings_WildcardKeys(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.labels.1.keys.1`, `category`)
+	root.SetKey(`settings.labels.1.keys.2`, `*`)
+	root.SetKey(`settings.labels.1.keys.3`, `type`)
+	root.SetKey(`settings.labels.1.1001.*.sale.value`, `house`)
+	root.SetKey(`settings.labels.1.1001.region.sale.value`, `never`)
+	root.SetKey(`settings.labels.1.1001.*.*.value`, `any type`)
+	root.SetKey(`settings.labels.1.*.*.rent.value`, `rent`)
+	root.SetKey(`settings.labels.2.default`, `none`)
+
+	c := func(args Args, expected string) {
+		t.Helper()
+		testDeepEqual(t, root.GetSettingsWith(args, "settings.labels"), Reply{"value": {expected}})
+		testDeepEqual(t, root.With(args).GetSettings("settings.labels"), Reply{"value": {expected}})
+	}
+	c(Args{"category": 1001, "type": "sale"}, "house")
+	c(Args{"category": 1001, "type": "sale", "*": "region"}, "house")
+	c(Args{"category": 1001, "type": "swap"}, "any type")
+	c(Args{"category": 1001}, "any type") // missing keys only match "*"
+	c(Args{"category": 1002, "type": "rent"}, "rent")
+	c(Args{"type": "rent"}, "rent")
+	c(Args{"category": 1002, "type": "sale"}, "none")
+	c(Args{}, "none")
+
+	// a missing key doesn't match a case with a literal "<nil>" key
+	root.SetKey(`settings.labels.1.<nil>.*.sale.value`, `nil`)
+	c(Args{"type": "sale"}, "none")
+}
+
+func TestSettings_Comparisons(t *testing.T) {
+	root := NewRoot()
+	root.SetKey(`settings.checks.1.keys.1`, `price`)
+	root.SetKey(`settings.checks.1.>=10000.value`, `extra verification`)
+	root.SetKey(`settings.checks.1.>=1000.value`, `verification`)
+	root.SetKey(`settings.checks.1.<0.value`, `invalid`)
+	root.SetKey(`settings.checks.1.500.value`, `exact`)
+	root.SetKey(`settings.checks.1.\>5.value`, `literal`)
+	root.SetKey(`settings.checks.2.keys.1`, `ratio`)
+	root.SetKey(`settings.checks.2.<=25e-2.value`, `low ratio`)
+	root.SetKey(`settings.checks.2.>1e0.value`, `high ratio`)
+	root.SetKey(`settings.checks.3.keys.1`, `timeout`)
+	root.SetKey(`settings.checks.3.>1h.value`, `long`)
+	root.SetKey(`settings.checks.3.<30s.value`, `short`)
+	root.SetKey(`settings.checks.3.*.value`, `normal`)
+
+	c := func(key string, value Value, expected string) {
+		t.Helper()
+		testDeepEqual(t, root.GetSettingsWith(Args{key: value}, "settings.checks"), Reply{"value": {expected}})
+	}
+
+	// ints
+	c("price", 20000, "extra verification")
+	c("price", 10000, "extra verification") // first match wins
+	c("price", "9999", "verification")
+	c("price", 5e3, "verification")
+	c("price", 500, "exact") // exact keys first
+	c("price", -1, "invalid")
+	c("price", 10, "normal")
+	c("price", "a lot", "normal")
+	c("price", ">5", "literal")
+	c("price", 6, "normal")
+
+	// floats
+	c("ratio", 0.25, "low ratio")
+	c("ratio", 0.1, "low ratio")
+	c("ratio", 0, "low ratio")
+	c("ratio", 1.5, "high ratio")
+	c("ratio", 2, "high ratio")
+	c("ratio", 0.5, "normal")
+
+	// durations
+	c("timeout", 2*time.Hour, "long")
+	c("timeout", "1d", "long")
+	c("timeout", "90m", "long")
+	c("timeout", "1h", "normal")
+	c("timeout", "10s", "short")
+	c("timeout", 1500*time.Millisecond, "short")
+	c("timeout", "10", "normal")
+}
+
+func FuzzGetSettings(f *testing.F) {
+	f.Add("category", "1001,1002", "label:a\\,b,suffix:x", "1001")
+	f.Add("?pickup", "true", `\`, "")
+	f.Add("", ">=10", "::,,", "25")
+	f.Fuzz(func(t *testing.T, key, caseKey, value, env string) {
+		root := NewRoot()
+		root.SetKey("settings.s.1.keys.1", key)
+		root.SetKey("settings.s.1."+caseKey+".value", value)
+		root.SetKey("settings.s.2.default", value)
+		root.GetSettings("settings.s")
+		root.GetSettingsMerged("settings.*")
+		root.GetSettingsWith(Args{key: env}, "settings.s")
+		root.ValidateSettings("settings.*")
+	})
+}
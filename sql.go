@@ -0,0 +1,58 @@
+package trix
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// Scan implements sql.Scanner, so that a node can be used as a destination
+// for JSON columns: the node's value and children are replaced by the JSON
+// object in src, which can be a []byte or a string. A NULL leaves the node
+// empty.
+func (node *Node) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	default:
+		return fmt.Errorf("Cannot scan %T into a node", src)
+	}
+
+	// start from an empty node
+	node.Value = nil
+	for _, key := range append([]string{}, node.ChildKeys...) {
+		internalUnset(node, []string{key})
+	}
+	if b == nil {
+		return nil
+	}
+	return node.UnmarshalJSON(b)
+}
+
+// sqlValue implements driver.Valuer for a node; it can't be implemented by
+// the node itself, since it has a Value field.
+type sqlValue struct {
+	node *Node
+}
+
+// SQLValue returns a driver.Valuer, to use the node as a query argument for
+// JSON columns: its value is the JSON representation of the node, as
+// returned by MarshalJSON, or NULL if the node is nil.
+func (node *Node) SQLValue() driver.Valuer {
+	return sqlValue{node}
+}
+
+// Value returns the node's JSON representation.
+func (v sqlValue) Value() (driver.Value, error) {
+	if v.node == nil {
+		return nil, nil
+	}
+	b, err := v.node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
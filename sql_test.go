@@ -0,0 +1,39 @@
+package trix
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+func TestSQL(t *testing.T) {
+	// a node can be a scan destination and a query argument
+	var _ sql.Scanner = NewRoot()
+	var _ driver.Valuer = NewRoot().SQLValue()
+
+	node := NewRoot()
+	node.SetKey("old", "value")
+	testError(t, node.Scan([]byte(`{"db":{"host":"localhost","port":5432},"tags":["a","b"]}`)), "")
+	node.SortRecursively()
+	testEqualString(t, node, "{db={host=localhost,port=5432},tags={1=a,2=b}}")
+
+	value, err := node.SQLValue().Value()
+	testError(t, err, "")
+	testDeepEqual(t, string(value.([]byte)), `{"db":{"host":"localhost","port":5432},"tags":["a","b"]}`)
+
+	// round trip through a string
+	copied := NewRoot()
+	testError(t, copied.Scan(string(value.([]byte))), "")
+	testTrue(t, copied.EqualUnordered(node))
+
+	// NULL
+	testError(t, node.Scan(nil), "")
+	testEqualString(t, node, "{}")
+	value, err = (*Node)(nil).SQLValue().Value()
+	testError(t, err, "")
+	testTrue(t, value == nil)
+
+	// errors
+	testError(t, node.Scan(42), "Cannot scan int into a node")
+	testError(t, node.Scan("{"), "unexpected end of JSON input")
+}
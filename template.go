@@ -1,14 +1,34 @@
 package trix
 
 import (
+	"bytes"
+	"fmt"
 	"html/template"
+	"io"
+	"sync"
+	texttemplate "text/template"
+	"time"
 )
 
-// TemplateFuncs returns an map suitable as an argument to template.Funcs.
-// This map contains some useful functions to use trix inside Go templates.
-// The values com from this node.
-func (node *Node) TemplateFuncs() template.FuncMap {
-	return template.FuncMap{
+// renderPrefix is the prefix used for the prefixed functions available to
+// Render and RenderText, in addition to the unprefixed ones.
+const renderPrefix = "t_"
+
+var (
+	// parsed templates, by their text; these are never executed directly,
+	// only clones of them are.
+	htmlTemplateCache sync.Map
+	textTemplateCache sync.Map
+)
+
+// templateFuncs returns the functions shared by TemplateFuncs and
+// TextTemplateFuncs, so that they can't drift apart.
+func (node *Node) templateFuncs() map[string]interface{} {
+	exists := func(keys ...interface{}) bool {
+		_, err := node.TryGetNode(keys...)
+		return err == nil
+	}
+	return map[string]interface{}{
 		"get": func(keys ...interface{}) Value {
 			return node.Get(keys...)
 		},
@@ -24,5 +44,222 @@ func (node *Node) TemplateFuncs() template.FuncMap {
 		"getsettings": func(keys ...interface{}) Reply {
 			return node.GetSettings(keys...)
 		},
+		"getstring": func(keys ...interface{}) string {
+			return node.GetString(keys...)
+		},
+		"getint": func(keys ...interface{}) int {
+			return node.GetInt(keys...)
+		},
+		"getfloat": func(keys ...interface{}) float64 {
+			return node.GetFloat(keys...)
+		},
+		"getbool": func(keys ...interface{}) bool {
+			return node.GetBool(keys...)
+		},
+		"getduration": func(keys ...interface{}) time.Duration {
+			return node.GetDuration(keys...)
+		},
+		"getdurationstr": func(keys ...interface{}) string {
+			return node.GetDuration(keys...).String()
+		},
+		"formatduration": FormatDuration,
+		"gettime": func(keys ...interface{}) time.Time {
+			return node.GetTime(keys...)
+		},
+		"getdefault": func(def Value, keys ...interface{}) Value {
+			return node.GetDefault(def, keys...)
+		},
+		"has":    exists,
+		"exists": exists,
+		"getkeys": func(keys ...interface{}) []string {
+			return node.GetKeys(keys...)
+		},
+		"sortbykey": func(nodes NodeList) NodeList {
+			return nodes.SortByKey()
+		},
+		"sortbyvalue": func(key string, nodes NodeList) NodeList {
+			if key == "" {
+				return nodes.SortByValue()
+			}
+			return nodes.SortByValue(key)
+		},
+		"settings": func(spec interface{}, pairs ...interface{}) (Reply, error) {
+			if len(pairs)%2 != 0 {
+				return nil, fmt.Errorf("settings: odd number of key/value arguments")
+			}
+			args := Args{}
+			for i := 0; i < len(pairs); i += 2 {
+				args[fmt.Sprint(pairs[i])] = pairs[i+1]
+			}
+			return node.With(args).GetSettings(spec), nil
+		},
+	}
+}
+
+// prefixedTemplateFuncs returns the shared functions, with the names prefixed.
+func (node *Node) prefixedTemplateFuncs(prefix string) map[string]interface{} {
+	funcs := map[string]interface{}{}
+	for name, fn := range node.templateFuncs() {
+		funcs[prefix+name] = fn
 	}
+	return funcs
+}
+
+// TemplateFuncs returns an map suitable as an argument to template.Funcs.
+// This map contains some useful functions to use trix inside Go templates.
+// The values com from this node.
+//
+// Like GetDefault, `getdefault` takes the default value as its first argument,
+// and only returns it if the node is not found; a node that exists but has an
+// empty value is considered present. Use `has` (or `exists`) to check whether
+// a node exists in `if` guards.
+//
+// The `sortbykey` and `sortbyvalue` functions take the NodeList as their last
+// argument, so they can be used in pipelines, e.g.
+// `{{ range (getnodes "item.*" | sortbyvalue "price") }}`; an empty key makes
+// `sortbyvalue` use the nodes' own values.
+//
+// The `settings` function evaluates GetSettings on a temporary scope, created
+// using the key/value pairs that follow the spec, e.g.
+// `{{ settings "settings.types" "category" 3041 "type" "s" }}`.
+func (node *Node) TemplateFuncs() template.FuncMap {
+	return template.FuncMap(node.templateFuncs())
+}
+
+// TextTemplateFuncs returns the same functions as TemplateFuncs, as a map
+// suitable for text/template.
+func (node *Node) TextTemplateFuncs() texttemplate.FuncMap {
+	return texttemplate.FuncMap(node.templateFuncs())
+}
+
+// TemplateFuncsPrefixed returns the same functions as TemplateFuncs, but with
+// each name prefixed, e.g. "t_get", "t_getnodes", to avoid collisions with
+// other functions.
+func (node *Node) TemplateFuncsPrefixed(prefix string) template.FuncMap {
+	return template.FuncMap(node.prefixedTemplateFuncs(prefix))
+}
+
+// TextTemplateFuncsPrefixed returns the same functions as TemplateFuncsPrefixed,
+// as a map suitable for text/template.
+func (node *Node) TextTemplateFuncsPrefixed(prefix string) texttemplate.FuncMap {
+	return texttemplate.FuncMap(node.prefixedTemplateFuncs(prefix))
+}
+
+// BaseLang is the language used by translation lookups when a translation is
+// not available in the requested language.
+const BaseLang = "en"
+
+// Translate returns the text stored in "text.<lang>.<key>", falling back
+// to "text.en.<key>" (see BaseLang) and finally to the key itself.
+// Whether the text was found in the requested language is also returned.
+func (node *Node) Translate(lang, key string) (string, bool) {
+	if s, err := node.TryGetString("text", lang, key); err == nil {
+		return s, true
+	} else if s, err := node.TryGetString("text", BaseLang, key); err == nil {
+		return s, false
+	}
+	return key, false
+}
+
+// langTemplateFuncs returns the shared functions, plus `tr`, which translates
+// keys into lang.
+func (node *Node) langTemplateFuncs(lang string, missing func(lang, key string)) map[string]interface{} {
+	funcs := node.templateFuncs()
+	funcs["tr"] = func(key string) string {
+		s, found := node.Translate(lang, key)
+		if !found && missing != nil {
+			missing(lang, key)
+		}
+		return s
+	}
+	return funcs
+}
+
+// TemplateFuncsForLang returns the same functions as TemplateFuncs, plus
+// `tr`, which returns the translation of a key into the specified language
+// (see Translate). If missing is not nil, it's called for each key that
+// isn't translated into the language, so that gaps can be collected.
+func (node *Node) TemplateFuncsForLang(lang string, missing func(lang, key string)) template.FuncMap {
+	return template.FuncMap(node.langTemplateFuncs(lang, missing))
+}
+
+// TextTemplateFuncsForLang returns the same functions as TemplateFuncsForLang,
+// as a map suitable for text/template.
+func (node *Node) TextTemplateFuncsForLang(lang string, missing func(lang, key string)) texttemplate.FuncMap {
+	return texttemplate.FuncMap(node.langTemplateFuncs(lang, missing))
+}
+
+// renderFuncs returns all functions available to Render and RenderText.
+func (node *Node) renderFuncs() map[string]interface{} {
+	funcs := node.templateFuncs()
+	for name, fn := range node.prefixedTemplateFuncs(renderPrefix) {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// Render parses the html template text, using both TemplateFuncs and the
+// TemplateFuncsPrefixed("t_") functions, and executes it with data, writing
+// the output to w. Parsed templates are cached, so rendering the same text
+// again doesn't parse it again.
+func (node *Node) Render(w io.Writer, tmpl string, data interface{}) error {
+	var master *template.Template
+	if cached, found := htmlTemplateCache.Load(tmpl); found {
+		master = cached.(*template.Template)
+	} else {
+		parsed, err := template.New("render").Funcs((*Node)(nil).renderFuncs()).Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("parsing template: %w", err)
+		}
+		cached, _ = htmlTemplateCache.LoadOrStore(tmpl, parsed)
+		master = cached.(*template.Template)
+	}
+
+	t, err := master.Clone()
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	if err := t.Funcs(node.renderFuncs()).Execute(w, data); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	return nil
+}
+
+// RenderString is like Render, but returns the output as a string.
+func (node *Node) RenderString(tmpl string, data interface{}) (string, error) {
+	buf := bytes.Buffer{}
+	err := node.Render(&buf, tmpl, data)
+	return buf.String(), err
+}
+
+// RenderText is like Render, but uses text/template, so the output is not
+// escaped.
+func (node *Node) RenderText(w io.Writer, tmpl string, data interface{}) error {
+	var master *texttemplate.Template
+	if cached, found := textTemplateCache.Load(tmpl); found {
+		master = cached.(*texttemplate.Template)
+	} else {
+		parsed, err := texttemplate.New("render").Funcs((*Node)(nil).renderFuncs()).Parse(tmpl)
+		if err != nil {
+			return fmt.Errorf("parsing template: %w", err)
+		}
+		cached, _ = textTemplateCache.LoadOrStore(tmpl, parsed)
+		master = cached.(*texttemplate.Template)
+	}
+
+	t, err := master.Clone()
+	if err != nil {
+		return fmt.Errorf("parsing template: %w", err)
+	}
+	if err := t.Funcs(node.renderFuncs()).Execute(w, data); err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+	return nil
+}
+
+// RenderTextString is like RenderText, but returns the output as a string.
+func (node *Node) RenderTextString(tmpl string, data interface{}) (string, error) {
+	buf := bytes.Buffer{}
+	err := node.RenderText(&buf, tmpl, data)
+	return buf.String(), err
 }
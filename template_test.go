@@ -0,0 +1,36 @@
+package trix
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	a := FromArgs(Args{"name": "<Alice>", "age": 30})
+	b := FromArgs(Args{"name": "Bob"})
+
+	tmpl := `{{ get "name" }}/{{ t_getstring "name" }}/{{ getint "age" }}/{{ . }}`
+	out, err := a.RenderString(tmpl, "x&y")
+	testError(t, err, "")
+	testEqualString(t, out, `&lt;Alice&gt;/&lt;Alice&gt;/30/x&amp;y`)
+
+	// cached template, different node
+	out, err = b.RenderString(tmpl, "z")
+	testError(t, err, "")
+	testEqualString(t, out, `Bob/Bob/0/z`)
+
+	// text variant doesn't escape
+	out, err = a.RenderTextString(tmpl, "x&y")
+	testError(t, err, "")
+	testEqualString(t, out, `<Alice>/<Alice>/30/x&y`)
+	out, err = b.RenderTextString(tmpl, "z")
+	testError(t, err, "")
+	testEqualString(t, out, `Bob/Bob/0/z`)
+
+	// errors include the position
+	_, err = a.RenderString("ok\n{{ get ", nil)
+	testError(t, err, `parsing template: template: render:2: unclosed action`)
+	_, err = a.RenderTextString("{{ missingfunc }}", nil)
+	testError(t, err, `parsing template: template: render:1: function "missingfunc" not defined`)
+	_, err = a.RenderTextString("ok\n  {{ .Missing }}", 1)
+	testError(t, err, `executing template: template: render:2:5: executing "render" at <.Missing>: can't evaluate field Missing in type int`)
+}
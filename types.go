@@ -3,25 +3,142 @@ package trix
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // StrArgs is a string-string map
 type StrArgs map[string]string
 
+// ArgsToStrArgs returns a new StrArgs, with the values converted to strings
+// like Args.GetString does.
+func ArgsToStrArgs(a Args) StrArgs {
+	result := StrArgs{}
+	for key := range a {
+		result[key] = a.GetString(key)
+	}
+	return result
+}
+
+// Merge the map with another, adding or overwriting keys
+func (args StrArgs) Merge(other StrArgs) StrArgs {
+	for key, value := range other {
+		args[key] = value
+	}
+	return args
+}
+
+// Clone returns a clone of the original one
+func (args StrArgs) Clone() StrArgs {
+	n := StrArgs{}
+	for k, v := range args {
+		n[k] = v
+	}
+	return n
+}
+
+// Add returns a new map, adding or overwriting keys
+func (args StrArgs) Add(other StrArgs) StrArgs {
+	return args.Clone().Merge(other)
+}
+
+// Keys returns the map's keys, sorted
+func (args StrArgs) Keys() []string {
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.StringSlice(keys).Sort()
+	return keys
+}
+
+// ToArgs returns a new Args with the same keys and values
+func (args StrArgs) ToArgs() Args {
+	result := Args{}
+	for k, v := range args {
+		result[k] = v
+	}
+	return result
+}
+
+// String returns a simple string representation of the arguments, with the
+// keys sorted, in the same format as Args.String.
+func (args StrArgs) String() string {
+	return args.ToArgs().String()
+}
+
 // NumericStringSlice represents a string slice that can be sorted using the
-// integer representation of its values
+// integer representation of its values. Integers of any size are compared
+// correctly; strings that aren't integers compare as zero, and strings with
+// the same value, like "7" and "007", are sorted alphabetically.
 type NumericStringSlice []string
 
 // Sort this slice.
-func (s NumericStringSlice) Sort()         { sort.Sort(s) }
+func (s NumericStringSlice) Sort()         { sort.Stable(s) }
 func (s NumericStringSlice) Len() int      { return len(s) }
 func (s NumericStringSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s NumericStringSlice) Less(i, j int) bool {
-	ii, _ := strconv.Atoi(s[i])
-	ij, _ := strconv.Atoi(s[j])
-	return ii < ij
+	if c := compareIntStrings(s[i], s[j]); c != 0 {
+		return c < 0
+	}
+	// e.g. "0" and "00"
+	return s[i] < s[j]
+}
+
+// parseIntString returns whether s is an optionally signed integer, like
+// those accepted by strconv.Atoi but of any size, and its sign and digits,
+// without leading zeros; zero has no digits.
+func parseIntString(s string) (negative bool, digits string, ok bool) {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative, s = s[0] == '-', s[1:]
+	}
+	if s == "" {
+		return false, "", false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false, "", false
+		}
+	}
+	digits = strings.TrimLeft(s, "0")
+	return negative && digits != "", digits, true
+}
+
+// isIntString returns whether s is an integer of any size; see
+// parseIntString.
+func isIntString(s string) bool {
+	_, _, ok := parseIntString(s)
+	return ok
+}
+
+// compareIntStrings compares the integer values of a and b, of any size,
+// returning -1, 0 or 1; strings that aren't integers have the value zero.
+func compareIntStrings(a, b string) int {
+	negA, digitsA, _ := parseIntString(a)
+	negB, digitsB, _ := parseIntString(b)
+	if negA != negB {
+		if negA {
+			return -1
+		}
+		return 1
+	}
+
+	// same sign: compare the magnitudes, by length and then digit by digit
+	c := 0
+	if len(digitsA) != len(digitsB) {
+		if c = 1; len(digitsA) < len(digitsB) {
+			c = -1
+		}
+	} else {
+		c = strings.Compare(digitsA, digitsB)
+	}
+	if negA {
+		return -c
+	}
+	return c
 }
 
 // Args represents a generic string-interface{} map
@@ -60,6 +177,154 @@ func (args Args) GetString(key string) string {
 	return ""
 }
 
+// Has returns whether the map has the specified key, even if its value is nil
+func (args Args) Has(key string) bool {
+	_, found := args[key]
+	return found
+}
+
+// TryGetInt returns the specified key as an int; if it's not found or
+// can't be converted, an error is returned.
+func (args Args) TryGetInt(key string) (int, error) {
+	if v, found := args[key]; !found {
+		return 0, errorKeyNotFound
+	} else if castd, ok := v.(int); ok {
+		return castd, nil
+	} else {
+		return parseInt(v)
+	}
+}
+
+// TryGetFloat returns the specified key as a float64; if it's not found or
+// can't be converted, an error is returned.
+func (args Args) TryGetFloat(key string) (float64, error) {
+	if v, found := args[key]; !found {
+		return 0, errorKeyNotFound
+	} else if castd, ok := v.(float64); ok {
+		return castd, nil
+	} else {
+		return strconv.ParseFloat(fmt.Sprint(v), 64)
+	}
+}
+
+// TryGetBool returns the specified key as a bool; if it's not found or
+// can't be converted, an error is returned.
+func (args Args) TryGetBool(key string) (bool, error) {
+	if v, found := args[key]; !found {
+		return false, errorKeyNotFound
+	} else if castd, ok := v.(bool); ok {
+		return castd, nil
+	} else {
+		return parseBool(v)
+	}
+}
+
+// TryGetDuration returns the specified key as a duration; if it's not found
+// or can't be converted, an error is returned.
+func (args Args) TryGetDuration(key string) (time.Duration, error) {
+	if v, found := args[key]; !found {
+		return 0, errorKeyNotFound
+	} else if castd, ok := v.(time.Duration); ok {
+		return castd, nil
+	} else {
+		return parseDuration(v)
+	}
+}
+
+// TryGetTime returns the specified key as a timestamp; if it's not found or
+// can't be converted, an error is returned.
+func (args Args) TryGetTime(key string) (time.Time, error) {
+	if v, found := args[key]; !found {
+		return time.Time{}, errorKeyNotFound
+	} else if castd, ok := v.(time.Time); ok {
+		return castd, nil
+	} else {
+		return parseTime(v)
+	}
+}
+
+// GetInt returns the specified key as an int
+func (args Args) GetInt(key string) int {
+	v, _ := args.TryGetInt(key)
+	return v
+}
+
+// GetFloat returns the specified key as a float64
+func (args Args) GetFloat(key string) float64 {
+	v, _ := args.TryGetFloat(key)
+	return v
+}
+
+// GetBool returns the specified key as a bool
+func (args Args) GetBool(key string) bool {
+	v, _ := args.TryGetBool(key)
+	return v
+}
+
+// GetDuration returns the specified key as a duration
+func (args Args) GetDuration(key string) time.Duration {
+	v, _ := args.TryGetDuration(key)
+	return v
+}
+
+// GetTime returns the specified key as a timestamp
+func (args Args) GetTime(key string) time.Time {
+	v, _ := args.TryGetTime(key)
+	return v
+}
+
+// Equal returns whether both maps have the same keys, with deeply-equal values.
+// Values of different types are considered different, even if they have the
+// same string representation (see EqualStrings).
+func (args Args) Equal(other Args) bool {
+	if len(args) != len(other) {
+		return false
+	}
+	for key, value := range args {
+		if otherValue, found := other[key]; !found || !reflect.DeepEqual(value, otherValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualStrings returns whether both maps have the same keys, with values that
+// have the same string representation (as returned by GetString).
+func (args Args) EqualStrings(other Args) bool {
+	if len(args) != len(other) {
+		return false
+	}
+	for key := range args {
+		if !other.Has(key) || args.GetString(key) != other.GetString(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the sorted lists of keys that were added (present only in
+// other), removed (present only in the original) and changed (with values
+// that aren't deeply equal).
+func (args Args) Diff(other Args) (added, removed, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	for key, value := range args {
+		if otherValue, found := other[key]; !found {
+			removed = append(removed, key)
+		} else if !reflect.DeepEqual(value, otherValue) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range other {
+		if !args.Has(key) {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return
+}
+
 // String returns a simple string representation of the arguments, with the
 // keys sorted. This is mainly convenient for testing.
 func (args Args) String() string {
@@ -1,14 +1,47 @@
 package trix
 
 import (
+	"encoding/json"
+	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestNumericStringSlice(t *testing.T) {
 	s := NumericStringSlice{"a", "0", "a1", "lol", "3", "99", "00", "03", "000"}
 	s.Sort()
-	// "0", "00", "a" or any other non-numeric string are considered equal
-	testEqualString(t, s, "[a 0 a1 lol 00 000 3 03 99]")
+	// "0", "00", "a" or any other non-numeric string have the same value,
+	// and are sorted alphabetically
+	testEqualString(t, s, "[0 00 000 a a1 lol 03 3 99]")
+}
+
+func TestNumericStringSlice_Big(t *testing.T) {
+	big := "1000000000000000000000000" // 25 digits
+	s := NumericStringSlice{big, "9999999999999999999999999", "007", "7", "-12", "07", "99999999999999999999", "8", "-3", "+5"}
+	for i := 0; i < 10; i++ {
+		rand.Shuffle(len(s), s.Swap)
+		s.Sort()
+		testEqualString(t, s, "[-12 -3 +5 007 07 7 8 99999999999999999999 1000000000000000000000000 9999999999999999999999999]")
+	}
+
+	// big keys are still numeric when sorting nodes, and serialised as arrays
+	root := NewRoot()
+	for _, key := range []string{big, "20", "0003", "3", "100000000000000000000"} {
+		root.SetKey(key, key)
+	}
+	root.Sort()
+	testDeepEqual(t, root.ChildKeys, []string{"0003", "3", "20", "100000000000000000000", big})
+	byt, err := json.Marshal(root)
+	testError(t, err, "")
+	testEqualString(t, string(byt), `["0003","3","20","100000000000000000000","`+big+`"]`)
+
+	// and when inserting into kept-sorted nodes
+	root = NewRoot()
+	root.Flags |= KeepSorted
+	for _, key := range []string{big, "20", "3", "0003", "100000000000000000000"} {
+		root.SetKey(key, key)
+	}
+	testDeepEqual(t, root.ChildKeys, []string{"0003", "3", "20", "100000000000000000000", big})
 }
 
 func TestArgs(t *testing.T) {
@@ -36,3 +69,90 @@ func TestArgs(t *testing.T) {
 	testEqualString(t, f.GetString("bool"), "true")
 	testEqualString(t, f.GetString("str"), "a")
 }
+
+func TestArgs_Getters(t *testing.T) {
+	when := time.Date(1979, 12, 7, 0, 0, 0, 0, time.UTC)
+	native := Args{"int": 1, "float": 3.14, "bool": true, "duration": time.Hour, "time": when, "nil": nil}
+	str := Args{"int": "1", "float": "3.14", "bool": "on", "duration": "1h", "time": "1979-12-07"}
+
+	for _, args := range []Args{native, str} {
+		testDeepEqual(t, args.GetInt("int"), 1)
+		testDeepEqual(t, args.GetFloat("float"), 3.14)
+		testDeepEqual(t, args.GetFloat("int"), 1.0)
+		testDeepEqual(t, args.GetBool("bool"), true)
+		testDeepEqual(t, args.GetDuration("duration"), time.Hour)
+		testDeepEqual(t, args.GetTime("time"), when)
+	}
+
+	testTrue(t, native.Has("nil"))
+	testTrue(t, !native.Has("missing"))
+	testDeepEqual(t, native.GetInt("missing"), 0)
+	testDeepEqual(t, native.GetInt("nil"), 0)
+
+	_, err := native.TryGetInt("missing")
+	testError(t, err, "key not found")
+	_, err = native.TryGetInt("bool")
+	testError(t, err, `strconv.ParseInt: parsing "true": invalid syntax`)
+	_, err = native.TryGetFloat("bool")
+	testError(t, err, `strconv.ParseFloat: parsing "true": invalid syntax`)
+	_, err = str.TryGetBool("int")
+	testError(t, err, "")
+	_, err = str.TryGetBool("float")
+	testError(t, err, "bad value")
+	_, err = str.TryGetDuration("float")
+	testError(t, err, "bad duration")
+	_, err = str.TryGetTime("float")
+	testError(t, err, "Bad time format: 3.14")
+}
+
+func TestArgs_Equal(t *testing.T) {
+	a := Args{"int": 1, "str": "x", "nil": nil, "slice": []string{"a", "b"}}
+	testTrue(t, a.Equal(a.Clone()))
+	testTrue(t, a.EqualStrings(a.Clone()))
+	testTrue(t, Args(nil).Equal(Args{}))
+	testTrue(t, Args{}.EqualStrings(nil))
+	testTrue(t, !Args(nil).Equal(Args{"nil": nil})) // nil value != missing key
+
+	b := Args{"int": "1", "str": "x", "nil": nil, "slice": []string{"a", "b"}}
+	testTrue(t, !a.Equal(b))
+	testTrue(t, a.EqualStrings(b))
+
+	c := Args{"int": 1, "str": "x", "nil": nil, "slice": []string{"a", "c"}}
+	testTrue(t, !a.Equal(c))
+	testTrue(t, !a.EqualStrings(c))
+	testTrue(t, !a.EqualStrings(Args{"int": 1}))
+
+	added, removed, changed := a.Diff(Args{"int": "1", "str": "x", "slice": []string{"a"}, "new": 1, "other": nil})
+	testDeepEqual(t, added, []string{"new", "other"})
+	testDeepEqual(t, removed, []string{"nil"})
+	testDeepEqual(t, changed, []string{"int", "slice"})
+
+	added, removed, changed = Args(nil).Diff(nil)
+	testDeepEqual(t, added, []string{})
+	testDeepEqual(t, removed, []string{})
+	testDeepEqual(t, changed, []string{})
+}
+
+func TestStrArgs(t *testing.T) {
+	a := StrArgs{"a": "1"}
+	b := StrArgs{"b": "2"}
+	testEqualString(t, a, `args[a:1]`)
+	testEqualString(t, b, `args[b:2]`)
+
+	c := a.Clone()
+	a.Merge(b)
+	testEqualString(t, a, `args[a:1 b:2]`)
+	testEqualString(t, c, `args[a:1]`) // the clone is unchanged
+	testEqualString(t, b, `args[b:2]`)
+
+	d := StrArgs{"d": "4"}
+	e := d.Add(a)
+	testEqualString(t, a, `args[a:1 b:2]`)
+	testEqualString(t, d, `args[d:4]`) // d is unchanged
+	testEqualString(t, e, `args[a:1 b:2 d:4]`)
+	testDeepEqual(t, e.Keys(), []string{"a", "b", "d"})
+	testDeepEqual(t, StrArgs{}.Keys(), []string{})
+
+	testDeepEqual(t, e.ToArgs(), Args{"a": "1", "b": "2", "d": "4"})
+	testDeepEqual(t, ArgsToStrArgs(Args{"int": 1, "bool": true, "str": "a"}), StrArgs{"int": "1", "bool": "true", "str": "a"})
+}
@@ -3,15 +3,47 @@ package trix
 import (
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// exactKey is a key used as a single element; see Key.
+type exactKey string
+
+// Key wraps v so that, when passed to getters and setters that accept
+// multiple keys, its string representation is used as a single element, even
+// if it has dots, e.g. `node.Get("versions", trix.Key(3.5))` returns the value
+// of the "3.5" child of "versions".
+func Key(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return exactKey(s)
+	}
+	return exactKey(fmt.Sprint(v))
+}
+
+// exactKeys returns the keys as a spec, with each of them as a single
+// element; see Key.
+func exactKeys(keys []string) []interface{} {
+	spec := make([]interface{}, len(keys))
+	for i, key := range keys {
+		spec[i] = exactKey(key)
+	}
+	return spec
+}
+
 // ParseKeys converts a slice of interfaces into a slice of strings; string
-// items can also include more than one dot-separated element.
+// items can also include more than one dot-separated element. Empty elements,
+// from leading, trailing or doubled dots (e.g. "a..b."), are ignored. For
+// historical reasons, other values are also split, so 3.5 is split into "3"
+// and "5"; use Key to keep a value as a single element.
 func ParseKeys(keys []interface{}) []string {
 	spec := make([]string, 0, len(keys))
 	for _, key := range keys {
 		var strPart string
 		switch key.(type) {
+		case exactKey:
+			spec = append(spec, string(key.(exactKey)))
+			continue
 		case string:
 			strPart = key.(string)
 		default:
@@ -19,52 +51,84 @@ func ParseKeys(keys []interface{}) []string {
 		}
 
 		for _, subkey := range strings.Split(strPart, ".") {
-			spec = append(spec, subkey)
+			if subkey != "" {
+				spec = append(spec, subkey)
+			}
 		}
 	}
 	return spec
 }
 
-// indexEsc returns the index of the first instance of substr in s that isn't preceded by escape, or -1 if substr is not present in s.
-func indexEsc(s, substr, escape string) int {
-	totalOffset := 0
-	for {
-		index := strings.Index(s, substr)
-		if index == -1 {
-			return index
+// checkKey returns an error if the dot-separated key has empty elements.
+func checkKey(key string) error {
+	for _, subkey := range strings.Split(key, ".") {
+		if subkey == "" {
+			return fmt.Errorf(`Bad key "%s": empty element`, key)
 		}
-		if escape == "" || index < len(escape) || s[index-len(escape):index] != escape {
-			return totalOffset + index
+	}
+	return nil
+}
+
+// MaxSpecLength is the maximum length of a spec accepted by SanitizeSpec, in
+// bytes.
+const MaxSpecLength = 1024
+
+// SanitizeSpec validates a user-supplied spec, e.g. from an HTTP parameter,
+// before it's passed to the getters: it must be valid UTF-8, at most
+// MaxSpecLength bytes long, with no empty elements (e.g. "a..b"), no control
+// characters or backslashes, and "*" can only be used as a whole element.
+// Return the spec, or an error if it's not valid.
+func SanitizeSpec(s string) (string, error) {
+	if len(s) > MaxSpecLength {
+		return "", fmt.Errorf("Bad spec: longer than %d bytes", MaxSpecLength)
+	} else if !utf8.ValidString(s) {
+		return "", fmt.Errorf("Bad spec %q: invalid UTF-8", s)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) || r == '\\' {
+			return "", fmt.Errorf("Bad spec %q: invalid character %q", s, r)
+		}
+	}
+	for _, subkey := range strings.Split(s, ".") {
+		if subkey == "" {
+			return "", fmt.Errorf("Bad spec %q: empty element", s)
+		} else if subkey != "*" && strings.Contains(subkey, "*") {
+			return "", fmt.Errorf("Bad spec %q: \"*\" must be a whole element", s)
 		}
-		// this substring is escaped; try the next one
-		offset := index + len(substr)
-		s = s[offset:]
-		totalOffset += offset
 	}
+	return s, nil
 }
 
-// splitNEsc slices s into at most n substrings, separated by sep (not preceded
-// by escape) and returns a slice of the substrings between those separators.
+// splitNEsc slices s into at most n substrings, separated by sep, and returns
+// a slice of the substrings between those separators; if n is -1, there's no
+// limit. Within s, escape followed by sep is a literal sep, and escape
+// followed by escape is a literal escape; any other escape, including a
+// trailing one, is kept as is. See joinEsc.
 func splitNEsc(s, sep, escape string, n int) []string {
-	escapedSep := escape + sep
 	parts := []string{}
-	unlimited := n == -1
 	if n == 0 {
 		return parts
 	}
 
-	for (unlimited || n > 1) && s != "" {
-		sepIndex := indexEsc(s, sep, escape)
-		if sepIndex < 0 {
-			break
+	var part strings.Builder
+	for i := 0; i < len(s); {
+		switch rest := s[i:]; {
+		case escape != "" && strings.HasPrefix(rest, escape+escape):
+			part.WriteString(escape)
+			i += 2 * len(escape)
+		case escape != "" && strings.HasPrefix(rest, escape+sep):
+			part.WriteString(sep)
+			i += len(escape) + len(sep)
+		case strings.HasPrefix(rest, sep) && (n < 0 || len(parts) < n-1):
+			parts = append(parts, part.String())
+			part.Reset()
+			i += len(sep)
+		default:
+			part.WriteByte(s[i])
+			i++
 		}
-		parts = append(parts, strings.Replace(s[:sepIndex], escapedSep, sep, -1))
-		s = s[sepIndex+len(sep):]
-
-		n--
 	}
-	parts = append(parts, strings.Replace(s, escapedSep, sep, -1))
-	return parts
+	return append(parts, part.String())
 }
 
 // splitEsc slices s into all substrings separated by sep (not preceded by escape)
@@ -72,3 +136,52 @@ func splitNEsc(s, sep, escape string, n int) []string {
 func splitEsc(s, sep, escape string) []string {
 	return splitNEsc(s, sep, escape, -1)
 }
+
+// joinEsc is the inverse of splitEsc: it joins the parts with sep, escaping
+// the escape strings and separators within them.
+func joinEsc(parts []string, sep, escape string) string {
+	if escape == "" {
+		return strings.Join(parts, sep)
+	}
+	replacer := strings.NewReplacer(escape, escape+escape, sep, escape+sep)
+	escaped := make([]string, len(parts))
+	for i, part := range parts {
+		escaped[i] = replacer.Replace(part)
+	}
+	return strings.Join(escaped, sep)
+}
+
+// pathEscaper escapes the characters with special meaning in a path string.
+var pathEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`)
+
+// joinPath joins the keys with dots, escaping dots and backslashes within
+// them, so that the result can be split back by splitPath.
+func joinPath(keys []string) string {
+	escaped := make([]string, len(keys))
+	for i, key := range keys {
+		escaped[i] = pathEscaper.Replace(key)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// splitPath is the inverse of joinPath. An empty string returns no keys.
+func splitPath(s string) []string {
+	keys := []string{}
+	if s == "" {
+		return keys
+	}
+	var key strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			key.WriteByte(s[i])
+		case c == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return append(keys, key.String())
+}
@@ -0,0 +1,110 @@
+package trix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitEsc(t *testing.T) {
+	c := func(s string, n int, expected ...string) {
+		t.Helper()
+		testDeepEqual(t, splitNEsc(s, ",", `\`, n), expected)
+	}
+	c(``, -1, ``)
+	c(`a,b`, -1, `a`, `b`)
+	c(`a,b,`, -1, `a`, `b`, ``)
+	c(`a\,b,c`, -1, `a,b`, `c`)
+	c(`C:\dir\\,b`, -1, `C:\dir\`, `b`) // escaped escape before a separator
+	c(`a\\\,b`, -1, `a\,b`)             // escaped escape and separator
+	c(`a\b\`, -1, `a\b\`)               // other and trailing escapes are literal
+	c(`a,b\,c,d`, 2, `a`, `b,c,d`)      // limited
+	c(`a,b`, 1, `a,b`)                  // no splits
+	testDeepEqual(t, splitNEsc(`a,b`, ",", `\`, 0), []string{})
+	testDeepEqual(t, splitNEsc(`a::b::c`, "::", `\`, -1), []string{"a", "b", "c"})
+	testDeepEqual(t, splitNEsc(`a\::b::c`, "::", `\`, -1), []string{"a::b", "c"})
+	testDeepEqual(t, splitNEsc(`a\,b`, ",", "", -1), []string{`a\`, "b"})
+
+	testDeepEqual(t, joinEsc([]string{`C:\dir\`, `a,b`, ``}, ",", `\`), `C:\\dir\\,a\,b,`)
+	testDeepEqual(t, joinEsc([]string{`a`, `b`}, ",", ""), `a,b`)
+}
+
+func FuzzJoinEsc(f *testing.F) {
+	f.Add(`a`, `b`, `c`)
+	f.Add(`C:\dir\`, `a,b`, ``)
+	f.Add(`\`, `\\,`, `,\`)
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		parts := []string{a, b, c}
+		joined := joinEsc(parts, ",", `\`)
+		testDeepEqual(t, splitEsc(joined, ",", `\`), parts)
+		testDeepEqual(t, splitNEsc(joined, ",", `\`, 2)[0], a)
+	})
+}
+
+func FuzzSplitNEsc(f *testing.F) {
+	f.Add(`a\,b,c`, 2)
+	f.Add(`\\\`, -1)
+	f.Fuzz(func(t *testing.T, s string, n int) {
+		if n < -1 || n > 10 {
+			n = -1
+		}
+		parts := splitNEsc(s, ",", `\`, n)
+		if n >= 0 {
+			testTrue(t, len(parts) <= n)
+		}
+		testTrue(t, len(strings.Join(parts, "")) <= len(s))
+	})
+}
+
+func FuzzParseKeys(f *testing.F) {
+	f.Add("a.b.c", "..x..", 3.5)
+	f.Fuzz(func(t *testing.T, a, b string, c float64) {
+		keys := ParseKeys([]interface{}{a, Key(b), c})
+		for i, key := range keys {
+			if i == len(ParseKeys([]interface{}{a})) {
+				testDeepEqual(t, key, b) // kept as a single element
+				continue
+			}
+			testTrue(t, key != "" && !strings.Contains(key, "."))
+		}
+	})
+}
+
+func TestSanitizeSpec(t *testing.T) {
+	for _, spec := range []string{"a", "a.b.c", "*", "a.*.c", "é.ü"} {
+		sanitized, err := SanitizeSpec(spec)
+		testError(t, err, "")
+		testDeepEqual(t, sanitized, spec)
+	}
+	c := func(spec, expected string) {
+		t.Helper()
+		_, err := SanitizeSpec(spec)
+		testError(t, err, expected)
+	}
+	c("", `Bad spec "": empty element`)
+	c("a..b", `Bad spec "a..b": empty element`)
+	c(".a", `Bad spec ".a": empty element`)
+	c("a.b*", `Bad spec "a.b*": "*" must be a whole element`)
+	c("a.**", `Bad spec "a.**": "*" must be a whole element`)
+	c(`a\.b`, `Bad spec "a\\.b": invalid character '\\'`)
+	c("a\nb", `Bad spec "a\nb": invalid character '\n'`)
+	c("a\xff", `Bad spec "a\xff": invalid UTF-8`)
+	c(strings.Repeat("a.", MaxSpecLength), "Bad spec: longer than 1024 bytes")
+}
+
+func FuzzSanitizeSpec(f *testing.F) {
+	f.Add("a.*.c")
+	f.Add("a..b")
+	f.Fuzz(func(t *testing.T, spec string) {
+		sanitized, err := SanitizeSpec(spec)
+		if err != nil {
+			return
+		}
+		// every element is kept as is
+		testDeepEqual(t, ParseKeys([]interface{}{sanitized}), strings.Split(spec, "."))
+
+		root := NewRoot()
+		root.SetKey("a.b.c", 1)
+		root.GetNodes(sanitized)
+		root.Get(sanitized)
+	})
+}
@@ -0,0 +1,508 @@
+package trix
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// ReadOnly is a read-only view of a node, as returned by View, that can be
+// shared with code that shouldn't change the tree. Nodes returned by its
+// methods are views too, so the underlying nodes can't be reached. Note that
+// values are returned as is, so slices and maps stored as values are shared.
+type ReadOnly interface {
+	// Key returns the node's key.
+	Key() string
+
+	// Value returns the node's value.
+	Value() Value
+
+	// Path returns the node's path, as returned by Node.Path.
+	Path() []string
+
+	// Children returns views of the node's children, in order.
+	Children() []ReadOnly
+
+	// Parent returns a view of the node's parent, or nil if it's a root.
+	Parent() ReadOnly
+
+	GetNode(keys ...interface{}) ReadOnly
+	TryGetNode(keys ...interface{}) (ReadOnly, error)
+	GetNodes(keys ...interface{}) []ReadOnly
+
+	Get(keys ...interface{}) Value
+	TryGet(keys ...interface{}) (Value, error)
+	GetDefault(def Value, keys ...interface{}) Value
+	GetString(keys ...interface{}) string
+	TryGetString(keys ...interface{}) (string, error)
+	TryGetStringNonEmpty(keys ...interface{}) (string, error)
+	GetStringDefault(def string, keys ...interface{}) string
+	GetInt(keys ...interface{}) int
+	TryGetInt(keys ...interface{}) (int, error)
+	GetIntDefault(def int, keys ...interface{}) int
+	GetUint(keys ...interface{}) uint64
+	TryGetUint(keys ...interface{}) (uint64, error)
+	GetUintDefault(def uint64, keys ...interface{}) uint64
+	GetSize(keys ...interface{}) int64
+	TryGetSize(keys ...interface{}) (int64, error)
+	GetSizeDefault(def int64, keys ...interface{}) int64
+	GetFloat(keys ...interface{}) float64
+	TryGetFloat(keys ...interface{}) (float64, error)
+	GetFloatDefault(def float64, keys ...interface{}) float64
+	GetFloat32(keys ...interface{}) float32
+	TryGetFloat32(keys ...interface{}) (float32, error)
+	GetFloat32Default(def float32, keys ...interface{}) float32
+	GetBool(keys ...interface{}) bool
+	TryGetBool(keys ...interface{}) (bool, error)
+	GetBoolDefault(def bool, keys ...interface{}) bool
+	GetDuration(keys ...interface{}) time.Duration
+	TryGetDuration(keys ...interface{}) (time.Duration, error)
+	GetDurationDefault(def time.Duration, keys ...interface{}) time.Duration
+	GetTime(keys ...interface{}) time.Time
+	TryGetTime(keys ...interface{}) (time.Time, error)
+	GetTimeDefault(def time.Time, keys ...interface{}) time.Time
+	GetURL(keys ...interface{}) *url.URL
+	TryGetURL(keys ...interface{}) (*url.URL, error)
+	GetURLDefault(def *url.URL, keys ...interface{}) *url.URL
+	GetRelativeURL(keys ...interface{}) *url.URL
+	GetIP(keys ...interface{}) net.IP
+	TryGetIP(keys ...interface{}) (net.IP, error)
+	GetIPDefault(def net.IP, keys ...interface{}) net.IP
+	GetCIDR(keys ...interface{}) *net.IPNet
+	TryGetCIDR(keys ...interface{}) (*net.IPNet, error)
+	GetCIDRDefault(def *net.IPNet, keys ...interface{}) *net.IPNet
+	GetRegexp(keys ...interface{}) *regexp.Regexp
+	TryGetRegexp(keys ...interface{}) (*regexp.Regexp, error)
+	GetBytes(keys ...interface{}) []byte
+	TryGetBytes(keys ...interface{}) ([]byte, error)
+	GetJSON(target interface{}, keys ...interface{}) bool
+	TryGetJSON(target interface{}, keys ...interface{}) error
+	TryGetFirst(paths ...interface{}) (Value, error)
+	GetStringFirst(paths ...interface{}) string
+	GetIntFirst(paths ...interface{}) int
+	TryGetEnum(allowed []string, keys ...interface{}) (string, error)
+	TryGetEnumWith(opts EnumOptions, keys ...interface{}) (string, error)
+	GetEnumDefault(def string, allowed []string, keys ...interface{}) string
+	GetEnumDefaultWith(def string, opts EnumOptions, keys ...interface{}) string
+	GetInto(target interface{}, keys ...interface{}) error
+
+	GetStringSlice(keys ...interface{}) []string
+	TryGetStringSlice(keys ...interface{}) ([]string, error)
+	GetStringSliceDefault(def []string, keys ...interface{}) []string
+	GetIntSlice(keys ...interface{}) []int
+	TryGetIntSlice(keys ...interface{}) ([]int, error)
+	GetIntSliceDefault(def []int, keys ...interface{}) []int
+	GetFloatSlice(keys ...interface{}) []float64
+	TryGetFloatSlice(keys ...interface{}) ([]float64, error)
+	GetFloatSliceDefault(def []float64, keys ...interface{}) []float64
+	GetBoolSlice(keys ...interface{}) []bool
+	TryGetBoolSlice(keys ...interface{}) ([]bool, error)
+	GetBoolSliceDefault(def []bool, keys ...interface{}) []bool
+	GetDurationSlice(keys ...interface{}) []time.Duration
+	TryGetDurationSlice(keys ...interface{}) ([]time.Duration, error)
+	GetDurationSliceDefault(def []time.Duration, keys ...interface{}) []time.Duration
+	GetTimeSlice(keys ...interface{}) []time.Time
+	TryGetTimeSlice(keys ...interface{}) ([]time.Time, error)
+	GetIPSlice(keys ...interface{}) []net.IP
+	TryGetIPSlice(keys ...interface{}) ([]net.IP, error)
+
+	GetValues(keys ...interface{}) []Value
+	GetStringValues(keys ...interface{}) []string
+	GetMap(keys ...interface{}) Args
+	GetStringMap(keys ...interface{}) StrArgs
+	GetKeys(keys ...interface{}) []string
+	GetSettings(keys ...interface{}) Reply
+
+	MarshalJSON() ([]byte, error)
+	String() string
+}
+
+// View returns a read-only view of the node, or nil if the node is nil.
+func (node *Node) View() ReadOnly {
+	if node == nil {
+		return nil
+	}
+	return view{node: node}
+}
+
+// Scope is a view of a branch of a tree, as returned by Scoped, which can
+// also set values under it.
+type Scope interface {
+	ReadOnly
+
+	// SetKey sets a node under the branch, creating the branch if necessary,
+	// like Node.SetKey does.
+	SetKey(key string, value Value) *Node
+}
+
+// Scoped returns a view of the node's descendant with the specified keys, to
+// hand a component its own branch: getters on it resolve relative to it, and,
+// as with any node, also look for the same path on parent scopes, so
+// inherited values are still found, but never outside the branch. The branch
+// doesn't need to exist, and is looked up each time, so values set later,
+// on the node's scope or on parent ones, are also found; it's only created
+// when SetKey writes under it. Nodes returned by the view's methods are views
+// of the branch too. Return nil if the node is nil.
+func (node *Node) Scoped(keys ...interface{}) Scope {
+	if node == nil {
+		return nil
+	}
+	path := ParseKeys(keys)
+	return scope{view{node: node, scoped: true, path: path, branch: len(path)}}
+}
+
+// view implements ReadOnly.
+type view struct {
+	node *Node
+
+	// scoped is set on the views of a scope's branch, and of nodes under it,
+	// which are looked up at path, relative to node, each time; the first
+	// branch elements of path are the branch.
+	scoped bool
+	path   []string
+	branch int
+}
+
+// scope implements Scope.
+type scope struct{ view }
+
+func (s scope) SetKey(key string, value Value) *Node {
+	keys := append(append([]string{}, s.path...), ParseKeys([]interface{}{key})...)
+	return internalSet(s.node, keys, value)
+}
+
+// keys returns the spec, relative to the view, as a spec relative to its
+// node.
+func (v view) keys(keys []interface{}) []interface{} {
+	if !v.scoped {
+		return keys
+	}
+	return append(exactKeys(v.path), keys...)
+}
+
+// paths is like keys, for the paths of the First getters.
+func (v view) paths(paths []interface{}) []interface{} {
+	if !v.scoped {
+		return paths
+	}
+	specs := make([]interface{}, len(paths))
+	for i, path := range paths {
+		keys, ok := path.([]interface{})
+		if !ok {
+			keys = []interface{}{path}
+		}
+		specs[i] = v.keys(keys)
+	}
+	return specs
+}
+
+// target returns the viewed node, or nil if it's a scope's node that doesn't
+// exist, on its scope or on parent ones.
+func (v view) target() *Node {
+	if !v.scoped {
+		return v.node
+	}
+	var found *Node
+	internalMatch(v.node, v.path, func(_ []string, n *Node) bool {
+		found = n
+		return false
+	})
+	return found
+}
+
+// at returns a view of the node found by one of the view's lookups, which
+// is also a view of a scope's branch if the node is under it.
+func (v view) at(found *Node) view {
+	if !v.scoped {
+		return view{node: found}
+	}
+	base, path := v.node.Path(), found.Path()
+	if len(path) < len(base)+v.branch {
+		return view{node: found}
+	}
+	for i, key := range append(base, v.path[:v.branch]...) {
+		if path[i] != key {
+			return view{node: found}
+		}
+	}
+	return view{node: v.node, scoped: true, path: path[len(base):], branch: v.branch}
+}
+
+// child returns a view of the child with the key of the viewed node, n.
+func (v view) child(n *Node, key string) view {
+	if !v.scoped {
+		return view{node: n.Children[key]}
+	}
+	path := append(append([]string{}, v.path...), key)
+	return view{node: v.node, scoped: true, path: path, branch: v.branch}
+}
+
+func (v view) Key() string {
+	if v.scoped && len(v.path) > 0 {
+		return v.path[len(v.path)-1]
+	}
+	return v.node.Key
+}
+
+func (v view) Value() Value {
+	if n := v.target(); n != nil {
+		return n.Value
+	}
+	return nil
+}
+
+func (v view) Path() []string {
+	if v.scoped {
+		return append(v.node.Path(), v.path...)
+	}
+	return v.node.Path()
+}
+
+func (v view) Children() []ReadOnly {
+	n := v.target()
+	if n == nil {
+		return []ReadOnly{}
+	}
+	children := make([]ReadOnly, len(n.ChildKeys))
+	for i, key := range n.ChildKeys {
+		children[i] = v.child(n, key)
+	}
+	return children
+}
+
+func (v view) Parent() ReadOnly {
+	if v.scoped {
+		if len(v.path) <= v.branch {
+			return nil
+		}
+		return view{node: v.node, scoped: true, path: v.path[:len(v.path)-1], branch: v.branch}
+	} else if v.node.Flags&IsRoot != 0 {
+		return nil
+	}
+	return v.node.Parent.View()
+}
+
+func (v view) GetNode(keys ...interface{}) ReadOnly {
+	if found := v.node.GetNode(v.keys(keys)...); found != nil {
+		return v.at(found)
+	}
+	return nil
+}
+
+func (v view) TryGetNode(keys ...interface{}) (ReadOnly, error) {
+	found, err := v.node.TryGetNode(v.keys(keys)...)
+	if err != nil {
+		return nil, err
+	}
+	return v.at(found), nil
+}
+
+func (v view) GetNodes(keys ...interface{}) []ReadOnly {
+	found := v.node.GetNodes(v.keys(keys)...)
+	views := make([]ReadOnly, len(found))
+	for i, n := range found {
+		views[i] = v.at(n)
+	}
+	return views
+}
+
+func (v view) Get(keys ...interface{}) Value             { return v.node.Get(v.keys(keys)...) }
+func (v view) TryGet(keys ...interface{}) (Value, error) { return v.node.TryGet(v.keys(keys)...) }
+func (v view) GetDefault(def Value, keys ...interface{}) Value {
+	return v.node.GetDefault(def, v.keys(keys)...)
+}
+func (v view) GetString(keys ...interface{}) string { return v.node.GetString(v.keys(keys)...) }
+func (v view) TryGetString(keys ...interface{}) (string, error) {
+	return v.node.TryGetString(v.keys(keys)...)
+}
+func (v view) TryGetStringNonEmpty(keys ...interface{}) (string, error) {
+	return v.node.TryGetStringNonEmpty(v.keys(keys)...)
+}
+func (v view) GetStringDefault(def string, keys ...interface{}) string {
+	return v.node.GetStringDefault(def, v.keys(keys)...)
+}
+func (v view) GetInt(keys ...interface{}) int             { return v.node.GetInt(v.keys(keys)...) }
+func (v view) TryGetInt(keys ...interface{}) (int, error) { return v.node.TryGetInt(v.keys(keys)...) }
+func (v view) GetIntDefault(def int, keys ...interface{}) int {
+	return v.node.GetIntDefault(def, v.keys(keys)...)
+}
+func (v view) GetUint(keys ...interface{}) uint64 { return v.node.GetUint(v.keys(keys)...) }
+func (v view) TryGetUint(keys ...interface{}) (uint64, error) {
+	return v.node.TryGetUint(v.keys(keys)...)
+}
+func (v view) GetUintDefault(def uint64, keys ...interface{}) uint64 {
+	return v.node.GetUintDefault(def, v.keys(keys)...)
+}
+func (v view) GetSize(keys ...interface{}) int64 { return v.node.GetSize(v.keys(keys)...) }
+func (v view) TryGetSize(keys ...interface{}) (int64, error) {
+	return v.node.TryGetSize(v.keys(keys)...)
+}
+func (v view) GetSizeDefault(def int64, keys ...interface{}) int64 {
+	return v.node.GetSizeDefault(def, v.keys(keys)...)
+}
+func (v view) GetFloat(keys ...interface{}) float64 { return v.node.GetFloat(v.keys(keys)...) }
+func (v view) TryGetFloat(keys ...interface{}) (float64, error) {
+	return v.node.TryGetFloat(v.keys(keys)...)
+}
+func (v view) GetFloatDefault(def float64, keys ...interface{}) float64 {
+	return v.node.GetFloatDefault(def, v.keys(keys)...)
+}
+func (v view) GetFloat32(keys ...interface{}) float32 { return v.node.GetFloat32(v.keys(keys)...) }
+func (v view) TryGetFloat32(keys ...interface{}) (float32, error) {
+	return v.node.TryGetFloat32(v.keys(keys)...)
+}
+func (v view) GetFloat32Default(def float32, keys ...interface{}) float32 {
+	return v.node.GetFloat32Default(def, v.keys(keys)...)
+}
+func (v view) GetBool(keys ...interface{}) bool { return v.node.GetBool(v.keys(keys)...) }
+func (v view) TryGetBool(keys ...interface{}) (bool, error) {
+	return v.node.TryGetBool(v.keys(keys)...)
+}
+func (v view) GetBoolDefault(def bool, keys ...interface{}) bool {
+	return v.node.GetBoolDefault(def, v.keys(keys)...)
+}
+func (v view) GetDuration(keys ...interface{}) time.Duration {
+	return v.node.GetDuration(v.keys(keys)...)
+}
+func (v view) TryGetDuration(keys ...interface{}) (time.Duration, error) {
+	return v.node.TryGetDuration(v.keys(keys)...)
+}
+func (v view) GetDurationDefault(def time.Duration, keys ...interface{}) time.Duration {
+	return v.node.GetDurationDefault(def, v.keys(keys)...)
+}
+func (v view) GetTime(keys ...interface{}) time.Time { return v.node.GetTime(v.keys(keys)...) }
+func (v view) TryGetTime(keys ...interface{}) (time.Time, error) {
+	return v.node.TryGetTime(v.keys(keys)...)
+}
+func (v view) GetTimeDefault(def time.Time, keys ...interface{}) time.Time {
+	return v.node.GetTimeDefault(def, v.keys(keys)...)
+}
+func (v view) GetURL(keys ...interface{}) *url.URL { return v.node.GetURL(v.keys(keys)...) }
+func (v view) TryGetURL(keys ...interface{}) (*url.URL, error) {
+	return v.node.TryGetURL(v.keys(keys)...)
+}
+func (v view) GetURLDefault(def *url.URL, keys ...interface{}) *url.URL {
+	return v.node.GetURLDefault(def, v.keys(keys)...)
+}
+func (v view) GetRelativeURL(keys ...interface{}) *url.URL {
+	return v.node.GetRelativeURL(v.keys(keys)...)
+}
+func (v view) GetIP(keys ...interface{}) net.IP { return v.node.GetIP(v.keys(keys)...) }
+func (v view) TryGetIP(keys ...interface{}) (net.IP, error) {
+	return v.node.TryGetIP(v.keys(keys)...)
+}
+func (v view) GetIPDefault(def net.IP, keys ...interface{}) net.IP {
+	return v.node.GetIPDefault(def, v.keys(keys)...)
+}
+func (v view) GetCIDR(keys ...interface{}) *net.IPNet { return v.node.GetCIDR(v.keys(keys)...) }
+func (v view) TryGetCIDR(keys ...interface{}) (*net.IPNet, error) {
+	return v.node.TryGetCIDR(v.keys(keys)...)
+}
+func (v view) GetCIDRDefault(def *net.IPNet, keys ...interface{}) *net.IPNet {
+	return v.node.GetCIDRDefault(def, v.keys(keys)...)
+}
+func (v view) GetRegexp(keys ...interface{}) *regexp.Regexp { return v.node.GetRegexp(v.keys(keys)...) }
+func (v view) TryGetRegexp(keys ...interface{}) (*regexp.Regexp, error) {
+	return v.node.TryGetRegexp(v.keys(keys)...)
+}
+func (v view) GetBytes(keys ...interface{}) []byte { return v.node.GetBytes(v.keys(keys)...) }
+func (v view) TryGetBytes(keys ...interface{}) ([]byte, error) {
+	return v.node.TryGetBytes(v.keys(keys)...)
+}
+func (v view) GetJSON(target interface{}, keys ...interface{}) bool {
+	return v.node.GetJSON(target, v.keys(keys)...)
+}
+func (v view) TryGetJSON(target interface{}, keys ...interface{}) error {
+	return v.node.TryGetJSON(target, v.keys(keys)...)
+}
+func (v view) TryGetFirst(paths ...interface{}) (Value, error) {
+	return v.node.TryGetFirst(v.paths(paths)...)
+}
+func (v view) GetStringFirst(paths ...interface{}) string {
+	return v.node.GetStringFirst(v.paths(paths)...)
+}
+func (v view) GetIntFirst(paths ...interface{}) int { return v.node.GetIntFirst(v.paths(paths)...) }
+func (v view) TryGetEnum(allowed []string, keys ...interface{}) (string, error) {
+	return v.node.TryGetEnum(allowed, v.keys(keys)...)
+}
+func (v view) TryGetEnumWith(opts EnumOptions, keys ...interface{}) (string, error) {
+	return v.node.TryGetEnumWith(opts, v.keys(keys)...)
+}
+func (v view) GetEnumDefault(def string, allowed []string, keys ...interface{}) string {
+	return v.node.GetEnumDefault(def, allowed, v.keys(keys)...)
+}
+func (v view) GetEnumDefaultWith(def string, opts EnumOptions, keys ...interface{}) string {
+	return v.node.GetEnumDefaultWith(def, opts, v.keys(keys)...)
+}
+func (v view) GetInto(target interface{}, keys ...interface{}) error {
+	return v.node.GetInto(target, v.keys(keys)...)
+}
+
+func (v view) GetStringSlice(keys ...interface{}) []string {
+	return v.node.GetStringSlice(v.keys(keys)...)
+}
+func (v view) TryGetStringSlice(keys ...interface{}) ([]string, error) {
+	return v.node.TryGetStringSlice(v.keys(keys)...)
+}
+func (v view) GetStringSliceDefault(def []string, keys ...interface{}) []string {
+	return v.node.GetStringSliceDefault(def, v.keys(keys)...)
+}
+func (v view) GetIntSlice(keys ...interface{}) []int { return v.node.GetIntSlice(v.keys(keys)...) }
+func (v view) TryGetIntSlice(keys ...interface{}) ([]int, error) {
+	return v.node.TryGetIntSlice(v.keys(keys)...)
+}
+func (v view) GetIntSliceDefault(def []int, keys ...interface{}) []int {
+	return v.node.GetIntSliceDefault(def, v.keys(keys)...)
+}
+func (v view) GetFloatSlice(keys ...interface{}) []float64 {
+	return v.node.GetFloatSlice(v.keys(keys)...)
+}
+func (v view) TryGetFloatSlice(keys ...interface{}) ([]float64, error) {
+	return v.node.TryGetFloatSlice(v.keys(keys)...)
+}
+func (v view) GetFloatSliceDefault(def []float64, keys ...interface{}) []float64 {
+	return v.node.GetFloatSliceDefault(def, v.keys(keys)...)
+}
+func (v view) GetBoolSlice(keys ...interface{}) []bool {
+	return v.node.GetBoolSlice(v.keys(keys)...)
+}
+func (v view) TryGetBoolSlice(keys ...interface{}) ([]bool, error) {
+	return v.node.TryGetBoolSlice(v.keys(keys)...)
+}
+func (v view) GetBoolSliceDefault(def []bool, keys ...interface{}) []bool {
+	return v.node.GetBoolSliceDefault(def, v.keys(keys)...)
+}
+func (v view) GetDurationSlice(keys ...interface{}) []time.Duration {
+	return v.node.GetDurationSlice(v.keys(keys)...)
+}
+func (v view) TryGetDurationSlice(keys ...interface{}) ([]time.Duration, error) {
+	return v.node.TryGetDurationSlice(v.keys(keys)...)
+}
+func (v view) GetDurationSliceDefault(def []time.Duration, keys ...interface{}) []time.Duration {
+	return v.node.GetDurationSliceDefault(def, v.keys(keys)...)
+}
+func (v view) GetTimeSlice(keys ...interface{}) []time.Time {
+	return v.node.GetTimeSlice(v.keys(keys)...)
+}
+func (v view) TryGetTimeSlice(keys ...interface{}) ([]time.Time, error) {
+	return v.node.TryGetTimeSlice(v.keys(keys)...)
+}
+func (v view) GetIPSlice(keys ...interface{}) []net.IP {
+	return v.node.GetIPSlice(v.keys(keys)...)
+}
+func (v view) TryGetIPSlice(keys ...interface{}) ([]net.IP, error) {
+	return v.node.TryGetIPSlice(v.keys(keys)...)
+}
+
+func (v view) GetValues(keys ...interface{}) []Value { return v.node.GetValues(v.keys(keys)...) }
+func (v view) GetStringValues(keys ...interface{}) []string {
+	return v.node.GetStringValues(v.keys(keys)...)
+}
+func (v view) GetMap(keys ...interface{}) Args          { return v.node.GetMap(v.keys(keys)...) }
+func (v view) GetStringMap(keys ...interface{}) StrArgs { return v.node.GetStringMap(v.keys(keys)...) }
+func (v view) GetKeys(keys ...interface{}) []string     { return v.node.GetKeys(v.keys(keys)...) }
+func (v view) GetSettings(keys ...interface{}) Reply    { return v.node.GetSettings(v.keys(keys)...) }
+
+func (v view) MarshalJSON() ([]byte, error) { return v.target().MarshalJSON() }
+func (v view) String() string               { return v.target().String() }
@@ -0,0 +1,71 @@
+package trix
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestView(t *testing.T) {
+	root := NewRoot()
+	root.SetKey("server.host", "localhost")
+	root.SetKey("server.port", 8080)
+	root.SetKey("server.timeout", "30s")
+	root.SetKey("server.debug", "on")
+	root.SetKey("server.ratio", 0.5)
+	root.SetKey("server.started", "2020-01-02T03:04:05Z")
+	root.SetKey("settings.mode.1.default", "speed:fast")
+	scope := root.With(Args{"server.port": 9090})
+	v := scope.View()
+
+	// getter results match the unwrapped tree
+	for _, key := range []string{"server.host", "server.port", "server.timeout", "server.debug", "server.ratio", "server.started", "missing"} {
+		testDeepEqual(t, v.Get(key), scope.Get(key))
+		testDeepEqual(t, v.GetString(key), scope.GetString(key))
+		testDeepEqual(t, v.GetInt(key), scope.GetInt(key))
+		testDeepEqual(t, v.GetFloat(key), scope.GetFloat(key))
+		testDeepEqual(t, v.GetBool(key), scope.GetBool(key))
+		testDeepEqual(t, v.GetDuration(key), scope.GetDuration(key))
+		testDeepEqual(t, v.GetTime(key), scope.GetTime(key))
+		_, err := v.TryGet(key)
+		_, err2 := scope.TryGet(key)
+		testDeepEqual(t, err, err2)
+	}
+	testDeepEqual(t, v.GetIntDefault(1, "missing"), 1)
+	testDeepEqual(t, v.GetDurationDefault(time.Second, "server.host"), time.Second)
+	testDeepEqual(t, v.GetValues("*.port"), scope.GetValues("*.port"))
+	testDeepEqual(t, v.GetMap("server"), scope.GetMap("server"))
+	testDeepEqual(t, v.GetKeys("server.*"), scope.GetKeys("server.*"))
+	testDeepEqual(t, v.GetSettings("settings.mode"), Reply{"speed": {"fast"}})
+	testDeepEqual(t, v.String(), scope.String())
+	byt, err := json.Marshal(v.GetNode("server"))
+	testError(t, err, "")
+	expected, _ := json.Marshal(scope.GetNode("server"))
+	testDeepEqual(t, string(byt), string(expected))
+
+	// nodes are returned as views
+	server := v.GetNode("server")
+	testDeepEqual(t, server.Key(), "server")
+	testDeepEqual(t, server.Path(), []string{"server"})
+	testDeepEqual(t, server.GetInt("port"), 9090)
+	testDeepEqual(t, server.Parent().Key(), "")
+	testTrue(t, server.Parent().Parent() == nil)
+	testDeepEqual(t, len(server.Children()), 1) // only the port is on this scope
+	testDeepEqual(t, server.Children()[0].Value(), 9090)
+	testDeepEqual(t, len(v.GetNodes("server.port")), 2)
+	testDeepEqual(t, v.GetNodes("server.port")[1].Value(), 8080)
+	testTrue(t, v.GetNode("missing") == nil)
+	missing, err := v.TryGetNode("missing")
+	testTrue(t, missing == nil)
+	testError(t, err, `key "missing": node not found`)
+
+	// the underlying nodes can't be reached
+	for _, leaked := range []ReadOnly{v, server, v.GetNodes("server.*")[0], server.Children()[0]} {
+		var i interface{} = leaked
+		_, isNode := i.(*Node)
+		testTrue(t, !isNode)
+		_, isSetter := i.(interface{ SetKey(string, Value) *Node })
+		testTrue(t, !isSetter)
+	}
+	testTrue(t, (*Node)(nil).View() == nil)
+}